@@ -0,0 +1,33 @@
+// Command goverrun runs declarative test plans (YAML or JSON, see goverrun.Plan) without writing Go:
+//
+//	goverrun validate plan.yaml
+//	goverrun run -smoke plan.yaml
+//	goverrun run -path /tmp/goverrun plan.yaml
+//	goverrun report -path /tmp/goverrun
+//	goverrun export -path /tmp/goverrun -format csv
+//	goverrun compare /tmp/goverrun-baseline /tmp/goverrun
+//
+// The load of the plan overrides the load flags of the run subcommand, the -override flag overrides both
+// (like -override 'checkout:users=5'). Flags can also be set as environment variables like GOVERRUN_USERS. The exit code is 0 on success,
+// 1 on errors (like invalid plans), 2 on invalid usage and 3 on unmet expectations, failed smoke runs or regressions.
+package main
+
+import (
+	. "github.com/goverrun/goverrun/core"
+	"os"
+)
+
+func main() {
+	CommandlineDefaults(1, 0, 10, 0, "/tmp/goverrun")
+	if SubcommandRun.Parsed() {
+		if len(CommandlineArgs.SubcommandArgs) == 0 {
+			LogError("Missing plan file (use as argument at the end)")
+			os.Exit(ExitUsage)
+		}
+		plan, err := LoadPlan(CommandlineArgs.SubcommandArgs[0])
+		CheckErrAndLogFatal(err, "unable to load plan")
+		err = plan.AddScenarios(DefaultLoadConfigFromArgs())
+		CheckErrAndLogFatal(err, "unable to add scenarios")
+	}
+	RunFromCommandlineArgs()
+}
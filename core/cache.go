@@ -0,0 +1,261 @@
+package goverrun
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPCache emulates the private HTTP cache of a browser for a single user (see LoadConfig.HTTPCache).
+// It honors Cache-Control, Expires, Vary as well as the validators ETag and Last-Modified for
+// revalidating stale entries via If-None-Match and If-Modified-Since.
+type HTTPCache struct {
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	statusCode int
+	status     string
+	proto      string
+	protoMajor int
+	protoMinor int
+	header     http.Header
+	body       []byte
+	vary       map[string]string // request header values of the headers listed in the Vary response header
+	freshUntil time.Time
+}
+
+// cacheOutcome is carried via the request context to tell the request execution how the cache answered.
+type cacheOutcome struct {
+	hit, revalidated bool
+}
+
+type cacheOutcomeKey struct{}
+
+// heuristicallyCacheable are the status codes which may be cached without explicit freshness information.
+var heuristicallyCacheable = map[int]bool{200: true, 203: true, 204: true, 300: true, 301: true, 308: true, 404: true, 405: true, 410: true, 414: true, 501: true}
+
+// NewHTTPCache creates an empty cache.
+func NewHTTPCache() *HTTPCache {
+	return &HTTPCache{entries: make(map[string]*cacheEntry)}
+}
+
+// Clear removes all entries from the cache (like a user clearing the browser cache).
+func (cache *HTTPCache) Clear() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.entries = make(map[string]*cacheEntry)
+}
+
+// Len returns the number of cached entries.
+func (cache *HTTPCache) Len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	return len(cache.entries)
+}
+
+func (cache *HTTPCache) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	key := req.URL.String()
+	if req.Method != http.MethodGet {
+		resp, err := next.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && req.Method != http.MethodOptions && resp.StatusCode < 400 {
+			cache.lock.Lock()
+			delete(cache.entries, key) // unsafe methods invalidate the cached resource
+			cache.lock.Unlock()
+		}
+		return resp, err
+	}
+	requestDirectives := cacheControl(req.Header)
+	if _, noStore := requestDirectives["no-store"]; noStore || len(req.Header.Get("If-None-Match")) > 0 || len(req.Header.Get("If-Modified-Since")) > 0 {
+		return next.RoundTrip(req) // conditional requests of the scenario itself are passed through untouched
+	}
+	outcome, _ := req.Context().Value(cacheOutcomeKey{}).(*cacheOutcome)
+	if outcome == nil {
+		outcome = &cacheOutcome{}
+	}
+
+	cache.lock.Lock()
+	entry := cache.entries[key]
+	cache.lock.Unlock()
+	if entry != nil && !entry.matches(req) {
+		entry = nil
+	}
+	_, noCache := requestDirectives["no-cache"]
+	if entry != nil && !noCache && requestDirectives["max-age"] != "0" && time.Now().Before(entry.freshUntil) {
+		outcome.hit = true
+		return entry.response(req), nil
+	}
+
+	if entry != nil && (len(entry.header.Get("ETag")) > 0 || len(entry.header.Get("Last-Modified")) > 0) {
+		conditional := req.Clone(req.Context())
+		if etag := entry.header.Get("ETag"); len(etag) > 0 {
+			conditional.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.header.Get("Last-Modified"); len(lastModified) > 0 {
+			conditional.Header.Set("If-Modified-Since", lastModified)
+		}
+		resp, err := next.RoundTrip(conditional)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			updated := *entry // entries are never modified as they may be in use concurrently (e.g. by embedded resources)
+			updated.header = entry.header.Clone()
+			for name, values := range resp.Header {
+				updated.header[name] = values
+			}
+			updated.freshUntil = freshUntil(updated.header, updated.statusCode, time.Now())
+			cache.lock.Lock()
+			cache.entries[key] = &updated
+			cache.lock.Unlock()
+			outcome.revalidated = true
+			return updated.response(req), nil
+		}
+		return cache.store(key, req, resp)
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return cache.store(key, req, resp)
+}
+
+// store caches the response (when allowed to) and returns it with a re-readable body.
+func (cache *HTTPCache) store(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	responseDirectives := cacheControl(resp.Header)
+	_, noStore := responseDirectives["no-store"]
+	_, explicitFreshness := responseDirectives["max-age"]
+	explicitFreshness = explicitFreshness || len(resp.Header.Get("Expires")) > 0
+	validators := len(resp.Header.Get("ETag")) > 0 || len(resp.Header.Get("Last-Modified")) > 0
+	if noStore || resp.Header.Get("Vary") == "*" || !(heuristicallyCacheable[resp.StatusCode] || explicitFreshness) || !(explicitFreshness || validators) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	entry := &cacheEntry{
+		statusCode: resp.StatusCode,
+		status:     resp.Status,
+		proto:      resp.Proto,
+		protoMajor: resp.ProtoMajor,
+		protoMinor: resp.ProtoMinor,
+		header:     resp.Header.Clone(),
+		body:       body,
+		vary:       make(map[string]string),
+		freshUntil: freshUntil(resp.Header, resp.StatusCode, time.Now()),
+	}
+	for _, name := range strings.Split(resp.Header.Get("Vary"), ",") {
+		if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); len(name) > 0 {
+			entry.vary[name] = strings.Join(req.Header.Values(name), ",")
+		}
+	}
+	cache.lock.Lock()
+	cache.entries[key] = entry
+	cache.lock.Unlock()
+	return resp, nil
+}
+
+func (entry *cacheEntry) matches(req *http.Request) bool {
+	for name, value := range entry.vary {
+		if strings.Join(req.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (entry *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        entry.status,
+		StatusCode:    entry.statusCode,
+		Proto:         entry.proto,
+		ProtoMajor:    entry.protoMajor,
+		ProtoMinor:    entry.protoMinor,
+		Header:        entry.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(entry.body)),
+		ContentLength: int64(len(entry.body)),
+		Request:       req,
+	}
+}
+
+// freshUntil calculates until when a response received at the given time is fresh (RFC 9111 section 4.2),
+// i.e. the time of receiving it plus its freshness lifetime minus its age at that time.
+func freshUntil(header http.Header, statusCode int, received time.Time) time.Time {
+	directives := cacheControl(header)
+	if _, noCache := directives["no-cache"]; noCache {
+		return received // always revalidate
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = received
+	}
+	age := received.Sub(date)
+	if age < 0 {
+		age = 0
+	}
+	if seconds, err := strconv.Atoi(header.Get("Age")); err == nil && seconds > 0 {
+		age += time.Duration(seconds) * time.Second
+	}
+	var lifetime time.Duration
+	if maxAge, exists := directives["max-age"]; exists {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return received
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if expires := header.Get("Expires"); len(expires) > 0 {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return received // invalid dates like "0" mean already expired
+		}
+		lifetime = expiresAt.Sub(date)
+	} else if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil && heuristicallyCacheable[statusCode] {
+		lifetime = date.Sub(lastModified) / 10 // heuristic freshness like browsers do
+	}
+	return received.Add(lifetime - age)
+}
+
+// cacheControl parses the Cache-Control (and legacy Pragma) header into lower-case directives and their (unquoted) values.
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if len(directive) == 0 {
+				continue
+			}
+			name, argument := directive, ""
+			if i := strings.Index(directive, "="); i >= 0 {
+				name, argument = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = argument
+		}
+	}
+	if len(header.Values("Cache-Control")) == 0 && strings.EqualFold(header.Get("Pragma"), "no-cache") {
+		directives["no-cache"] = ""
+	}
+	return directives
+}
+
+// trackCacheOutcome flags the response as served from the cache and reduces the tracked sizes to the actual network traffic.
+func (response *Response) trackCacheOutcome(outcome *cacheOutcome) {
+	switch {
+	case outcome.hit:
+		response.CacheHit = true
+		response.RequestSize, response.ResponseSize = 0, 0
+	case outcome.revalidated:
+		response.CacheRevalidated = true
+		response.ResponseSize -= len(response.Body) // the 304 response has no body
+	}
+}
@@ -0,0 +1,103 @@
+package goverrun
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPCache(t *testing.T) {
+	var lock sync.Mutex
+	served := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		served[r.Method+" "+r.URL.Path]++
+		lock.Unlock()
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/last-modified":
+			w.Header().Set("Last-Modified", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+			w.Header().Set("Cache-Control", "max-age=0")
+			if len(r.Header.Get("If-Modified-Since")) > 0 {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		}
+		_, _ = fmt.Fprint(w, "content of ", r.URL.Path)
+	}))
+	defer server.Close()
+
+	cache := NewHTTPCache()
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{
+		Transport: &RoundTripperWrapper{realRoundTripper: http.DefaultTransport, UserAgent: "test", Cache: cache},
+	}}
+	fetch := func(path string) *Response {
+		response := user.Step(path).Request(http.MethodGet, server.URL+path).SendWithoutTimeout()
+		if string(response.Body) != "content of "+path || response.StatusCode != http.StatusOK {
+			t.Errorf("%s: unexpected response %d %q", path, response.StatusCode, response.Body)
+		}
+		return response
+	}
+	for _, path := range []string{"/fresh", "/etag", "/last-modified", "/no-store"} {
+		if response := fetch(path); response.CacheHit || response.CacheRevalidated {
+			t.Errorf("%s: first request must not be served from the cache", path)
+		}
+	}
+	if response := fetch("/fresh"); !response.CacheHit || response.ResponseSize != 0 {
+		t.Errorf("expected cache hit: %+v", response)
+	}
+	if response := fetch("/etag"); !response.CacheRevalidated || response.stepEntry().CacheHit {
+		t.Errorf("expected ETag revalidation: %+v", response)
+	}
+	if response := fetch("/last-modified"); !response.CacheRevalidated {
+		t.Errorf("expected Last-Modified revalidation: %+v", response)
+	}
+	if response := fetch("/no-store"); response.CacheHit || response.CacheRevalidated {
+		t.Errorf("expected no-store to bypass the cache: %+v", response)
+	}
+	user.Step("update").Request(http.MethodPost, server.URL+"/fresh").SendWithoutTimeout()
+	if response := fetch("/fresh"); response.CacheHit {
+		t.Error("expected POST to invalidate the cached entry")
+	}
+	cache.Clear()
+	if response := fetch("/fresh"); response.CacheHit || cache.Len() != 1 {
+		t.Error("expected cleared cache to fetch again")
+	}
+	want := map[string]int{"GET /fresh": 3, "POST /fresh": 1, "GET /etag": 2, "GET /last-modified": 2, "GET /no-store": 2}
+	if fmt.Sprint(served) != fmt.Sprint(want) {
+		t.Errorf("got %v want %v", served, want)
+	}
+}
+
+func TestHTTPCacheFreshness(t *testing.T) {
+	received := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	date := received.Format(http.TimeFormat)
+	for _, test := range []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{"Cache-Control": {"public, max-age=120"}, "Age": {"20"}}, 100 * time.Second},
+		{http.Header{"Cache-Control": {"max-age=120, no-cache"}}, 0},
+		{http.Header{"Date": {date}, "Expires": {received.Add(time.Hour).Format(http.TimeFormat)}}, time.Hour},
+		{http.Header{"Date": {date}, "Expires": {"0"}}, 0},
+		{http.Header{"Date": {date}, "Last-Modified": {received.Add(-10 * time.Hour).Format(http.TimeFormat)}}, time.Hour},
+		{http.Header{"Pragma": {"no-cache"}, "Cache-Control": {"max-age=60"}}, time.Minute},
+	} {
+		if got := freshUntil(test.header, http.StatusOK, received).Sub(received); got != test.want {
+			t.Errorf("%v: got %v want %v", test.header, got, test.want)
+		}
+	}
+}
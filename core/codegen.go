@@ -0,0 +1,305 @@
+package goverrun
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordedRequest is a captured request (like from a HAR file or the recording proxy) used for generating scenario code.
+type RecordedRequest struct {
+	Started      time.Time
+	Duration     time.Duration
+	Method       string
+	URL          string
+	Header       http.Header
+	Body         []byte
+	StatusCode   int
+	RedirectURL  string // target of redirect responses (followed automatically by the generated code)
+	ContentType  string // of the response
+	ResourceType string // as recorded by browsers (like document, xhr, script or image)
+	ResponseBody []byte // (if recorded) to find dynamic values requiring correlation
+	Step         string // name of the step (optional, defaults to method and path)
+}
+
+// CodegenOptions configures the generated scenario code.
+type CodegenOptions struct {
+	Package             string        // defaults to main
+	FuncName            string        // of the Scenario.Runner, defaults to recordedScenario
+	Source              string        // mentioned in the generated comment (like the HAR filename)
+	Hosts               []string      // hosts to keep requests of (defaults to the host of the first request), all others are third-party
+	IncludeStaticAssets bool          // keep requests of images, stylesheets, scripts and fonts (usually fetched via FetchEmbeddedResources instead)
+	MinThinkTime        time.Duration // recorded gaps shorter than this are not generated as think times (defaults to 500ms)
+	Timeout             time.Duration // of every request (defaults to 10s)
+}
+
+var staticAssetExtensions = map[string]bool{
+	".css": true, ".js": true, ".mjs": true, ".map": true, ".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".ico": true, ".webp": true, ".avif": true, ".bmp": true, ".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp4": true, ".webm": true, ".mp3": true, ".wav": true,
+}
+
+var staticAssetResourceTypes = map[string]bool{
+	"stylesheet": true, "script": true, "image": true, "font": true, "media": true, "manifest": true, "texttrack": true,
+}
+
+// skippedRecordedHeaders are set by the transport, the cookie jar or the Go HTTP client itself.
+var skippedRecordedHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Connection": true, "Keep-Alive": true, "Proxy-Connection": true, "Te": true,
+	"Upgrade": true, "Cookie": true, "Accept-Encoding": true, "User-Agent": true,
+}
+
+// correlationCandidatePattern matches names of parameters and headers likely carrying dynamic values.
+var correlationCandidatePattern = regexp.MustCompile(`(?i)csrf|xsrf|token|nonce|session|^sid$|^state$|viewstate|eventvalidation|authenticity|verification|^authorization$`)
+
+// minCorrelationValueLength avoids reporting short values (like ids or flags) found in previous responses.
+const minCorrelationValueLength = 8
+
+// IsStaticAsset returns if the recorded request fetched a static asset (by resource type, content type or file extension).
+func (rec RecordedRequest) IsStaticAsset() bool {
+	if staticAssetResourceTypes[rec.ResourceType] {
+		return true
+	}
+	contentType := strings.ToLower(rec.ContentType)
+	if strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "font/") || strings.HasPrefix(contentType, "text/css") ||
+		strings.Contains(contentType, "javascript") {
+		return true
+	}
+	if u, err := url.Parse(rec.URL); err == nil {
+		return staticAssetExtensions[strings.ToLower(path.Ext(u.Path))]
+	}
+	return false
+}
+
+// GenerateScenarioCode generates the Go source of a Scenario.Runner sending the recorded requests with the fluent API.
+// Static assets and requests to third-party hosts are filtered, recorded gaps become think times, redirects are
+// followed like by the Go HTTP client and likely correlation candidates (like CSRF tokens) are marked with TODO comments.
+func GenerateScenarioCode(recorded []RecordedRequest, options CodegenOptions) ([]byte, error) {
+	if len(options.Package) == 0 {
+		options.Package = "main"
+	}
+	if len(options.FuncName) == 0 {
+		options.FuncName = "recordedScenario"
+	}
+	if options.MinThinkTime == 0 {
+		options.MinThinkTime = 500 * time.Millisecond
+	}
+	if options.Timeout == 0 {
+		options.Timeout = 10 * time.Second
+	}
+	recorded = append([]RecordedRequest(nil), recorded...)
+	sort.SliceStable(recorded, func(i, j int) bool { return recorded[i].Started.Before(recorded[j].Started) })
+	hosts := make(map[string]bool)
+	for _, host := range options.Hosts {
+		hosts[strings.ToLower(host)] = true
+	}
+	var baseURL string
+	var kept []RecordedRequest
+	var gaps []time.Duration // think time before each kept request
+	var lastEnd time.Time
+	followed := make(map[string]int) // redirect targets requested automatically by following the redirect (to the index in kept of the redirecting request)
+	var seenValues []string          // of previous responses for finding correlation candidates
+	for _, rec := range recorded {
+		u, err := url.Parse(rec.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if len(hosts) == 0 {
+			hosts[strings.ToLower(u.Host)] = true
+		}
+		if len(baseURL) == 0 && hosts[strings.ToLower(u.Host)] {
+			baseURL = u.Scheme + "://" + u.Host
+		}
+		gap := time.Duration(0)
+		if !lastEnd.IsZero() {
+			gap = rec.Started.Sub(lastEnd)
+		}
+		if end := rec.Started.Add(rec.Duration); end.After(lastEnd) {
+			lastEnd = end
+		}
+		if !hosts[strings.ToLower(u.Host)] || (!options.IncludeStaticAssets && rec.IsStaticAsset()) {
+			continue
+		}
+		index, isFollowed := followed[rec.URL]
+		if isFollowed && rec.Method == http.MethodGet {
+			delete(followed, rec.URL)
+			kept[index].StatusCode = rec.StatusCode // the final status of the followed redirect
+		} else {
+			index = len(kept)
+		}
+		if len(rec.RedirectURL) > 0 {
+			if target, err := u.Parse(rec.RedirectURL); err == nil {
+				followed[target.String()] = index // also for chained redirects
+			}
+		}
+		if index < len(kept) {
+			continue
+		}
+		kept = append(kept, rec)
+		gaps = append(gaps, gap)
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("no requests left after filtering static assets and third-party hosts")
+	}
+
+	var code strings.Builder
+	usesBody := false
+	for i, rec := range kept {
+		if gaps[i] >= options.MinThinkTime {
+			fmt.Fprintf(&code, "\tuser.ThinkTime(%s)\n", durationLiteral(gaps[i].Round(100*time.Millisecond)))
+		}
+		if writeRecordedRequest(&code, rec, baseURL, options.Timeout, seenValues) {
+			usesBody = true
+		}
+		if len(rec.ResponseBody) > 0 {
+			seenValues = append(seenValues, string(rec.ResponseBody))
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "package %s\n\n", options.Package)
+	src.WriteString("import (\n\t. \"github.com/goverrun/goverrun/core\"\n")
+	if usesBody {
+		src.WriteString("\t\"io\"\n")
+	}
+	src.WriteString("\t\"net/http\"\n")
+	if usesBody {
+		src.WriteString("\t\"strings\"\n")
+	}
+	src.WriteString("\t\"time\"\n)\n\n")
+	fmt.Fprintf(&src, "var baseURL = %s\n\n", strconv.Quote(baseURL))
+	if len(options.Source) > 0 {
+		fmt.Fprintf(&src, "// %s was generated from %s.\n", options.FuncName, options.Source)
+	} else {
+		fmt.Fprintf(&src, "// %s was generated from a recording.\n", options.FuncName)
+	}
+	fmt.Fprintf(&src, "func %s(user *User) {\n", options.FuncName)
+	if usesBody {
+		src.WriteString("\tvar body io.Reader\n")
+	}
+	src.WriteString(code.String())
+	src.WriteString("}\n")
+	return format.Source(src.Bytes())
+}
+
+// writeRecordedRequest writes the step of the request and returns if it uses the body variable (assigned before the step).
+func writeRecordedRequest(code *strings.Builder, rec RecordedRequest, baseURL string, timeout time.Duration, seenValues []string) (bodyUsed bool) {
+	u, _ := url.Parse(rec.URL)
+	stepName := rec.Step
+	if len(stepName) == 0 {
+		stepName = rec.Method + " " + u.Path
+	}
+	target := strconv.Quote(rec.URL)
+	if strings.HasPrefix(rec.URL, baseURL) && len(baseURL) > 0 {
+		target = "baseURL"
+		if rest := strings.TrimPrefix(rec.URL, baseURL); len(rest) > 0 {
+			target += "+" + strconv.Quote(rest)
+		}
+	}
+	contentType := rec.Header.Get("Content-Type")
+	var formParams url.Values
+	if len(rec.Body) > 0 {
+		if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+			formParams, _ = url.ParseQuery(string(rec.Body))
+		}
+		if formParams == nil {
+			fmt.Fprintf(code, "\tbody = strings.NewReader(%s)\n", quoteBody(rec.Body))
+			bodyUsed = true
+		}
+	}
+	query := u.Query()
+	for _, name := range sortedKeys(query) {
+		for _, value := range query[name] {
+			writeCorrelationTodo(code, "query param", name, value, seenValues, "\t")
+		}
+	}
+	fmt.Fprintf(code, "\tuser.Step(%s).\n", strconv.Quote(stepName))
+	fmt.Fprintf(code, "\t\tRequest(%s, %s).\n", methodLiteral(rec.Method), target)
+	for _, name := range sortedKeys(rec.Header) {
+		canonical := http.CanonicalHeaderKey(name)
+		if skippedRecordedHeaders[canonical] || strings.HasPrefix(name, ":") || (formParams != nil && canonical == "Content-Type") {
+			continue
+		}
+		value := rec.Header.Get(name)
+		writeCorrelationTodo(code, "header", canonical, value, seenValues, "\t\t")
+		fmt.Fprintf(code, "\t\tSetHeader(%s, %s).\n", strconv.Quote(canonical), strconv.Quote(value))
+	}
+	for _, name := range sortedKeys(formParams) {
+		for i, value := range formParams[name] {
+			writeCorrelationTodo(code, "form param", name, value, seenValues, "\t\t")
+			method := "SetFormParam"
+			if i > 0 {
+				method = "AddFormParam" // repeated names like multiple selected checkboxes
+			}
+			fmt.Fprintf(code, "\t\t%s(%s, %s).\n", method, strconv.Quote(name), strconv.Quote(value))
+		}
+	}
+	if bodyUsed {
+		code.WriteString("\t\tSetBody(&body).\n")
+	}
+	fmt.Fprintf(code, "\t\tSendWithTimeout(%s).\n", durationLiteral(timeout))
+	if rec.StatusCode > 0 {
+		fmt.Fprintf(code, "\t\tAssertStatusCode(%d).\n", rec.StatusCode)
+	}
+	code.WriteString("\t\tArchiveStats()\n")
+	return bodyUsed
+}
+
+// writeCorrelationTodo marks values with names of typical dynamic values or seen in previous responses.
+func writeCorrelationTodo(code *strings.Builder, kind, name, value string, seenValues []string, indent string) {
+	reason := ""
+	if correlationCandidatePattern.MatchString(name) {
+		reason = "name suggests a dynamic value"
+	} else if len(value) >= minCorrelationValueLength {
+		for _, seen := range seenValues {
+			if strings.Contains(seen, value) {
+				reason = "value was received in a previous response"
+				break
+			}
+		}
+	}
+	if len(reason) > 0 {
+		fmt.Fprintf(code, "%s// TODO correlate %s %s (%s): extract it from a previous response instead of the recorded value\n", indent, kind, strconv.Quote(name), reason)
+	}
+}
+
+func quoteBody(body []byte) string {
+	if bytes.IndexByte(body, '`') < 0 && bytes.IndexByte(body, '\r') < 0 && strconv.CanBackquote(strings.ReplaceAll(string(body), "\n", "")) {
+		return "`" + string(body) + "`"
+	}
+	return strconv.Quote(string(body))
+}
+
+func methodLiteral(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return "http.Method" + method[:1] + strings.ToLower(method[1:])
+	}
+	return strconv.Quote(method)
+}
+
+func durationLiteral(d time.Duration) string {
+	switch {
+	case d%time.Second == 0:
+		return fmt.Sprintf("%d * time.Second", d/time.Second)
+	default:
+		return fmt.Sprintf("%d * time.Millisecond", d/time.Millisecond)
+	}
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
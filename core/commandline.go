@@ -0,0 +1,277 @@
+package goverrun
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvironmentPrefix prefixes the environment variables of the flags, see EnvironmentVariable.
+const EnvironmentPrefix = "GOVERRUN_"
+
+// explicitFlags are the flags of the parsed subcommand which were given or set via environment variables.
+var explicitFlags = make(map[string]bool)
+
+// EnvironmentVariable returns the name of the environment variable of the flag (like GOVERRUN_RAMP_UP for -ramp-up).
+// The precedence of a setting is: given flag, environment variable, code (like the defaults of CommandlineDefaults).
+func EnvironmentVariable(flagName string) string {
+	return EnvironmentPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// setFlagsFromEnvironment sets the flags of the subcommand from their environment variables (before parsing,
+// so given flags override them).
+func setFlagsFromEnvironment(subcommand *flag.FlagSet) error {
+	var err error
+	subcommand.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(EnvironmentVariable(f.Name))
+		if !ok || err != nil {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value %q of environment variable %s: %v", value, EnvironmentVariable(f.Name), setErr)
+			return
+		}
+		if list, isList := f.Value.(*listValue); isList {
+			list.fromEnvironment = true // replaced (instead of extended) by given flags
+		}
+		explicitFlags[f.Name] = true
+	})
+	return err
+}
+
+// intervalValue is a flag value of a RandomInterval written like 2s (fixed) or 1s-3s (random).
+type intervalValue RandomInterval
+
+func intervalVar(fs *flag.FlagSet, name, usage string) *RandomInterval {
+	interval := new(RandomInterval)
+	fs.Var((*intervalValue)(interval), name, usage)
+	return interval
+}
+
+func (v *intervalValue) String() string {
+	if v == nil || v.Max == 0 {
+		return ""
+	}
+	if v.Min == v.Max {
+		return v.Min.String()
+	}
+	return v.Min.String() + "-" + v.Max.String()
+}
+
+func (v *intervalValue) Set(s string) error {
+	interval, err := parseInterval(s)
+	if err != nil {
+		return err
+	}
+	*v = intervalValue(interval)
+	return nil
+}
+
+func parseInterval(s string) (RandomInterval, error) {
+	parts := strings.SplitN(s, "-", 2)
+	min, err := parseSeconds(parts[0])
+	if err != nil {
+		return RandomInterval{}, err
+	}
+	max := min
+	if len(parts) == 2 {
+		if max, err = parseSeconds(parts[1]); err != nil {
+			return RandomInterval{}, err
+		}
+	}
+	if max < min {
+		return RandomInterval{}, fmt.Errorf("maximum %s below minimum %s", max, min)
+	}
+	return RandomInterval{Min: min, Max: max}, nil
+}
+
+// parseSeconds parses a non-negative duration like 1m30s or a number of seconds like 90.
+func parseSeconds(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, atoiErr := strconv.Atoi(s)
+		if atoiErr != nil {
+			return 0, fmt.Errorf("invalid duration %q (expected like 500ms, 2m30s or seconds like 90)", s)
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %s", d)
+	}
+	return d, nil
+}
+
+// listValue is a flag value of comma-separated items, repeated flags extend the list.
+type listValue struct {
+	items           *[]string
+	fromEnvironment bool
+}
+
+func listVar(fs *flag.FlagSet, name, usage string) *[]string {
+	items := new([]string)
+	fs.Var(&listValue{items: items}, name, usage)
+	return items
+}
+
+func (v *listValue) String() string {
+	if v == nil || v.items == nil {
+		return ""
+	}
+	return strings.Join(*v.items, ",")
+}
+
+func (v *listValue) Set(s string) error {
+	if v.fromEnvironment {
+		*v.items, v.fromEnvironment = nil, false
+	}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			*v.items = append(*v.items, item)
+		}
+	}
+	return nil
+}
+
+// loadOverrideKeys are the keys of the load overrides of scenarios (see the -override flag of the run subcommand).
+var loadOverrideKeys = []string{"users", "start-delay", "loop-delay", "ramp-up", "plateau", "ramp-down", "clear-cookies", "http-cache"}
+
+// applyRunArgs applies the given (or via environment variables set) flags of the run subcommand which override the code:
+// the run-wide defaults (like Proxy, also overriding the transport configs of the scenarios), the load overrides of
+// scenarios and the selection of the scenarios to run.
+func applyRunArgs() error {
+	if explicitFlags["proxy"] {
+		Proxy = *CommandlineArgs.Run.Proxy
+		for _, scenario := range scenarios {
+			scenario.Transport.Proxy = ProxyConfig{URL: Proxy} // also without the proxy credentials of the code
+		}
+	}
+	if explicitFlags["insecure"] {
+		SkipCertificateValidation = *CommandlineArgs.Run.SkipCertificateValidation
+	}
+	if explicitFlags["user-agent"] {
+		UserAgent = *CommandlineArgs.Run.UserAgent
+		for _, scenario := range scenarios {
+			scenario.Transport.UserAgent = UserAgent
+		}
+	}
+	if explicitFlags["tag-user-loop"] {
+		AddUserLoopHeader = *CommandlineArgs.Run.AddUserLoopHeader
+	}
+	if explicitFlags["tag-scenario-step"] {
+		AddScenarioStepHeader = *CommandlineArgs.Run.AddScenarioStepHeader
+	}
+	if err := overrideScenarioLoads(*CommandlineArgs.Run.Override); err != nil {
+		return err
+	}
+	return selectScenarios(*CommandlineArgs.Run.Include, *CommandlineArgs.Run.Exclude)
+}
+
+// matchingScenarios returns the scenarios whose titles match the pattern (see path.Match), at least one must match.
+func matchingScenarios(pattern string) ([]*Scenario, error) {
+	var matching []*Scenario
+	var titles []string
+	for title, scenario := range scenarios {
+		matched, err := path.Match(pattern, title)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scenario pattern %q: %v", pattern, err)
+		}
+		if matched {
+			matching = append(matching, scenario)
+		}
+		titles = append(titles, title)
+	}
+	if len(matching) == 0 {
+		sort.Strings(titles)
+		return nil, fmt.Errorf("no scenario matches %q (expected one of '%s')", pattern, strings.Join(titles, "', '"))
+	}
+	return matching, nil
+}
+
+// selectScenarios runs only the included (when any, even if ignored in the code) and not excluded scenarios.
+func selectScenarios(include, exclude []string) error {
+	if len(include) > 0 {
+		included := make(map[*Scenario]bool)
+		for _, pattern := range include {
+			matching, err := matchingScenarios(pattern)
+			if err != nil {
+				return err
+			}
+			for _, scenario := range matching {
+				included[scenario] = true
+			}
+		}
+		for _, scenario := range scenarios {
+			scenario.Ignored = !included[scenario]
+		}
+	}
+	for _, pattern := range exclude {
+		matching, err := matchingScenarios(pattern)
+		if err != nil {
+			return err
+		}
+		for _, scenario := range matching {
+			scenario.Ignored = true
+		}
+	}
+	return nil
+}
+
+// overrideScenarioLoads applies overrides like "checkout:users=5" (the title may be a pattern) to the load configs.
+func overrideScenarioLoads(overrides []string) error {
+	for _, override := range overrides {
+		equals := strings.Index(override, "=")
+		colon := -1
+		if equals > 0 {
+			colon = strings.LastIndex(override[:equals], ":")
+		}
+		if colon < 0 {
+			return fmt.Errorf("invalid override %q (expected like 'title:users=5')", override)
+		}
+		pattern, key, value := override[:colon], override[colon+1:equals], override[equals+1:]
+		matching, err := matchingScenarios(pattern)
+		if err != nil {
+			return err
+		}
+		for _, scenario := range matching {
+			if err := overrideLoad(&scenario.LoadConfig, key, value); err != nil {
+				return fmt.Errorf("invalid override %q: %v", override, err)
+			}
+		}
+	}
+	return nil
+}
+
+func overrideLoad(config *LoadConfig, key, value string) error {
+	var err error
+	switch key {
+	case "users":
+		var users int
+		if users, err = strconv.Atoi(value); err == nil && users <= 0 {
+			err = fmt.Errorf("zero or negative users %d", users)
+		}
+		config.LoopingUsers = users
+	case "start-delay":
+		config.StartDelay, err = parseInterval(value)
+	case "loop-delay":
+		config.LoopDelay, err = parseInterval(value)
+	case "ramp-up":
+		config.RampUp, err = parseSeconds(value)
+	case "plateau":
+		config.Plateau, err = parseSeconds(value)
+	case "ramp-down":
+		config.RampDown, err = parseSeconds(value)
+	case "clear-cookies":
+		config.ClearCookieJarOnEveryLoop, err = strconv.ParseBool(value)
+	case "http-cache":
+		config.HTTPCache, err = strconv.ParseBool(value)
+	default:
+		err = fmt.Errorf("unknown key %q (expected one of %s)", key, strings.Join(loadOverrideKeys, ", "))
+	}
+	return err
+}
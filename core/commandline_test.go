@@ -0,0 +1,112 @@
+package goverrun
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func defineTestCommandlineFlags() {
+	DefineCommandlineFlags(1, 0, 10, 0, "/tmp/goverrun", flag.ContinueOnError)
+	for _, subcommand := range Subcommands() {
+		subcommand.SetOutput(&bytes.Buffer{})
+	}
+}
+
+func TestCommandlinePrecedence(t *testing.T) {
+	t.Setenv("GOVERRUN_USERS", "7")
+	t.Setenv("GOVERRUN_PLATEAU", "20")
+	t.Setenv("GOVERRUN_LOOP_DELAY", "1s-2s")
+	t.Setenv("GOVERRUN_INCLUDE", "a")
+	t.Setenv("GOVERRUN_VERBOSE", "true")
+	defineTestCommandlineFlags()
+	if err := ParseCommandline([]string{"run", "-users", "3", "-include", "b,c", "-include", "d", "-clear-cookies=false", "target"}); err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultLoadConfigFromArgs()
+	if config.LoopingUsers != 3 || config.Plateau != 20*time.Second || config.LoopDelay != (RandomInterval{Min: time.Second, Max: 2 * time.Second}) ||
+		config.ClearCookieJarOnEveryLoop || !*CommandlineArgs.Run.Verbose {
+		t.Errorf("unexpected load config: %+v", config)
+	}
+	if include := strings.Join(*CommandlineArgs.Run.Include, ","); include != "b,c,d" {
+		t.Errorf("unexpected include: %s", include)
+	}
+	if !explicitFlags["verbose"] || !explicitFlags["users"] || explicitFlags["proxy"] {
+		t.Errorf("unexpected explicit flags: %v", explicitFlags)
+	}
+
+	t.Setenv("GOVERRUN_START_DELAY", "soon")
+	defineTestCommandlineFlags()
+	if err := ParseCommandline([]string{"run"}); err == nil || !strings.Contains(err.Error(), "GOVERRUN_START_DELAY") {
+		t.Errorf("expected invalid environment variable error, got %v", err)
+	}
+}
+
+func TestApplyRunArgs(t *testing.T) {
+	defer Reset()
+	for _, title := range []string{"browse", "checkout", "checkout express", "admin"} {
+		transport := TransportConfig{Proxy: ProxyConfig{URL: "http://proxy-of-code:3128", Username: "code"}, UserAgent: "Agent-of-code"}
+		if err := AddScenario(&Scenario{Title: title, LoadConfig: LoadConfig{LoopingUsers: 10}, Ignored: title == "admin", Transport: transport}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	UserAgent = "from code"
+	defineTestCommandlineFlags()
+	err := ParseCommandline([]string{"run", "-proxy", "http://127.0.0.1:8080", "-insecure", "-include", "checkout*,admin", "-exclude", "checkout express",
+		"-override", "checkout*:users=2,checkout:loop-delay=1s-3s", "-override", "*:ramp-up=30", "-override", "admin:http-cache=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyRunArgs(); err != nil {
+		t.Fatal(err)
+	}
+	if Proxy != "http://127.0.0.1:8080" || !SkipCertificateValidation || UserAgent != "from code" {
+		t.Errorf("unexpected run-wide defaults: %q %v %q", Proxy, SkipCertificateValidation, UserAgent)
+	}
+	if transport := scenarios["checkout"].Transport; transport.Proxy != (ProxyConfig{URL: "http://127.0.0.1:8080"}) || transport.UserAgent != "Agent-of-code" {
+		t.Errorf("expected the proxy flag to override the transport config of the code: %+v", transport)
+	}
+	for title, expectedIgnored := range map[string]bool{"browse": true, "checkout": false, "checkout express": true, "admin": false} {
+		if scenarios[title].Ignored != expectedIgnored {
+			t.Errorf("unexpected selection of %s", title)
+		}
+	}
+	if config := scenarios["checkout"].LoadConfig; config.LoopingUsers != 2 || config.LoopDelay.Max != 3*time.Second || config.RampUp != 30*time.Second {
+		t.Errorf("unexpected overridden load config: %+v", config)
+	}
+	if config := scenarios["admin"].LoadConfig; config.LoopingUsers != 10 || !config.HTTPCache || config.RampUp != 30*time.Second {
+		t.Errorf("unexpected overridden load config: %+v", config)
+	}
+
+	defineTestCommandlineFlags()
+	if err := ParseCommandline([]string{"run", "-user-agent", "Agent-of-flag"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyRunArgs(); err != nil {
+		t.Fatal(err)
+	}
+	if transport := scenarios["browse"].Transport; transport.UserAgent != "Agent-of-flag" || transport.Proxy.URL != "http://127.0.0.1:8080" {
+		t.Errorf("expected the user agent flag to override the transport config of the code: %+v", transport)
+	}
+
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-include", "shop"}, `no scenario matches "shop" (expected one of 'admin', 'browse', 'checkout', 'checkout express')`},
+		{[]string{"-override", "users=5"}, `invalid override "users=5" (expected like 'title:users=5')`},
+		{[]string{"-override", "browse:users=0"}, `invalid override "browse:users=0": zero or negative users 0`},
+		{[]string{"-override", "browse:think=1s"}, `invalid override "browse:think=1s": unknown key "think" (expected one of ` + strings.Join(loadOverrideKeys, ", ") + ")"},
+		{[]string{"-override", "browse:loop-delay=3s-1s"}, `invalid override "browse:loop-delay=3s-1s": maximum 1s below minimum 3s`},
+	} {
+		defineTestCommandlineFlags()
+		if err := ParseCommandline(append([]string{"run"}, test.args...)); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyRunArgs(); err == nil || err.Error() != test.expected {
+			t.Errorf("unexpected error of %v: %v", test.args, err)
+		}
+	}
+}
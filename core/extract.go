@@ -0,0 +1,286 @@
+package goverrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+	stdhtml "html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Extractor extracts a value from a response and stores it into User.Data under the given key.
+// Extractors are created with ExtractRegex, ExtractJSONPath, ExtractHeader, ExtractCookie, ExtractCSS (or MustExtractCSS) or
+// ExtractXPath (or MustExtractXPath) and applied via Response.Extract.
+type Extractor struct {
+	Key         string
+	Kind        string
+	Expression  string
+	Attribute   string
+	Fallback    string
+	HasFallback bool
+	IsRequired  bool
+	regex       *regexp.Regexp    // of regex extractors
+	selector    cascadia.Selector // of CSS extractors
+	path        *xpath.Expr       // of XPath extractors
+}
+
+// ExtractRegex extracts the first capture group (or the complete match when the expression has no capture group)
+// of the regular expression applied on the response body. HTML entities of the extracted value are unescaped.
+func ExtractRegex(key string, re *regexp.Regexp) *Extractor {
+	return &Extractor{Key: key, Kind: "regex", Expression: re.String(), regex: re}
+}
+
+// ExtractJSONPath extracts the result of the JSONPath expression (like $.data.token) applied on the response body.
+// Non-string results are stored as their JSON text representation.
+func ExtractJSONPath(key, expression string) *Extractor {
+	return &Extractor{Key: key, Kind: "jsonpath", Expression: expression}
+}
+
+// ExtractHeader extracts the value of the given response header.
+func ExtractHeader(key, header string) *Extractor {
+	return &Extractor{Key: key, Kind: "header", Expression: header}
+}
+
+// ExtractCookie extracts the value of the given cookie set via the response
+// (or - when not set by the response - the value currently stored in the user's cookie jar).
+func ExtractCookie(key, cookie string) *Extractor {
+	return &Extractor{Key: key, Kind: "cookie", Expression: cookie}
+}
+
+// ExtractCSS extracts the text content (or the attribute when set via Attr) of the first HTML element
+// matching the CSS selector applied on the response body. Returns an error when the selector is invalid.
+func ExtractCSS(key, selector string) (*Extractor, error) {
+	compiled, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSS selector '%s': %w", selector, err)
+	}
+	return &Extractor{Key: key, Kind: "css", Expression: selector, selector: compiled}, nil
+}
+
+// MustExtractCSS is like ExtractCSS but panics when the selector is invalid (like regexp.MustCompile).
+func MustExtractCSS(key, selector string) *Extractor {
+	extractor, err := ExtractCSS(key, selector)
+	if err != nil {
+		panic(err)
+	}
+	return extractor
+}
+
+// ExtractXPath extracts the text content of the first HTML node matching the XPath expression applied on the response body.
+// Attributes can be selected directly via XPath like //input[@name='csrf']/@value. Returns an error when the expression is invalid.
+func ExtractXPath(key, expression string) (*Extractor, error) {
+	compiled, err := xpath.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath expression '%s': %w", expression, err)
+	}
+	return &Extractor{Key: key, Kind: "xpath", Expression: expression, path: compiled}, nil
+}
+
+// MustExtractXPath is like ExtractXPath but panics when the expression is invalid (like regexp.MustCompile).
+func MustExtractXPath(key, expression string) *Extractor {
+	extractor, err := ExtractXPath(key, expression)
+	if err != nil {
+		panic(err)
+	}
+	return extractor
+}
+
+// Attr selects the attribute of the matching element instead of its text content (only for CSS extractors).
+func (extractor *Extractor) Attr(attribute string) *Extractor {
+	extractor.Attribute = attribute
+	return extractor
+}
+
+// Default sets the value to store when the extraction finds nothing.
+func (extractor *Extractor) Default(value string) *Extractor {
+	extractor.Fallback = value
+	extractor.HasFallback = true
+	return extractor
+}
+
+// Required marks the response as failed when the extraction finds nothing (and no default is set).
+func (extractor *Extractor) Required() *Extractor {
+	extractor.IsRequired = true
+	return extractor
+}
+
+func (extractor *Extractor) String() string {
+	s := extractor.Kind + " '" + extractor.Expression + "'"
+	if len(extractor.Attribute) > 0 {
+		s += " (attribute '" + extractor.Attribute + "')"
+	}
+	return s + " into '" + extractor.Key + "'"
+}
+
+// Extract applies the given extractors on the response and stores the extracted values into User.Data.
+// When an extraction finds nothing, the default value is stored (if set), otherwise the key is removed from User.Data.
+// A failed required extraction marks the response as failed (unless the response is already considered unsuccessful).
+func (response *Response) Extract(extractors ...*Extractor) *Response {
+	if response.Step == nil || response.Step.User == nil {
+		return response // disabled user
+	}
+	user := response.Step.User
+	if user.Data == nil {
+		user.Data = make(map[string]interface{})
+	}
+	for _, extractor := range extractors {
+		value, err := extractor.extract(response)
+		if err == nil {
+			user.Data[extractor.Key] = value
+			if smoke {
+				LogSuccessf("Extracted %s: %s", extractor, redactExtracted(extractor.Key, value))
+			}
+			continue
+		}
+		if extractor.HasFallback {
+			user.Data[extractor.Key] = extractor.Fallback
+			if smoke {
+				LogWarningf("Extraction failed (default used): %s: %s", extractor, err)
+			}
+			continue
+		}
+		delete(user.Data, extractor.Key)
+		if extractor.IsRequired && !response.ConsideredUnsuccessful() {
+			response.MarkAsFailed(fmt.Sprint("required extraction failed: ", extractor, ": ", err))
+		} else if verbose || smoke {
+			LogWarningf("[%d:%d] Extraction failed: %s: %s\n", user.CurrentUser, user.CurrentLoop, extractor, err)
+		}
+	}
+	return response
+}
+
+var errNotFound = fmt.Errorf("not found")
+
+func (extractor *Extractor) extract(response *Response) (string, error) {
+	switch extractor.Kind {
+	case "regex":
+		if extractor.regex == nil {
+			return "", fmt.Errorf("regex extractor not created via ExtractRegex")
+		}
+		s := extractor.regex.FindSubmatch(response.Body)
+		if len(s) == 0 {
+			return "", fmt.Errorf("no match")
+		}
+		if len(s) > 1 {
+			return stdhtml.UnescapeString(string(s[1])), nil
+		}
+		return stdhtml.UnescapeString(string(s[0])), nil
+	case "jsonpath":
+		if !json.Valid(response.Body) {
+			return "", fmt.Errorf("response body is no valid JSON")
+		}
+		result, err := evalExpressionOnJSON(response.Body, extractor.Expression)
+		if err != nil {
+			return "", err
+		}
+		if result == nil {
+			return "", errNotFound
+		}
+		return jsonValueToString(result), nil
+	case "header":
+		if values, exists := response.Header[http.CanonicalHeaderKey(extractor.Expression)]; exists && len(values) > 0 {
+			return values[0], nil
+		}
+		return "", errNotFound
+	case "cookie":
+		for _, cookie := range (&http.Response{Header: response.Header}).Cookies() {
+			if cookie.Name == extractor.Expression {
+				return cookie.Value, nil
+			}
+		}
+		user := response.Step.User
+		if user.HttpClient != nil && user.HttpClient.Jar != nil {
+			if u, err := url.Parse(response.FinalURL); err == nil && len(response.FinalURL) > 0 {
+				for _, cookie := range user.HttpClient.Jar.Cookies(u) {
+					if cookie.Name == extractor.Expression {
+						return cookie.Value, nil
+					}
+				}
+			}
+		}
+		return "", errNotFound
+	case "css":
+		if extractor.selector == nil {
+			return "", fmt.Errorf("CSS extractor not created via ExtractCSS")
+		}
+		document, err := response.HTMLDocument()
+		if err != nil {
+			return "", err
+		}
+		node := extractor.selector.MatchFirst(document)
+		if node == nil {
+			return "", errNotFound
+		}
+		if len(extractor.Attribute) > 0 {
+			if value, exists := attribute(node, extractor.Attribute); exists {
+				return value, nil
+			}
+			return "", fmt.Errorf("attribute not found")
+		}
+		return strings.TrimSpace(htmlquery.InnerText(node)), nil
+	case "xpath":
+		if extractor.path == nil {
+			return "", fmt.Errorf("XPath extractor not created via ExtractXPath")
+		}
+		document, err := response.HTMLDocument()
+		if err != nil {
+			return "", err
+		}
+		node := htmlquery.QuerySelector(document, extractor.path)
+		if node == nil {
+			return "", errNotFound
+		}
+		return strings.TrimSpace(htmlquery.InnerText(node)), nil
+	default:
+		return "", fmt.Errorf("unknown extractor kind '%s'", extractor.Kind)
+	}
+}
+
+// HTMLDocument returns the parsed HTML document of the response body (parsed only once per response).
+func (response *Response) HTMLDocument() (*html.Node, error) {
+	if response.document != nil {
+		return response.document, nil
+	}
+	document, err := html.Parse(bytes.NewReader(response.Body))
+	if err != nil {
+		return nil, err
+	}
+	response.document = document
+	return document, nil
+}
+
+func attribute(node *html.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if strings.EqualFold(attr.Key, name) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func jsonValueToString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
@@ -0,0 +1,73 @@
+package goverrun
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const extractTestHTML = `<html><body>
+<form action="/login" method="post">
+  <input type="hidden" name="csrf" value="t&amp;ken-123">
+  <input type="text" name="user">
+</form>
+<div class="greeting">  Hello <b>Alice</b> </div>
+</body></html>`
+
+func TestExtractors(t *testing.T) {
+	user := &User{CurrentUser: 1, CurrentLoop: 1, Data: map[string]interface{}{"stale": "old"}}
+	header := http.Header{}
+	header.Set("Location", "/next")
+	header.Add("Set-Cookie", "session=s3cr3t; Path=/")
+	response := &Response{Step: user.Step("extract"), Body: []byte(extractTestHTML), Header: header}
+	response.Extract(
+		ExtractRegex("regex", regexp.MustCompile(`name="csrf" value="([^"]+)"`)),
+		MustExtractCSS("css", "div.greeting"),
+		MustExtractCSS("cssAttr", "input[name=csrf]").Attr("value"),
+		MustExtractXPath("xpath", "//input[@name='csrf']/@value"),
+		ExtractHeader("header", "location"),
+		ExtractCookie("cookie", "session"),
+		MustExtractCSS("fallback", "#missing").Default("none"),
+		ExtractRegex("stale", regexp.MustCompile(`missing-(\d+)`)),
+	)
+	want := map[string]string{
+		"regex":    "t&ken-123",
+		"css":      "Hello Alice",
+		"cssAttr":  "t&ken-123",
+		"xpath":    "t&ken-123",
+		"header":   "/next",
+		"cookie":   "s3cr3t",
+		"fallback": "none",
+	}
+	for key, value := range want {
+		if user.Data[key] != value {
+			t.Errorf("%s: got %q want %q", key, user.Data[key], value)
+		}
+	}
+	if _, exists := user.Data["stale"]; exists {
+		t.Error("expected stale value to be removed after failed extraction")
+	}
+	if response.IsFailed() {
+		t.Errorf("unexpected failure: %s", response.AssertionFailed)
+	}
+
+	jsonResponse := &Response{Step: user.Step("extract json"), Body: []byte(`{"data": {"token": "abc", "id": 42, "tags": ["a", 1]}}`)}
+	jsonResponse.Extract(ExtractJSONPath("token", "$.data.token"), ExtractJSONPath("id", "$.data.id"))
+	if user.Data["token"] != "abc" || user.Data["id"] != "42" {
+		t.Errorf("unexpected JSON extraction: %v %v", user.Data["token"], user.Data["id"])
+	}
+	if tags := jsonResponse.ExtractSliceFromJSON("$.data.tags"); strings.Join(tags, ",") != "a,1" {
+		t.Errorf("unexpected JSON slice extraction: %v", tags)
+	}
+	jsonResponse.Extract(ExtractJSONPath("missing", "$.data.missing").Required(), ExtractHeader("other", "X-Other").Required())
+	if !strings.HasPrefix(jsonResponse.AssertionFailed, "required extraction failed: jsonpath '$.data.missing' into 'missing'") {
+		t.Errorf("unexpected root cause of failed required extraction: %s", jsonResponse.AssertionFailed)
+	}
+	if _, err := ExtractCSS("invalid", "input[name="); err == nil || !strings.HasPrefix(err.Error(), "invalid CSS selector 'input[name=':") {
+		t.Errorf("expected invalid selector error, got %v", err)
+	}
+	if _, err := ExtractXPath("invalid", "//input[@name='csrf'"); err == nil || !strings.HasPrefix(err.Error(), "invalid XPath expression '//input[@name='csrf'':") {
+		t.Errorf("expected invalid expression error, got %v", err)
+	}
+}
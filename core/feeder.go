@@ -0,0 +1,260 @@
+package goverrun
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+)
+
+// FeederStrategy defines how the rows of a Feeder are handed out to the looping users.
+type FeederStrategy int
+
+const (
+	// FeedSequential lets every user walk through the rows in file order (one row per loop),
+	// starting over with the first row after the last one.
+	FeedSequential FeederStrategy = iota
+	// FeedRandom hands out a randomly chosen row on every loop.
+	FeedRandom
+	// FeedUniquePerUser binds one row exclusively to each user for the whole run.
+	// Users for which no unused row is left are stopped.
+	FeedUniquePerUser
+	// FeedCircular hands out the rows in file order shared across all users (one row per loop),
+	// starting over with the first row after the last one.
+	FeedCircular
+	// FeedStopWhenExhausted hands out the rows in file order shared across all users (one row per loop),
+	// each row only once. Users requesting a row after the last one has been handed out are stopped.
+	FeedStopWhenExhausted
+)
+
+func (strategy FeederStrategy) String() string {
+	switch strategy {
+	case FeedSequential:
+		return "sequential"
+	case FeedRandom:
+		return "random"
+	case FeedUniquePerUser:
+		return "unique-per-user"
+	case FeedCircular:
+		return "circular"
+	case FeedStopWhenExhausted:
+		return "stop-when-exhausted"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(strategy))
+	}
+}
+
+// Feeder provides test data rows (column name to value) to the looping users.
+// It is safe to use concurrently from all users of all scenarios.
+type Feeder struct {
+	Strategy FeederStrategy
+
+	lock        sync.Mutex
+	rows        []map[string]string
+	cursor      int
+	rowsByUser  map[string]int
+	name        string
+	exhaustions int
+}
+
+type fedRow struct {
+	loop, seq int
+	row       map[string]string
+	ok        bool
+}
+
+// NewFeeder creates a feeder handing out the given rows with the given strategy.
+func NewFeeder(rows []map[string]string, strategy FeederStrategy) *Feeder {
+	return &Feeder{
+		Strategy:   strategy,
+		rows:       rows,
+		rowsByUser: make(map[string]int),
+		name:       "in-memory feeder",
+	}
+}
+
+// NewCSVFeeder creates a feeder from the given CSV file. The first record is used as header (i.e. the column names).
+func NewCSVFeeder(filename string, strategy FeederStrategy) (*Feeder, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	feeder, err := NewCSVFeederFromReader(f, strategy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse CSV feeder file '%s': %w", filename, err)
+	}
+	feeder.name = filename
+	return feeder, nil
+}
+
+// NewCSVFeederFromReader creates a feeder from the given CSV content. The first record is used as header (i.e. the column names).
+func NewCSVFeederFromReader(r io.Reader, strategy FeederStrategy) (*Feeder, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing CSV header")
+	}
+	header := records[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return NewFeeder(rows, strategy), nil
+}
+
+// NewJSONLinesFeeder creates a feeder from the given JSON Lines file (i.e. one JSON object per line).
+// Non-string values are converted to their JSON text representation.
+func NewJSONLinesFeeder(filename string, strategy FeederStrategy) (*Feeder, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	feeder, err := NewJSONLinesFeederFromReader(f, strategy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse JSON Lines feeder file '%s': %w", filename, err)
+	}
+	feeder.name = filename
+	return feeder, nil
+}
+
+// NewJSONLinesFeederFromReader creates a feeder from the given JSON Lines content (i.e. one JSON object per line).
+// Non-string values are converted to their JSON text representation.
+func NewJSONLinesFeederFromReader(r io.Reader, strategy FeederStrategy) (*Feeder, error) {
+	dec := json.NewDecoder(r)
+	var rows []map[string]string
+	for line := 1; ; line++ {
+		var object map[string]json.RawMessage
+		if err := dec.Decode(&object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("object %d: %w", line, err)
+		}
+		row := make(map[string]string, len(object))
+		for k, v := range object {
+			var s string
+			if err := json.Unmarshal(v, &s); err == nil {
+				row[k] = s
+			} else {
+				row[k] = string(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return NewFeeder(rows, strategy), nil
+}
+
+// Partition reduces the feeder to the rows belonging to the given worker (zero-based index) of all workers,
+// so that distributed load generators don't use the same rows. Rows are assigned round-robin to the workers.
+func (feeder *Feeder) Partition(workerIndex, workerCount int) *Feeder {
+	if workerCount <= 0 || workerIndex < 0 || workerIndex >= workerCount {
+		panic(fmt.Sprintf("invalid feeder partition: worker index %d of %d workers", workerIndex, workerCount))
+	}
+	feeder.lock.Lock()
+	defer feeder.lock.Unlock()
+	partitioned := make([]map[string]string, 0, len(feeder.rows)/workerCount+1)
+	for i := workerIndex; i < len(feeder.rows); i += workerCount {
+		partitioned = append(partitioned, feeder.rows[i])
+	}
+	feeder.rows = partitioned
+	feeder.cursor = 0
+	feeder.rowsByUser = make(map[string]int)
+	return feeder
+}
+
+// Len returns the number of rows of the feeder.
+func (feeder *Feeder) Len() int {
+	feeder.lock.Lock()
+	defer feeder.lock.Unlock()
+	return len(feeder.rows)
+}
+
+// Exhaustions returns how often users have been stopped because no row was left for them.
+func (feeder *Feeder) Exhaustions() int {
+	feeder.lock.Lock()
+	defer feeder.lock.Unlock()
+	return feeder.exhaustions
+}
+
+func (feeder *Feeder) next(user *User) (map[string]string, bool) {
+	feeder.lock.Lock()
+	defer feeder.lock.Unlock()
+	if len(feeder.rows) == 0 {
+		feeder.exhaustions++
+		return nil, false
+	}
+	switch feeder.Strategy {
+	case FeedSequential:
+		return feeder.rows[(user.CurrentLoop-1+len(feeder.rows))%len(feeder.rows)], true
+	case FeedRandom:
+		return feeder.rows[rand.Intn(len(feeder.rows))], true
+	case FeedUniquePerUser:
+		key := user.Scenario + "/" + fmt.Sprint(user.CurrentUser)
+		if i, exists := feeder.rowsByUser[key]; exists {
+			return feeder.rows[i], true
+		}
+		if feeder.cursor >= len(feeder.rows) {
+			feeder.exhaustions++
+			return nil, false
+		}
+		feeder.rowsByUser[key] = feeder.cursor
+		feeder.cursor++
+		return feeder.rows[feeder.cursor-1], true
+	case FeedCircular:
+		row := feeder.rows[feeder.cursor%len(feeder.rows)]
+		feeder.cursor = (feeder.cursor + 1) % len(feeder.rows)
+		return row, true
+	case FeedStopWhenExhausted:
+		if feeder.cursor >= len(feeder.rows) {
+			feeder.exhaustions++
+			return nil, false
+		}
+		feeder.cursor++
+		return feeder.rows[feeder.cursor-1], true
+	default:
+		panic(fmt.Sprint("unknown feeder strategy: ", feeder.Strategy))
+	}
+}
+
+// Feed returns the row of the given feeder for the current loop of the user. Feeding the same feeder again
+// within the same loop returns the same row. When the feeder has no row left for the user (depending on the
+// feeder strategy) the user gets disabled (i.e. all further steps are skipped and the user stops looping)
+// and ok is false.
+func (user *User) Feed(feeder *Feeder) (row map[string]string, ok bool) {
+	if user.Disabled {
+		return nil, false
+	}
+	if user.fed == nil {
+		user.fed = make(map[*Feeder]fedRow)
+	}
+	if fed, exists := user.fed[feeder]; exists && fed.loop == user.CurrentLoop {
+		return fed.row, fed.ok
+	}
+	row, ok = feeder.next(user)
+	user.feedSeq++
+	user.fed[feeder] = fedRow{loop: user.CurrentLoop, seq: user.feedSeq, row: row, ok: ok}
+	if !ok {
+		user.Disabled = true
+		if verbose {
+			LogInfof("[%d:%d] Stopping user as no rows are left in feeder: %s\n", user.CurrentUser, user.CurrentLoop, feeder.name)
+		}
+	}
+	return row, ok
+}
@@ -0,0 +1,107 @@
+package goverrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const feederTestCSV = `username, password
+alice,secret-a
+bob,secret-b
+carol,secret-c
+`
+
+func TestCSVFeederStrategies(t *testing.T) {
+	tests := []struct {
+		strategy FeederStrategy
+		users    int
+		loops    int
+		want     []string // usernames in order of feeding (user by user, loop by loop)
+	}{
+		{FeedSequential, 2, 4, []string{"alice", "bob", "carol", "alice", "alice", "bob", "carol", "alice"}},
+		{FeedCircular, 2, 2, []string{"alice", "bob", "carol", "alice"}},
+		{FeedUniquePerUser, 2, 2, []string{"alice", "alice", "bob", "bob"}},
+		{FeedStopWhenExhausted, 2, 2, []string{"alice", "bob", "carol", ""}},
+	}
+	for _, test := range tests {
+		feeder, err := NewCSVFeederFromReader(strings.NewReader(feederTestCSV), test.strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for u := 1; u <= test.users; u++ {
+			user := &User{Scenario: "Feeder Test", CurrentUser: u}
+			for l := 1; l <= test.loops; l++ {
+				user.CurrentLoop = l
+				row, ok := user.Feed(feeder)
+				if again, _ := user.Feed(feeder); again["username"] != row["username"] {
+					t.Errorf("%s: feeding twice in the same loop returned different rows", test.strategy)
+				}
+				if ok && row["password"] != "secret-"+row["username"][:1] {
+					t.Errorf("%s: row columns mixed up: %v", test.strategy, row)
+				}
+				got = append(got, row["username"])
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: got %v want %v", test.strategy, got, test.want)
+		}
+	}
+}
+
+func TestFeederExhaustionDisablesUser(t *testing.T) {
+	feeder := NewFeeder([]map[string]string{{"id": "1"}}, FeedStopWhenExhausted)
+	first, second := &User{CurrentUser: 1, CurrentLoop: 1}, &User{CurrentUser: 2, CurrentLoop: 1}
+	if _, ok := first.Feed(feeder); !ok || first.Disabled {
+		t.Error("expected first user to get the only row")
+	}
+	if _, ok := second.Feed(feeder); ok || !second.Disabled {
+		t.Error("expected second user to be disabled on exhausted feeder")
+	}
+	response := second.Step("disabled").Request("GET", "http://127.0.0.1:1").SendWithoutTimeout()
+	if response.Error != nil || !response.archived {
+		t.Error("expected request of disabled user to be skipped")
+	}
+}
+
+func TestJSONLinesFeederConcurrentPartitioned(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		sb.WriteString(fmt.Sprintf(`{"id": %d, "name": "n"}`+"\n", i))
+	}
+	feeder, err := NewJSONLinesFeederFromReader(strings.NewReader(sb.String()), FeedStopWhenExhausted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feeder.Partition(1, 4)
+	if feeder.Len() != 25 {
+		t.Fatalf("got %d rows in partition want 25", feeder.Len())
+	}
+	var (
+		wg    sync.WaitGroup
+		lock  sync.Mutex
+		count int
+	)
+	for u := 1; u <= 50; u++ {
+		wg.Add(1)
+		go func(u int) {
+			defer wg.Done()
+			user := &User{CurrentUser: u, CurrentLoop: 1}
+			if row, ok := user.Feed(feeder); ok {
+				if id, _ := strconv.Atoi(row["id"]); id%4 != 1 || row["name"] != "n" {
+					t.Errorf("unexpected row in partition: %v", row)
+				}
+				lock.Lock()
+				count++
+				lock.Unlock()
+			}
+		}(u)
+	}
+	wg.Wait()
+	if count != 25 || feeder.Exhaustions() != 25 {
+		t.Errorf("got %d fed rows and %d exhaustions want 25 each", count, feeder.Exhaustions())
+	}
+}
@@ -2,17 +2,22 @@ package goverrun
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/gob"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/PaesslerAG/gval"
 	"github.com/PaesslerAG/jsonpath"
+	xhtml "golang.org/x/net/html"
+	"google.golang.org/grpc/codes"
 	"html"
 	"io"
 	"log"
 	"math/rand"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
@@ -22,6 +27,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,12 +41,12 @@ const (
 )
 
 var (
-	// exported
-	AddUserLoopHeader         bool
-	AddScenarioStepHeader     bool
-	SkipCertificateValidation bool
-	Proxy                     string
-	UserAgent                 string
+	// exported (run-wide defaults, prefer the per-scenario Scenario.Transport)
+	AddUserLoopHeader         bool   // see TaggingConfig.UserLoopHeader
+	AddScenarioStepHeader     bool   // see TaggingConfig.ScenarioStepHeader
+	SkipCertificateValidation bool   // see TLSConfig.InsecureSkipVerify
+	Proxy                     string // see ProxyConfig.URL
+	UserAgent                 string // see TransportConfig.UserAgent
 
 	// internal
 	verbose              bool
@@ -64,28 +70,80 @@ func Reset() {
 	scenarios = make(map[string]*Scenario)
 	requestInterceptors = make([]func(u *User, r *http.Request), 0)
 	currentLoopingUsers = safeTracker{counters: make(map[string]int)}
+	openConnections = safeTracker{counters: make(map[string]int)}
+	localAddressRoundRobin = safeTracker{counters: make(map[string]int)}
+	dnsRoundRobin = safeTracker{counters: make(map[string]int)}
 	folder = ""
 	scenariosWriter = nil
 	stepHistogramWriters = make(map[string]*stepGobWriter)
+	explicitFlags = make(map[string]bool)
 }
 
 type CommandlineArguments struct {
 	Run struct {
-		LoopingUsers, RampUpSeconds, PlateauSeconds, RampDownSeconds *int
-		Folder                                                       *string
+		LoopingUsers, RampUpSeconds, PlateauSeconds, RampDownSeconds        *int
+		Folder                                                              *string
+		StartDelay, LoopDelay                                               *RandomInterval
+		ClearCookieJarOnEveryLoop                                           *bool
+		Proxy, UserAgent                                                    *string
+		SkipCertificateValidation, AddUserLoopHeader, AddScenarioStepHeader *bool
+		Verbose, Smoke                                                      *bool
+		Include, Exclude, Override                                          *[]string
 	}
 	Report struct {
 		Folder *string
 	}
+	Har struct {
+		Output, Package, Func, Hosts *string
+		IncludeStaticAssets          *bool
+		MinThinkTime, Timeout        *time.Duration
+	}
+	Record struct {
+		Listen, CADir, Format, Output, Steps, Hosts *string
+		IdleGap                                     *time.Duration
+		IncludeStaticAssets                         *bool
+	}
+	OpenAPI struct {
+		Output, Package, Func *string
+		Timeout               *time.Duration
+	}
+	Export struct {
+		Folder, Format, Output *string
+	}
+	Compare struct {
+		MaxLatencyIncrease, MaxSuccessDrop *float64
+	}
 	SubcommandArgs []string
 }
 
+// exit codes of the subcommands
+const (
+	ExitOK               = 0
+	ExitError            = 1 // like invalid plans or unreadable files
+	ExitUsage            = 2 // invalid subcommand or flags
+	ExitUnmetExpectation = 3 // unmet expectations of a run, failed smoke runs or regressions of a comparison
+)
+
+// ErrUnknownSubcommand is returned by ParseCommandline for missing or unknown subcommands.
+var ErrUnknownSubcommand = errors.New("missing or unknown subcommand")
+
 var (
-	SubcommandReport *flag.FlagSet
-	SubcommandRun    *flag.FlagSet
-	CommandlineArgs  = &CommandlineArguments{}
+	SubcommandReport   *flag.FlagSet
+	SubcommandRun      *flag.FlagSet
+	SubcommandValidate *flag.FlagSet
+	SubcommandExport   *flag.FlagSet
+	SubcommandCompare  *flag.FlagSet
+	SubcommandHar      *flag.FlagSet
+	SubcommandRecord   *flag.FlagSet
+	SubcommandOpenAPI  *flag.FlagSet
+	CommandlineArgs    = &CommandlineArguments{}
+
+	subcommandDescriptions = make(map[string]string)
 )
 
+// CommandlineDefaults prints the banner, defines the subcommands with the given defaults (see DefineCommandlineFlags)
+// and parses them from os.Args (see ParseCommandline). Invalid arguments exit with ExitUsage, -h with ExitOK.
+// Flags can also be set via environment variables (see EnvironmentVariable), given flags take precedence.
 func CommandlineDefaults(users, RampUpSeconds, plateauSeconds, rampDownSeconds int, reportPath string) {
 	fmt.Println(`
    ______                                    
@@ -96,58 +154,211 @@ func CommandlineDefaults(users, RampUpSeconds, plateauSeconds, rampDownSeconds i
 Agile Load Testing - https://goverrun.io`)
 	fmt.Println()
 
-	SubcommandRun = flag.NewFlagSet("run", flag.ExitOnError)
+	DefineCommandlineFlags(users, RampUpSeconds, plateauSeconds, rampDownSeconds, reportPath, flag.ExitOnError)
+	err := ParseCommandline(os.Args[1:])
+	switch {
+	case err == flag.ErrHelp:
+		os.Exit(ExitOK)
+	case errors.Is(err, ErrUnknownSubcommand):
+		PrintMissingSubcommandAndExit(Subcommands()...)
+	case err != nil:
+		LogError(err)
+		os.Exit(ExitUsage)
+	}
+}
+
+// DefineCommandlineFlags (re)defines the subcommands and their flags (stored into CommandlineArgs when parsed)
+// with the given defaults, independent of os.Args (see ParseCommandline).
+func DefineCommandlineFlags(users, RampUpSeconds, plateauSeconds, rampDownSeconds int, reportPath string, errorHandling flag.ErrorHandling) {
+	SubcommandRun = flag.NewFlagSet("run", errorHandling)
 	SubcommandRun.SetOutput(os.Stdout)
 	CommandlineArgs.Run.LoopingUsers = SubcommandRun.Int("users", users, "number of looping users")
 	CommandlineArgs.Run.RampUpSeconds = SubcommandRun.Int("ramp-up", RampUpSeconds, "ramp-up duration in seconds")
 	CommandlineArgs.Run.PlateauSeconds = SubcommandRun.Int("plateau", plateauSeconds, "plateau duration in seconds")
 	CommandlineArgs.Run.RampDownSeconds = SubcommandRun.Int("ramp-down", rampDownSeconds, "ramp-down duration in seconds")
 	CommandlineArgs.Run.Folder = SubcommandRun.String("path", reportPath, "report output folder")
+	CommandlineArgs.Run.StartDelay = intervalVar(SubcommandRun, "start-delay", "delay `interval` before a scenario starts, fixed like 5s or random like 1s-10s")
+	CommandlineArgs.Run.LoopDelay = intervalVar(SubcommandRun, "loop-delay", "delay `interval` between the loops of a user, fixed like 1s or random like 1s-3s")
+	CommandlineArgs.Run.ClearCookieJarOnEveryLoop = SubcommandRun.Bool("clear-cookies", true, "clear the cookie jar (and HTTP cache) of a user on every loop")
+	CommandlineArgs.Run.Proxy = SubcommandRun.String("proxy", "", "proxy URL of all requests, overriding the proxies of the code")
+	CommandlineArgs.Run.SkipCertificateValidation = SubcommandRun.Bool("insecure", false, "skip the validation of server certificates")
+	CommandlineArgs.Run.UserAgent = SubcommandRun.String("user-agent", "", "user agent of all requests, overriding the user agents of the code (default: the ones of the code, otherwise random ones)")
+	CommandlineArgs.Run.Verbose = SubcommandRun.Bool("verbose", false, "log details like every request")
+	CommandlineArgs.Run.Smoke = SubcommandRun.Bool("smoke", false, "run every scenario once with a single user dumping all requests and responses (without report)")
+	CommandlineArgs.Run.AddUserLoopHeader = SubcommandRun.Bool("tag-user-loop", false, "tag requests with a header of the current user and loop")
+	CommandlineArgs.Run.AddScenarioStepHeader = SubcommandRun.Bool("tag-scenario-step", false, "tag requests with a header of the current scenario and step")
+	CommandlineArgs.Run.Include = listVar(SubcommandRun, "include", "comma-separated `titles` (or patterns like 'checkout*') of the only scenarios to run, repeatable")
+	CommandlineArgs.Run.Exclude = listVar(SubcommandRun, "exclude", "comma-separated `titles` (or patterns) of scenarios not to run, repeatable")
+	CommandlineArgs.Run.Override = listVar(SubcommandRun, "override", "comma-separated load `overrides` of scenarios like 'checkout:users=5,*:loop-delay=1s-2s', repeatable\n"+
+		"(keys: "+strings.Join(loadOverrideKeys, ", ")+")")
 	// use the Base-URL as last argument
 
-	SubcommandReport = flag.NewFlagSet("report", flag.ExitOnError)
+	SubcommandReport = flag.NewFlagSet("report", errorHandling)
 	SubcommandReport.SetOutput(os.Stdout)
 	CommandlineArgs.Report.Folder = SubcommandReport.String("path", reportPath, "report input folder")
 
-	// Verify that a subcommand has been provided
-	// os.Arg[0] is the main command
-	// os.Arg[1] will be the subcommand
-	if len(os.Args) < 2 {
-		PrintMissingSubcommandAndExit(SubcommandRun, SubcommandReport)
-	}
-
-	switch os.Args[1] {
-	case SubcommandRun.Name():
-		err := SubcommandRun.Parse(os.Args[2:])
-		panicOnErr(err)
-		CommandlineArgs.SubcommandArgs = SubcommandRun.Args()
-		/* left to be decided by the caller
-		if len(CommandlineArgs.SubcommandArgs) == 0 {
-			LogFatal("Missing required subcommand target (use as argument at the end)")
-			os.Exit(1)
+	SubcommandHar = flag.NewFlagSet("har", errorHandling)
+	SubcommandHar.SetOutput(os.Stdout)
+	CommandlineArgs.Har.Output = SubcommandHar.String("out", "scenario.go", "generated Go source file")
+	CommandlineArgs.Har.Package = SubcommandHar.String("package", "main", "package of the generated code")
+	CommandlineArgs.Har.Func = SubcommandHar.String("func", "recordedScenario", "name of the generated scenario runner function")
+	CommandlineArgs.Har.Hosts = SubcommandHar.String("hosts", "", "comma-separated hosts to keep requests of (default: host of the first request)")
+	CommandlineArgs.Har.IncludeStaticAssets = SubcommandHar.Bool("include-assets", false, "keep requests of static assets (images, stylesheets, scripts and fonts)")
+	CommandlineArgs.Har.MinThinkTime = SubcommandHar.Duration("min-think-time", 500*time.Millisecond, "shortest recorded gap generated as think time")
+	CommandlineArgs.Har.Timeout = SubcommandHar.Duration("timeout", 10*time.Second, "timeout of every generated request")
+	// use the HAR file as last argument
+
+	SubcommandRecord = flag.NewFlagSet("record", errorHandling)
+	SubcommandRecord.SetOutput(os.Stdout)
+	CommandlineArgs.Record.Listen = SubcommandRecord.String("listen", "127.0.0.1:8888", "listen address of the recording proxy")
+	CommandlineArgs.Record.CADir = SubcommandRecord.String("ca-dir", defaultCADir(), "directory of the CA certificate for HTTPS interception (generated on first use)")
+	CommandlineArgs.Record.Format = SubcommandRecord.String("format", "code", "output format: code (scenario code) or raw (raw request files)")
+	CommandlineArgs.Record.Output = SubcommandRecord.String("out", "scenario.go", "generated Go source file (format code) or directory (format raw)")
+	CommandlineArgs.Record.Steps = SubcommandRecord.String("steps", "", "comma-separated step name rules name=pattern matched against method and URL (default: grouping by idle gaps)")
+	CommandlineArgs.Record.IdleGap = SubcommandRecord.Duration("idle-gap", 2*time.Second, "gap without requests starting a new step")
+	CommandlineArgs.Record.Hosts = SubcommandRecord.String("hosts", "", "comma-separated hosts to keep requests of in the scenario code (default: host of the first request)")
+	CommandlineArgs.Record.IncludeStaticAssets = SubcommandRecord.Bool("include-assets", false, "keep requests of static assets in the scenario code")
+
+	SubcommandOpenAPI = flag.NewFlagSet("openapi", errorHandling)
+	SubcommandOpenAPI.SetOutput(os.Stdout)
+	CommandlineArgs.OpenAPI.Output = SubcommandOpenAPI.String("out", "scenario.go", "generated Go source file")
+	CommandlineArgs.OpenAPI.Package = SubcommandOpenAPI.String("package", "main", "package of the generated code")
+	CommandlineArgs.OpenAPI.Func = SubcommandOpenAPI.String("func", "apiScenario", "name of the generated scenario runner function")
+	CommandlineArgs.OpenAPI.Timeout = SubcommandOpenAPI.Duration("timeout", 10*time.Second, "timeout of every generated request")
+	// use the OpenAPI spec file (YAML or JSON) as last argument
+
+	SubcommandValidate = flag.NewFlagSet("validate", errorHandling)
+	SubcommandValidate.SetOutput(os.Stdout)
+	// use the plan files as last arguments
+
+	SubcommandExport = flag.NewFlagSet("export", errorHandling)
+	SubcommandExport.SetOutput(os.Stdout)
+	CommandlineArgs.Export.Folder = SubcommandExport.String("path", reportPath, "report input folder (analyzed first when not yet reported)")
+	CommandlineArgs.Export.Format = SubcommandExport.String("format", "csv", "output format: csv or json")
+	CommandlineArgs.Export.Output = SubcommandExport.String("out", "", "output file (default: results.<format> in the report folder)")
+
+	SubcommandCompare = flag.NewFlagSet("compare", errorHandling)
+	SubcommandCompare.SetOutput(os.Stdout)
+	CommandlineArgs.Compare.MaxLatencyIncrease = SubcommandCompare.Float64("max-latency-increase", 10, "largest allowed increase of the 95th percentile of the total request-response time in percent")
+	CommandlineArgs.Compare.MaxSuccessDrop = SubcommandCompare.Float64("max-success-drop", 1, "largest allowed drop of the success percentage in percentage points")
+	// use the baseline and the current report folder as last arguments
+
+	SetSubcommandUsage(SubcommandRun, "[target]", "runs the scenarios and reports the results")
+	SetSubcommandUsage(SubcommandReport, "", "reports the results of a run (merging distributed results placed as subfolders)")
+	SetSubcommandUsage(SubcommandValidate, "<plan file>...", "validates plan files (YAML or JSON) without running them")
+	SetSubcommandUsage(SubcommandExport, "", "exports the summary of every step of reported results as CSV or JSON")
+	SetSubcommandUsage(SubcommandCompare, "<baseline folder> <current folder>", "compares the results of two runs step by step and fails on regressions")
+	SetSubcommandUsage(SubcommandHar, "<HAR file>", "generates scenario code from a HAR recording")
+	SetSubcommandUsage(SubcommandRecord, "", "records browser traffic via a proxy and generates scenario code")
+	SetSubcommandUsage(SubcommandOpenAPI, "<OpenAPI spec file>", "generates a skeleton scenario from an OpenAPI 3 spec")
+}
+
+// Subcommands returns the defined subcommands (see DefineCommandlineFlags).
+func Subcommands() []*flag.FlagSet {
+	return []*flag.FlagSet{SubcommandRun, SubcommandReport, SubcommandValidate, SubcommandExport, SubcommandCompare, SubcommandHar, SubcommandRecord, SubcommandOpenAPI}
+}
+
+// SetSubcommandUsage sets the -h output of the subcommand (like a custom description of the run target).
+func SetSubcommandUsage(subcommand *flag.FlagSet, arguments, description string) {
+	subcommandDescriptions[subcommand.Name()] = description
+	subcommand.Usage = func() {
+		w := subcommand.Output()
+		_, _ = fmt.Fprintf(w, "Usage: %s %s [flags] %s\n\n%s\n\nFlags (also settable as environment variables like %s for -path, given flags take precedence):\n",
+			filepath.Base(os.Args[0]), subcommand.Name(), arguments, description, EnvironmentVariable("path"))
+		subcommand.PrintDefaults()
+	}
+}
+
+// PrintCommandlineUsage prints the usage overview of all subcommands.
+func PrintCommandlineUsage() {
+	fmt.Printf("Usage: %s <subcommand> [flags] [arguments]\n\nSubcommands:\n", filepath.Base(os.Args[0]))
+	for _, subcommand := range Subcommands() {
+		fmt.Printf("  %-10s %s\n", subcommand.Name(), subcommandDescriptions[subcommand.Name()])
+	}
+	fmt.Printf("\nUse '%s <subcommand> -h' for the flags of a subcommand.\n", filepath.Base(os.Args[0]))
+}
+
+// ParseCommandline parses the subcommand with its flags from the arguments (without the program name) into
+// CommandlineArgs, the subcommands must be defined before (see DefineCommandlineFlags). A missing or unknown
+// subcommand returns ErrUnknownSubcommand, -h or help print the usage and return flag.ErrHelp.
+// Flags not given are taken from their environment variables (see EnvironmentVariable) when set.
+func ParseCommandline(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: none given", ErrUnknownSubcommand)
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		PrintCommandlineUsage()
+		return flag.ErrHelp
+	}
+	for _, subcommand := range Subcommands() {
+		if subcommand.Name() != args[0] {
+			continue
 		}
-		*/
-	case SubcommandReport.Name():
-		err := SubcommandReport.Parse(os.Args[2:])
-		panicOnErr(err)
-		CommandlineArgs.SubcommandArgs = SubcommandReport.Args()
-	default:
-		PrintMissingSubcommandAndExit(SubcommandRun, SubcommandReport)
+		explicitFlags = make(map[string]bool)
+		if err := setFlagsFromEnvironment(subcommand); err != nil {
+			return err
+		}
+		if err := subcommand.Parse(args[1:]); err != nil {
+			return err
+		}
+		subcommand.Visit(func(f *flag.Flag) {
+			explicitFlags[f.Name] = true
+		})
+		CommandlineArgs.SubcommandArgs = subcommand.Args()
+		return nil
 	}
+	return fmt.Errorf("%w: %s", ErrUnknownSubcommand, args[0])
 }
 
 func RunFromCommandlineArgs() {
+	if SubcommandHar.Parsed() {
+		generateScenarioFromHARArgs()
+		return
+	}
+	if SubcommandRecord.Parsed() {
+		recordFromArgs()
+		return
+	}
+	if SubcommandOpenAPI.Parsed() {
+		generateScenarioFromOpenAPIArgs()
+		return
+	}
+	if SubcommandValidate.Parsed() {
+		validatePlansFromArgs()
+		return
+	}
+	if SubcommandExport.Parsed() {
+		exportResultsFromArgs()
+		return
+	}
+	if SubcommandCompare.Parsed() {
+		compareResultsFromArgs()
+		return
+	}
 	var reportPath string
 	if SubcommandRun.Parsed() {
+		if err := applyRunArgs(); err != nil {
+			LogError(err)
+			os.Exit(ExitUsage)
+		}
+		if *CommandlineArgs.Run.Smoke {
+			if RunSmoke() {
+				LogWarning("Smoke test failed")
+				os.Exit(ExitUnmetExpectation)
+			}
+			LogSuccess("Smoke test passed")
+			return
+		}
 		reportPath = *CommandlineArgs.Run.Folder
-		Run(reportPath, verbose)
+		Run(reportPath, *CommandlineArgs.Run.Verbose)
 	} else if SubcommandReport.Parsed() {
-		reportPath = *CommandlineArgs.Run.Folder
+		reportPath = *CommandlineArgs.Report.Folder
 	}
 	unmetExpectation := GenerateResultsReport(reportPath)
 	if unmetExpectation {
 		LogWarning("Unmet expectation")
-		os.Exit(3)
+		os.Exit(ExitUnmetExpectation)
 	}
 }
 
@@ -163,9 +374,11 @@ type Expectation struct {
 	TotalRequestResponseTimePercentileLimits []*PercentileExpectation
 	TimeToFirstBytePercentileLimits          []*PercentileExpectation
 	TimeAfterRequestSentPercentileLimits     []*PercentileExpectation
+	PageLoadTimePercentileLimits             []*PercentileExpectation
 	TotalRequestBytesWithin                  *RangeExpectation
 	TotalResponseBytesWithin                 *RangeExpectation
 	StatusCodeThresholds                     []*StatusCodeExpectation
+	GRPCCodeThresholds                       []*StatusCodeExpectation
 	FailureTypeMatchesThresholds             []*TypeMatchesThreshold
 	ErrorTypeMatchesThresholds               []*TypeMatchesThreshold
 	TimeoutTypeMatchesThresholds             []*TypeMatchesThreshold
@@ -212,21 +425,35 @@ type PercentileExpectation struct {
 	ActualValue time.Duration
 }
 
+// AddRequestInterceptor registers a function called with every request before it is sent. The interceptors are
+// never called concurrently for the same user (also not for its parallel fetches of embedded resources).
 func AddRequestInterceptor(fn func(u *User, r *http.Request)) {
 	requestInterceptors = append(requestInterceptors, fn)
 }
 
 type Counts struct {
-	Requests uint64
-	Timeouts uint64
-	Failures uint64
-	Errors   uint64
+	Requests           uint64
+	Timeouts           uint64
+	Failures           uint64
+	Errors             uint64
+	CacheHits          uint64
+	CacheRevalidations uint64
 }
 
 func (c Counts) Successes() uint64 {
 	return c.Requests - c.Failures - c.Errors - c.Timeouts
 }
 
+func (c Counts) add(other Counts) Counts {
+	c.Requests += other.Requests
+	c.Timeouts += other.Timeouts
+	c.Failures += other.Failures
+	c.Errors += other.Errors
+	c.CacheHits += other.CacheHits
+	c.CacheRevalidations += other.CacheRevalidations
+	return c
+}
+
 func (c Counts) SuccessPercentage() float64 {
 	return float64(c.Successes()) / float64(c.Requests) * 100
 }
@@ -254,6 +481,13 @@ type User struct {
 	HttpClient               *http.Client
 	Disabled                 bool
 	Data                     map[string]interface{} // intended to set custom values
+	// internal
+	fed            map[*Feeder]fedRow
+	feedSeq        int
+	tagging        TaggingConfig
+	webSockets     map[string]*WebSocket // open WebSocket connections by URL
+	sockets        map[string]*Socket    // open TCP and UDP connections by network://address
+	smokeResponses []*Response           // of all steps in the smoke mode (see trackSmoke)
 }
 
 func (user *User) printStep(step *Step) {
@@ -435,6 +669,22 @@ func (step *Step) ExpectTimeAfterRequestSentPercentileLimit(percentile float64,
 	return step
 }
 
+// ExpectPageLoadTimePercentileLimit sets the expectation of the page-load time (i.e. including all embedded resources,
+// see Request.FetchEmbeddedResources) for the given percentile is within the given maximum duration.
+// Percent values may range from 0.0 to 100.0 percent.
+//
+// When invoked multiple times, only the percentile expectation value when archiving the step's stats for the first time is used
+// (i.e. subsequent invocations post-archive are silently ignored).
+func (step *Step) ExpectPageLoadTimePercentileLimit(percentile float64, duration time.Duration) *Step {
+	if isValidPercentage(percentile) {
+		step.Expectation.PageLoadTimePercentileLimits = append(step.Expectation.PageLoadTimePercentileLimits, &PercentileExpectation{
+			Percentile: percentile,
+			Duration:   duration,
+		})
+	}
+	return step
+}
+
 // ExpectTotalRequestBytesWithin sets the expectation of the total request byte count for the given step.
 //
 // When invoked multiple times, only the expectation value when archiving the step's stats for the first time is used
@@ -577,6 +827,15 @@ type Request struct {
 	Timeout    time.Duration
 	Body       *io.Reader
 	Request    *http.Request
+	Templating bool
+	// EmbeddedResources is set to fetch the resources embedded in HTML responses (see FetchEmbeddedResources)
+	EmbeddedResources *EmbeddedResources
+	// Streaming is set to process the response body incrementally as a stream of events (see Stream)
+	Streaming *Streaming
+	// MultipartForm is set to send the form params as multipart/form-data (instead of URL-encoded, see SubmitForm)
+	MultipartForm bool
+	// repeatedFormParams are the further values of form params with multiple values (see AddFormParam)
+	repeatedFormParams url.Values
 }
 
 func (req *Request) SetBody(body *io.Reader) *Request {
@@ -600,17 +859,66 @@ func (req *Request) SetCookie(key, value string) *Request {
 	return req
 }
 
+// SetFormParam sets the form param, replacing any values of it.
 func (req *Request) SetFormParam(key, value string) *Request {
 	if req.FormParams == nil {
 		req.FormParams = make(map[string]string)
 	}
 	req.FormParams[key] = value
+	delete(req.repeatedFormParams, key)
 	if _, ok := req.Headers["Content-Type"]; !ok {
 		req.SetHeader("Content-Type", "application/x-www-form-urlencoded")
 	}
 	return req
 }
 
+// AddFormParam adds the value to the form param, keeping its existing values (like multiple selected checkboxes).
+// The first value is the one of FormParams.
+func (req *Request) AddFormParam(key, value string) *Request {
+	if _, exists := req.FormParams[key]; !exists {
+		return req.SetFormParam(key, value)
+	}
+	if req.repeatedFormParams == nil {
+		req.repeatedFormParams = url.Values{}
+	}
+	req.repeatedFormParams.Add(key, value)
+	if _, ok := req.Headers["Content-Type"]; !ok {
+		req.SetHeader("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return req
+}
+
+// formValues returns all values of the form params (the further values of params removed from FormParams are dropped).
+func (req *Request) formValues() url.Values {
+	values := make(url.Values, len(req.FormParams))
+	for key, value := range req.FormParams {
+		values[key] = append([]string{value}, req.repeatedFormParams[key]...)
+	}
+	return values
+}
+
+// encodeMultipartForm encodes the values as multipart/form-data body (sorted by key like url.Values.Encode).
+func encodeMultipartForm(values url.Values) (body []byte, contentType string, err error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range values[key] {
+			if err := writer.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
 func (req *Request) SendWithoutTimeout() *Response {
 	return sendRequest(req)
 }
@@ -621,18 +929,44 @@ func (req *Request) SendWithTimeout(timeout time.Duration) *Response {
 }
 
 func sendRequest(req *Request) *Response {
+	if req.Disabled {
+		// user got disabled (e.g. ramp-down or exhausted feeder): nothing is sent and nothing will be archived
+		return &Response{Timestamps: &Timestamps{}, archived: true}
+	}
+	if req.Templating {
+		expandRequest(req)
+	}
 	if !req.Raw {
 		var r *http.Request
 		var err error
-		if len(req.FormParams) > 0 {
+		if len(req.FormParams) > 0 && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+			// like browsers do: form params of GET (and HEAD) requests are sent as query params
+			r, err = http.NewRequest(req.Method, req.URL, nil)
+			if err == nil {
+				query := r.URL.Query()
+				for k, values := range req.formValues() {
+					query.Del(k)
+					for _, v := range values {
+						query.Add(k, v)
+					}
+				}
+				r.URL.RawQuery = query.Encode()
+				delete(req.Headers, "Content-Type")
+			}
+		} else if len(req.FormParams) > 0 {
 			if req.Body != nil {
 				LogWarning("Custom form post used but standard form params provided")
 			}
-			formParams := url.Values{}
-			for k, v := range req.FormParams {
-				formParams.Set(k, v)
+			if req.MultipartForm {
+				var body []byte
+				var contentType string
+				if body, contentType, err = encodeMultipartForm(req.formValues()); err == nil {
+					req.SetHeader("Content-Type", contentType)
+					r, err = http.NewRequest(req.Method, req.URL, bytes.NewReader(body))
+				}
+			} else {
+				r, err = http.NewRequest(req.Method, req.URL, strings.NewReader(req.formValues().Encode()))
 			}
-			r, err = http.NewRequest(req.Method, req.URL, strings.NewReader(formParams.Encode()))
 		} else {
 			if req.Body == nil {
 				r, err = http.NewRequest(req.Method, req.URL, nil)
@@ -642,8 +976,21 @@ func sendRequest(req *Request) *Response {
 		}
 		req.Request = r
 		CheckErrAndLogError(err, "unable to send request")
+		if err != nil {
+			return req.User.trackSmoke(&Response{
+				Scenario:   req.User.Scenario,
+				Step:       req.Step,
+				RequestURL: req.URL,
+				Timestamps: &Timestamps{},
+				Error:      err,
+			})
+		}
 	}
-	return req.User.executeRequestWithTracing(req)
+	rsp := req.User.executeRequestWithTracing(req)
+	if req.EmbeddedResources != nil {
+		req.User.fetchEmbeddedResources(req, rsp)
+	}
+	return rsp
 }
 
 func (step *Step) Request(method, url string) *Request {
@@ -713,25 +1060,22 @@ func addCookies(req *http.Request, reqCookies map[string]string) {
 func (user *User) executeRequestWithTracing(request *Request) *Response {
 	addHeaders(request.Request, request.Headers)
 	addCookies(request.Request, request.Cookies)
-	if AddScenarioStepHeader {
-		request.Request.Header.Set("Goverrun-Scenario-Step", user.Scenario+": "+request.Step.Name)
-	}
-	if AddUserLoopHeader {
-		request.Request.Header.Set("Goverrun-User-Loop", strconv.Itoa(user.CurrentUser)+"/"+strconv.Itoa(user.CurrentLoop))
-	}
+	user.addTaggingHeaders(request.Request, request.Step)
 	rsp := &Response{
-		Scenario:   user.Scenario,
-		Step:       request.Step,
-		RequestURL: request.Request.URL.String(),
-		Timestamps: &Timestamps{},
+		Scenario:      user.Scenario,
+		Step:          request.Step,
+		RequestMethod: request.Request.Method,
+		RequestURL:    request.Request.URL.String(),
+		Timestamps:    &Timestamps{},
 	}
+	user.trackSmoke(rsp)
 	trace := &httptrace.ClientTrace{
 		GotFirstResponseByte: rsp.gotFirstResponseByte,
 		WroteRequest:         rsp.wroteRequest,
+		DNSStart:             rsp.dnsStart,
+		DNSDone:              rsp.dnsDone,
+		GotConn:              rsp.gotConn,
 		/*
-			GotConn: rsp.gotConn,
-			DNSStart:             rsp.dnsStart,
-			DNSDone:              rsp.dnsDone,
 			TLSHandshakeStart:    rsp.tlsHandshakeStart,
 			TLSHandshakeDone:     rsp.tlsHandshakeDone,
 			ConnectStart:         rsp.connectStart,
@@ -739,40 +1083,26 @@ func (user *User) executeRequestWithTracing(request *Request) *Response {
 		*/
 	}
 
-	// call all registered request interceptors
-	for _, fn := range requestInterceptors {
-		fn(user, request.Request)
-	}
+	user.callRequestInterceptors(request.Request)
 
-	if verbose {
+	if verbose || smoke {
 		user.printStep(request.Step)
-		/*
-			dump, _ := httputil.DumpRequest(req, true)
-			fmt.Println(">>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>")
-			fmt.Println(string(dump))
-			fmt.Println("<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<")
-		*/
+	}
+	if smoke {
+		dumpSmokeRequest(request.Request)
 	}
 
+	outcome := &cacheOutcome{}
+	ctx := context.WithValue(httptrace.WithClientTrace(request.Request.Context(), trace), cacheOutcomeKey{}, outcome)
 	rsp.Timestamps.Start = time.Now()
 	user.HttpClient.Timeout = request.Timeout
-	responseOfCall, err := user.HttpClient.Do(request.Request.WithContext(httptrace.WithClientTrace(request.Request.Context(), trace)))
+	responseOfCall, err := user.HttpClient.Do(request.Request.WithContext(ctx))
 	rsp.Timestamps.Done = time.Now()
 	// https://stackoverflow.com/questions/48077098/getting-ttfb-time-to-first-byte-value-in-golang
 	// https://blog.golang.org/http-tracing
 	// https://github.com/davecheney/httpstat
 	if err != nil {
-		netErr, ok := err.(net.Error) // here "ok" is simply false when the type assertion failed (i.e. other type of error)
-		if ok && netErr.Timeout() && rsp.Error == nil {
-			rsp.Timeout = err
-		} else if rsp.Timeout == nil { // other type of error
-			rsp.Error = err
-			if verbose {
-				log.Println(">>>>>>>>>>>>>>>>>>>>>>")
-				log.Println(err)
-				log.Println("<<<<<<<<<<<<<<<<<<<<<<")
-			}
-		}
+		rsp.trackError(err)
 	}
 	var (
 		respBody   []byte
@@ -782,23 +1112,23 @@ func (user *User) executeRequestWithTracing(request *Request) *Response {
 	)
 	if responseOfCall != nil && responseOfCall.Body != nil {
 		defer responseOfCall.Body.Close()
-		respBody, err = io.ReadAll(responseOfCall.Body)
+		if request.Streaming != nil {
+			respBody, err = rsp.readStream(responseOfCall.Body, responseOfCall.Header.Get("Content-Type"), request.Streaming)
+			rsp.Timestamps.Done = time.Now() // the total duration of a stream includes reading it
+		} else {
+			respBody, err = io.ReadAll(responseOfCall.Body)
+		}
 		if err != nil {
-			netErr, ok := err.(net.Error) // here "ok" is simply false when the type assertion failed (i.e. other type of error)
-			if ok && netErr.Timeout() && rsp.Error == nil {
-				rsp.Timeout = err
-			} else if rsp.Timeout == nil { // other type of error
-				rsp.Error = err
-				if verbose {
-					log.Println(">>>>>>>>>>>>>>>>>>>>>>")
-					log.Println(err)
-					log.Println("<<<<<<<<<<<<<<<<<<<<<<")
-				}
-			}
+			rsp.trackError(err)
 		}
 		headerSize = HeaderSize(responseOfCall.Header)
+		rsp.Header = responseOfCall.Header
 		statusCode = responseOfCall.StatusCode
 		status = responseOfCall.Status
+		rsp.Protocol = responseOfCall.Proto
+		if smoke {
+			dumpSmokeResponse(responseOfCall, respBody)
+		}
 	}
 	rsp.StatusCode = statusCode
 	rsp.Status = status
@@ -808,6 +1138,8 @@ func (user *User) executeRequestWithTracing(request *Request) *Response {
 	rsp.Body = respBody
 	rsp.RequestSize = HeaderSize(request.Request.Header) + int(request.Request.ContentLength)
 	rsp.ResponseSize = headerSize + len(respBody)
+	rsp.trackCacheOutcome(outcome)
+	rsp.OpenConnections = openConnections.Value(user.Scenario)
 	/*
 		if detailsWriter != nil {
 			err := detailsWriter.writeArchiveEntry(rsp.archiveEntry())
@@ -821,36 +1153,82 @@ func (user *User) executeRequestWithTracing(request *Request) *Response {
 	return rsp
 }
 
+func (user *User) addTaggingHeaders(r *http.Request, step *Step) {
+	if AddScenarioStepHeader || user.tagging.ScenarioStepHeader {
+		r.Header.Set("Goverrun-Scenario-Step", user.Scenario+": "+step.Name)
+	}
+	if AddUserLoopHeader || user.tagging.UserLoopHeader {
+		r.Header.Set("Goverrun-User-Loop", strconv.Itoa(user.CurrentUser)+"/"+strconv.Itoa(user.CurrentLoop))
+	}
+}
+
+// callRequestInterceptors calls all registered request interceptors
+func (user *User) callRequestInterceptors(r *http.Request) {
+	for _, fn := range requestInterceptors {
+		fn(user, r)
+	}
+}
+
+// trackError tracks the error either as timeout or as other type of error (only the first one is tracked).
+func (response *Response) trackError(err error) {
+	netErr, ok := err.(net.Error) // here "ok" is simply false when the type assertion failed (i.e. other type of error)
+	if ok && netErr.Timeout() && response.Error == nil {
+		response.Timeout = err
+	} else if response.Timeout == nil { // other type of error
+		response.Error = err
+		if verbose {
+			log.Println(">>>>>>>>>>>>>>>>>>>>>>")
+			log.Println(err)
+			log.Println("<<<<<<<<<<<<<<<<<<<<<<")
+		}
+	}
+}
+
 type Timestamps struct {
 	Start                time.Time
 	WroteRequest         time.Time
 	GotFirstResponseByte time.Time
 	Done                 time.Time
+	PageLoadDone         time.Time // only set when embedded resources are fetched
+	DNSStart, DNSDone    time.Time // only set when a new connection required a DNS lookup
 	/*
 		GotConn     time.Time
 		ConnReused           bool
-		DNSStart, DNSDone                   time.Time
 		TLSHandshakeStart, TLSHandshakeDone time.Time
 		ConnectStart, ConnectDone           time.Time
 	*/
 }
 
 type Response struct {
-	Scenario        string
-	Step            *Step
-	RequestSize     int
-	ResponseSize    int
-	RequestURL      string
-	FinalURL        string
-	StatusCode      int
-	Status          string
-	Timestamps      *Timestamps
-	Timeout         error
-	Error           error
-	AssertionFailed string
-	Body            []byte
+	Scenario         string
+	Step             *Step
+	RequestSize      int
+	ResponseSize     int
+	RequestMethod    string
+	RequestURL       string
+	FinalURL         string
+	StatusCode       int
+	Status           string
+	GRPCCode         codes.Code // status code of gRPC calls (see Step.GRPC)
+	Protocol         string     // like HTTP/1.1, HTTP/2.0 or HTTP/3.0
+	LocalAddress     string     // local (source) IP of the connection the request was sent over
+	Header           http.Header
+	Timestamps       *Timestamps
+	Timeout          error
+	Error            error
+	AssertionFailed  string
+	Body             []byte
+	Resources        []*Response   // fetched embedded resources (if enabled)
+	OpenConnections  int           // open connections of the scenario when the response was received
+	CacheHit         bool          // served from the user's HTTP cache without a request (if enabled)
+	CacheRevalidated bool          // served from the user's HTTP cache after a 304 revalidation (if enabled)
+	MessagesSent     int           // WebSocket messages sent
+	MessagesReceived int           // WebSocket messages received
+	Events           []StreamEvent // streamed events (only in streaming mode, see Request.Stream)
+	GraphQLOperation string        // operation name of GraphQL requests (see Step.GraphQL)
 	// internal
 	archived bool
+	document *xhtml.Node
 }
 
 type StepEntry struct {
@@ -863,8 +1241,21 @@ type StepEntry struct {
 	AssertionFailed          bool
 	AssertionFailedRootCause string
 	StatusCode               int
+	GRPCCode                 codes.Code
+	Protocol                 string
+	LocalAddress             string
 	RequestSize              int
 	ResponseSize             int
+	Resources                []ResourceEntry
+	CacheHit                 bool
+	CacheRevalidated         bool
+	OpenConnections          int
+	MessagesSent             int
+	MessagesReceived         int
+	StreamEvents             int
+	TimeToFirstEvent         time.Duration
+	InterEventGaps           []time.Duration
+	GraphQLOperation         string
 }
 
 func (response *Response) IsFailed() bool {
@@ -873,7 +1264,9 @@ func (response *Response) IsFailed() bool {
 
 func (response *Response) MarkAsFailed(message string) {
 	response.AssertionFailed = message
-	if verbose {
+	if smoke {
+		LogError(message)
+	} else if verbose {
 		log.Println(message)
 	}
 }
@@ -885,6 +1278,8 @@ func (response *Response) Assert(fn func(response *Response) (message string, ok
 	message, ok := fn(response)
 	if !ok {
 		response.MarkAsFailed(fmt.Sprint("assertion of function on response failed ", message))
+	} else {
+		response.markAsPassed("assertion of function on response passed %s", message)
 	}
 	return response
 }
@@ -896,6 +1291,8 @@ func (response *Response) AssertStatusCode(statusCode int) *Response {
 	ok := response.StatusCode == statusCode
 	if !ok {
 		response.MarkAsFailed(fmt.Sprint("assertion of status code failed: got ", response.StatusCode, " want ", statusCode))
+	} else {
+		response.markAsPassed("assertion of status code passed: %d", statusCode)
 	}
 	return response
 }
@@ -907,6 +1304,8 @@ func (response *Response) AssertStatus(status string) *Response {
 	ok := response.Status == status
 	if !ok {
 		response.MarkAsFailed(fmt.Sprint("assertion of status failed: got ", response.Status, " want ", status))
+	} else {
+		response.markAsPassed("assertion of status passed: %s", status)
 	}
 	return response
 }
@@ -918,6 +1317,8 @@ func (response *Response) AssertBodyMatches(re *regexp.Regexp) *Response {
 	ok := re.Match(response.Body)
 	if !ok {
 		response.MarkAsFailed(fmt.Sprint("assertion of body content failed (response body did not match expected regular expression): ", re))
+	} else {
+		response.markAsPassed("assertion of body content passed (response body matched regular expression): %s", re)
 	}
 	return response
 }
@@ -929,6 +1330,8 @@ func (response *Response) AssertBodyContains(s string) *Response {
 	ok := strings.Contains(string(response.Body), s)
 	if !ok {
 		response.MarkAsFailed(fmt.Sprint("assertion of body content failed (response body did not contain expected value): ", s))
+	} else {
+		response.markAsPassed("assertion of body content passed (response body contained value): %s", s)
 	}
 	return response
 }
@@ -941,6 +1344,8 @@ func (response *Response) AssertBodySizeAtLeast(bytes int) *Response {
 	ok := length >= bytes
 	if !ok {
 		response.MarkAsFailed(fmt.Sprint("assertion of body size failed (response body was shorter than expected value): got ", length, " want >=", bytes))
+	} else {
+		response.markAsPassed("assertion of body size passed: got %d want >=%d", length, bytes)
 	}
 	return response
 }
@@ -953,6 +1358,8 @@ func (response *Response) AssertBodySizeAtMost(bytes int) *Response {
 	ok := length <= bytes
 	if !ok {
 		response.MarkAsFailed(fmt.Sprint("assertion of body size failed (response body was longer than expected value): got ", length, " want <=", bytes))
+	} else {
+		response.markAsPassed("assertion of body size passed: got %d want <=%d", length, bytes)
 	}
 	return response
 }
@@ -962,6 +1369,13 @@ func (response *Response) ConsideredUnsuccessful() bool {
 }
 
 func (response *Response) ArchiveStats() *Response {
+	if smoke {
+		if !response.archived {
+			response.logSmokeStep() // instead of tracking the stats
+			response.archived = true
+		}
+		return response
+	}
 	// histogram tracking
 	if len(folder) > 0 && !response.archived {
 		histogramLock.Lock()
@@ -994,19 +1408,6 @@ func (response *Response) ArchiveStats() *Response {
 
 /* check if connection and tls handshake values are correct (when connections are reused?)
 
-func (response *Response) gotConn(info httptrace.GotConnInfo) {
-	response.Timestamps.GotConn = time.Now()
-	response.Timestamps.ConnReused = info.Reused
-}
-
-func (response *Response) dnsStart(dsi httptrace.DNSStartInfo) {
-	response.Timestamps.DNSStart = time.Now()
-}
-
-func (response *Response) dnsDone(ddi httptrace.DNSDoneInfo) {
-	response.Timestamps.DNSDone = time.Now()
-}
-
 func (response *Response) tlsHandshakeStart() {
 	response.Timestamps.TLSHandshakeStart = time.Now()
 }
@@ -1023,6 +1424,22 @@ func (response *Response) connectDone(network, addr string, err error) {
 	response.Timestamps.ConnectDone = time.Now()
 }
 */
+func (response *Response) gotConn(info httptrace.GotConnInfo) {
+	// response.Timestamps.GotConn = time.Now()
+	// response.Timestamps.ConnReused = info.Reused
+	if host, _, err := net.SplitHostPort(info.Conn.LocalAddr().String()); err == nil {
+		response.LocalAddress = host
+	}
+}
+
+func (response *Response) dnsStart(dsi httptrace.DNSStartInfo) {
+	response.Timestamps.DNSStart = time.Now()
+}
+
+func (response *Response) dnsDone(ddi httptrace.DNSDoneInfo) {
+	response.Timestamps.DNSDone = time.Now()
+}
+
 func (response *Response) gotFirstResponseByte() {
 	// for calculating the time from start to first byte (TTFB)
 	response.Timestamps.GotFirstResponseByte = time.Now()
@@ -1064,10 +1481,23 @@ func (response *Response) stepEntry() *StepEntry {
 		AssertionFailed:          len(response.AssertionFailed) > 0,
 		AssertionFailedRootCause: response.AssertionFailed,
 		StatusCode:               response.StatusCode,
+		GRPCCode:                 response.GRPCCode,
+		Protocol:                 response.Protocol,
+		LocalAddress:             response.LocalAddress,
 		Timestamps:               *response.Timestamps,
 		RequestSize:              response.RequestSize,
 		ResponseSize:             response.ResponseSize,
-	}
+		Resources:                response.resourceEntries(),
+		CacheHit:                 response.CacheHit,
+		CacheRevalidated:         response.CacheRevalidated,
+		OpenConnections:          response.OpenConnections,
+		MessagesSent:             response.MessagesSent,
+		MessagesReceived:         response.MessagesReceived,
+		StreamEvents:             len(response.Events),
+		InterEventGaps:           response.InterEventGaps(),
+		GraphQLOperation:         response.GraphQLOperation,
+	}
+	stepEntry.TimeToFirstEvent, _ = response.TimeToFirstEvent()
 	const logErrorDetailsForDebugging = false
 	if logErrorDetailsForDebugging {
 		if response.Error != nil {
@@ -1103,19 +1533,36 @@ func (stats *Timestamps) TimeToFirstByte(afterRequestSent bool) (d time.Duration
 	return res, true
 }
 
+// DNSLookupTime returns the duration of the DNS lookup (not completed when the request reused a connection or the IP was known).
+func (stats *Timestamps) DNSLookupTime() (d time.Duration, completed bool) {
+	if stats.DNSStart.IsZero() || stats.DNSDone.IsZero() {
+		return 0, false
+	}
+	res := stats.DNSDone.Sub(stats.DNSStart)
+	if res < 0 {
+		res = 0
+	}
+	return res, true
+}
+
 func (response *Response) PrintStats(w io.Writer) *Response {
 	printLock.Lock()
 	defer printLock.Unlock()
 	_, _ = fmt.Fprintln(w)
 	_, _ = fmt.Fprintln(w, "------------------------------------------------------------------")
 	_, _ = fmt.Fprintln(w, response.RequestURL)
-	_, _ = fmt.Fprintln(w, response.Status)
+	_, _ = fmt.Fprintln(w, response.Protocol, response.Status)
 	_, _ = fmt.Fprintln(w, "Total-Duration:", durationMeasurement(response.Timestamps.TotalDuration()))
 	_, _ = fmt.Fprintln(w, "Time-to-First-Byte:", durationMeasurement(response.Timestamps.TimeToFirstByte(false)))
 	_, _ = fmt.Fprintln(w, "Time-to-First-Byte (after Request-Sent):", durationMeasurement(response.Timestamps.TimeToFirstByte(true)))
+	if len(response.Resources) > 0 {
+		_, _ = fmt.Fprintln(w, "Page-Load-Time:", durationMeasurement(response.Timestamps.PageLoadTime()), "with", len(response.Resources), "embedded resources")
+	}
+	if _, completed := response.Timestamps.DNSLookupTime(); completed {
+		_, _ = fmt.Fprintln(w, "DNS-Lookup-Time:", durationMeasurement(response.Timestamps.DNSLookupTime()))
+	}
 	/*
 		_, _ = fmt.Fprintln(w,"Connection reused:", response.Timestamps.ConnReused)
-		_, _ = fmt.Fprintln(w,"DNS Lookup:", response.Timestamps.DNSDone.Sub(response.Timestamps.DNSStart))
 		_, _ = fmt.Fprintln(w,"Connect:", response.Timestamps.ConnectDone.Sub(response.Timestamps.ConnectStart))
 		_, _ = fmt.Fprintln(w,"TLS Handshake:", response.Timestamps.TLSHandshakeDone.Sub(response.Timestamps.TLSHandshakeStart))
 		_, _ = fmt.Fprintln(w,"Time To First Byte (from TLS Handshake Done):", response.Timestamps.GotFirstResponseByte.Sub(response.Timestamps.TLSHandshakeDone))
@@ -1139,20 +1586,35 @@ func (response *Response) ExtractStringFromJSON(expression string) string {
 	res := response.EvalExpressionOnJSON(expression)
 	if res == nil {
 		return ""
-	} else {
-		return res.(string)
 	}
+	return jsonValueToString(res)
 }
 
 func (response *Response) ExtractSliceFromJSON(expression string) (result []string) {
 	res := response.EvalExpressionOnJSON(expression)
-	if res != nil {
-		result = res.([]string)
+	switch values := res.(type) {
+	case []string:
+		result = values
+	case []interface{}:
+		for _, value := range values {
+			result = append(result, jsonValueToString(value))
+		}
+	case nil:
+	default:
+		result = []string{jsonValueToString(values)}
 	}
 	return
 }
 
 func (response *Response) EvalExpressionOnJSON(expression string) interface{} {
+	result, err := evalExpressionOnJSON(response.Body, expression)
+	if err != nil {
+		LogError(err) // TODO track it as unable to extract (i.e. not found?)
+	}
+	return result
+}
+
+func evalExpressionOnJSON(body []byte, expression string) (interface{}, error) {
 	builder := gval.Full(jsonpath.PlaceholderExtension())
 	// see https://goessner.net/articles/JsonPath/
 	// and https://godoc.org/github.com/PaesslerAG/jsonpath#example-package--Gval
@@ -1161,19 +1623,16 @@ func (response *Response) EvalExpressionOnJSON(expression string) interface{} {
 	// or simpler examples like $["user-agent"]
 	path, err := builder.NewEvaluable(expression)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	result, err := path(context.Background(), DynamicJSON(response.Body))
-	if err != nil {
-		LogError(err) // TODO track it as unable to extract (i.e. not found?)
-	}
-	return result
+	return path(context.Background(), DynamicJSON(body))
 }
 
 type Scenario struct {
 	Title, Description string
 	Runner             func(user *User)
 	LoadConfig         LoadConfig
+	Transport          TransportConfig
 	Ignored            bool
 	ExecutionCount     uint64
 }
@@ -1191,6 +1650,8 @@ type LoadConfig struct { // TODO add also cool-down period to slowly reduce user
 	LoopDelay                 RandomInterval
 	RampUp, Plateau, RampDown time.Duration
 	ClearCookieJarOnEveryLoop bool
+	HTTPCache                 bool // emulates a browser cache per user (cleared alongside the cookie jar)
+	Connections               ConnectionPolicy
 }
 
 // safeTracker is safe to use concurrently.
@@ -1283,23 +1744,64 @@ func (sgw *scenariosGobWriter) writeScenarios(scenarios map[string]*Scenario) er
 	if err != nil {
 		return err
 	}
-	return sgw.gobEncoder.Encode(scenarios)
+	written := make(map[string]*Scenario, len(scenarios))
+	for title, scenario := range scenarios {
+		copied := *scenario
+		copied.Transport = scenario.Transport.redacted() // secrets like proxy passwords must not end up in the results
+		written[title] = &copied
+	}
+	return sgw.gobEncoder.Encode(written)
 }
 
 func init() { // special func init() is called automatically and only once (before the other special func main() which is the entry point)
 	rand.Seed(time.Now().UnixNano())
 	// handle CTRL-C
+	signal.Notify(interrupts, os.Interrupt)
 	go func() {
-		sigchan := make(chan os.Signal)
-		signal.Notify(sigchan, os.Interrupt)
-		<-sigchan
-		LogInfo("Goverrun stopped")
-		// do last actions and wait for all write operations to end
-		writeSummaryAndCloseFiles()
-		os.Exit(0)
+		for range interrupts {
+			interruptLock.Lock()
+			handledElsewhere := interruptHandlers > 0
+			interruptLock.Unlock()
+			if handledElsewhere {
+				continue // interrupted while starting a handler of notifyInterrupt
+			}
+			LogInfo("Goverrun stopped")
+			// do last actions and wait for all write operations to end
+			writeSummaryAndCloseFiles()
+			os.Exit(0)
+		}
 	}()
 }
 
+var (
+	interrupts        = make(chan os.Signal, 1)
+	interruptHandlers int // number of running notifyInterrupt handlers
+	interruptLock     sync.Mutex
+)
+
+// notifyInterrupt relays interrupts (CTRL-C) to the returned channel instead of stopping goverrun until stopInterruptNotify
+// (like to write a recording when interrupted).
+func notifyInterrupt() chan os.Signal {
+	interruptLock.Lock()
+	defer interruptLock.Unlock()
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	signal.Stop(interrupts)
+	interruptHandlers++
+	return interrupted
+}
+
+// stopInterruptNotify lets interrupts stop goverrun again (unless handled by other notifyInterrupt handlers).
+func stopInterruptNotify(interrupted chan os.Signal) {
+	interruptLock.Lock()
+	defer interruptLock.Unlock()
+	interruptHandlers--
+	if interruptHandlers == 0 {
+		signal.Notify(interrupts, os.Interrupt)
+	}
+	signal.Stop(interrupted)
+}
+
 var (
 	closed    = false
 	closeLock sync.Mutex
@@ -1350,6 +1852,18 @@ func AddScenario(scenario *Scenario) error {
 	if scenario.LoadConfig.Plateau < 0 {
 		panic("negative Plateau")
 	}
+	if err := scenario.Transport.Validate(); err != nil {
+		panic(fmt.Sprint("invalid transport config: ", err))
+	}
+	if err := scenario.LoadConfig.Connections.Validate(scenario.Transport.Protocol); err != nil {
+		panic(fmt.Sprint("invalid connection policy: ", err))
+	}
+	if scenario.LoadConfig.Connections.SharedTransport && len(scenario.Transport.TLS.ClientCertDir) > 0 {
+		panic("per-user client certificates (ClientCertDir) are not supported with a shared transport")
+	}
+	if scenario.LoadConfig.Connections.SharedTransport && scenario.Transport.Resolver.StickyIP {
+		panic("per-user sticky IPs (StickyIP) are not supported with a shared transport")
+	}
 	if _, exists := scenarios[scenario.Title]; exists {
 		return fmt.Errorf("scenario already exists '%s'", scenario.Title)
 	}
@@ -1359,23 +1873,22 @@ func AddScenario(scenario *Scenario) error {
 
 func DefaultLoadConfigFromArgs() LoadConfig {
 	return LoadConfig{
-		StartDelay: RandomInterval{
-			Min: 0 * time.Millisecond,
-			Max: 0 * time.Millisecond,
-		},
-		LoopingUsers: *CommandlineArgs.Run.LoopingUsers,
-		LoopDelay: RandomInterval{
-			Min: 0 * time.Millisecond,
-			Max: 0 * time.Millisecond,
-		},
+		StartDelay:                *CommandlineArgs.Run.StartDelay,
+		LoopingUsers:              *CommandlineArgs.Run.LoopingUsers,
+		LoopDelay:                 *CommandlineArgs.Run.LoopDelay,
 		RampUp:                    time.Duration(*CommandlineArgs.Run.RampUpSeconds) * time.Second,
 		Plateau:                   time.Duration(*CommandlineArgs.Run.PlateauSeconds) * time.Second,
 		RampDown:                  time.Duration(*CommandlineArgs.Run.RampDownSeconds) * time.Second,
-		ClearCookieJarOnEveryLoop: true,
+		ClearCookieJarOnEveryLoop: *CommandlineArgs.Run.ClearCookieJarOnEveryLoop,
 	}
 }
 
 func Run(outputFolder string, verboseLogs bool) {
+	for _, scenario := range scenarios {
+		if err := scenario.Transport.validateProtocolSupport(); err != nil && !scenario.Ignored {
+			LogFatal("Invalid transport config of scenario:", scenario.Title, err) // before any scenario starts
+		}
+	}
 	defer writeSummaryAndCloseFiles()
 
 	// log every 10 seconds (via ticker) the current state
@@ -1389,7 +1902,7 @@ func Run(outputFolder string, verboseLogs bool) {
 			case _ /*t*/ = <-logTicker.C:
 				//fmt.Println("Tick at", t)
 				for _, scenario := range scenarios {
-					LogInfof("Looping users of scenario '%s': %d\n", scenario.Title, currentLoopingUsers.Value(scenario.Title))
+					LogInfof("Looping users of scenario '%s': %d (open connections: %d)\n", scenario.Title, currentLoopingUsers.Value(scenario.Title), openConnections.Value(scenario.Title))
 				}
 			}
 		}
@@ -1441,6 +1954,15 @@ func Run(outputFolder string, verboseLogs bool) {
 			end := time.Now().Add(scenario.LoadConfig.RampUp).Add(scenario.LoadConfig.Plateau).Add(scenario.LoadConfig.RampDown)
 			rampDownPhaseEntry := end.Add(-scenario.LoadConfig.RampDown)
 			rampDownStep := int64(scenario.LoadConfig.RampDown) / int64(scenario.LoadConfig.LoopingUsers)
+			loadedTLS, err := scenario.Transport.TLS.load()
+			if err != nil {
+				LogError("Unable to load TLS config of scenario:", scenario.Title, err)
+				return
+			}
+			var sharedRoundTripper http.RoundTripper
+			if scenario.LoadConfig.Connections.SharedTransport {
+				sharedRoundTripper = newRoundTripper(scenario.Transport, scenario.LoadConfig.Connections, loadedTLS.forUser(0), scenario.Title, 0)
+			}
 			for currentUserCount := 1; currentUserCount <= scenario.LoadConfig.LoopingUsers; currentUserCount++ {
 				rampDownCutoffForCurrentUser := rampDownPhaseEntry.Add(time.Duration(int64(currentUserCount) * rampDownStep))
 				wg.Add(1)
@@ -1450,24 +1972,44 @@ func Run(outputFolder string, verboseLogs bool) {
 					if verbose {
 						LogInfof("Ramp-up: adding looping user to scenario '%s': %d looping\n", scenario.Title, currentLoopingCount)
 					}
+					roundTripper := sharedRoundTripper
+					if roundTripper == nil {
+						roundTripper = newRoundTripper(scenario.Transport, scenario.LoadConfig.Connections, loadedTLS.forUser(currentUser), scenario.Title, currentUser)
+					}
+					transport := wrapRoundTripper(scenario.Transport, roundTripper, currentUser)
+					if sharedRoundTripper == nil {
+						defer transport.CloseIdleConnections()
+					}
+					if scenario.LoadConfig.HTTPCache {
+						transport.Cache = NewHTTPCache()
+					}
 					user := User{
 						Scenario:    scenario.Title,
 						CurrentUser: currentUser,
 						HttpClient: &http.Client{
-							Transport: NewRoundTripperWrapper(SkipCertificateValidation, Proxy),
+							Transport: transport,
 						},
-						Data: make(map[string]interface{}),
+						Data:    make(map[string]interface{}),
+						tagging: scenario.Transport.Tagging,
 					}
+					defer user.closeWebSockets()
+					defer user.closeSockets()
 					for time.Now().Before(end) {
 						user.CurrentLoop++
 						if user.HttpClient.Jar == nil || scenario.LoadConfig.ClearCookieJarOnEveryLoop {
 							jar, err := cookiejar.New(nil)
 							CheckErrAndLogError(err, "unable to initialize cookie jar")
 							user.HttpClient.Jar = jar
+							if transport.Cache != nil {
+								transport.Cache.Clear()
+							}
+						}
+						if scenario.LoadConfig.Connections.NewConnectionEveryLoop && user.CurrentLoop > 1 {
+							transport.CloseIdleConnections()
 						}
 						scenario.Runner(&user)
 						atomic.AddUint64(&scenario.ExecutionCount, 1)
-						if time.Now().After(rampDownCutoffForCurrentUser) {
+						if user.Disabled || time.Now().After(rampDownCutoffForCurrentUser) {
 							newCount := currentLoopingUsers.Dec(scenario.Title)
 							user.Disabled = true
 							if verbose {
@@ -0,0 +1,195 @@
+package goverrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// GraphQLRequest is a GraphQL operation sent as JSON POST request. As GraphQL APIs usually serve all operations
+// from a single URL, the operation name is tracked with every response, so that the results can be broken down
+// by operation within the step.
+type GraphQLRequest struct {
+	Request       *Request
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+	AllowErrors   bool // responses with errors are not marked as failed (e.g. to assert on expected errors)
+}
+
+// GraphQLError is an entry of the errors array of a GraphQL response.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// operationNamePattern matches the type and name of the first named operation of a query document.
+var operationNamePattern = regexp.MustCompile(`(?:^|[\s}])(query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// GraphQL prepares the GraphQL operation (query, mutation or subscription document) to be sent to the endpoint URL.
+// The operation name defaults to the name of the first named operation within the query.
+func (step *Step) GraphQL(url, query string) *GraphQLRequest {
+	gql := &GraphQLRequest{Request: step.Request(http.MethodPost, url), Query: query}
+	if match := operationNamePattern.FindStringSubmatch(query); match != nil {
+		gql.OperationName = match[2]
+	}
+	return gql
+}
+
+// SetOperationName selects the operation to execute (when the query contains multiple operations).
+func (gql *GraphQLRequest) SetOperationName(name string) *GraphQLRequest {
+	gql.OperationName = name
+	return gql
+}
+
+func (gql *GraphQLRequest) SetVariable(key string, value interface{}) *GraphQLRequest {
+	if gql.Variables == nil {
+		gql.Variables = make(map[string]interface{})
+	}
+	gql.Variables[key] = value
+	return gql
+}
+
+func (gql *GraphQLRequest) SetHeader(key, value string) *GraphQLRequest {
+	gql.Request.SetHeader(key, value)
+	return gql
+}
+
+// AllowGraphQLErrors keeps responses with errors from being marked as failed.
+func (gql *GraphQLRequest) AllowGraphQLErrors() *GraphQLRequest {
+	gql.AllowErrors = true
+	return gql
+}
+
+func (gql *GraphQLRequest) SendWithoutTimeout() *Response {
+	return gql.send()
+}
+
+func (gql *GraphQLRequest) SendWithTimeout(timeout time.Duration) *Response {
+	gql.Request.Timeout = timeout
+	return gql.send()
+}
+
+// send posts the operation and marks responses containing errors as failed (GraphQL servers usually respond
+// with status 200 even when the operation failed). The failure root cause is the error code (see classifyGraphQLErrors).
+func (gql *GraphQLRequest) send() *Response {
+	if gql.Request.Disabled {
+		return sendRequest(gql.Request)
+	}
+	payload := map[string]interface{}{"query": gql.Query}
+	if len(gql.OperationName) > 0 {
+		payload["operationName"] = gql.OperationName
+	}
+	if len(gql.Variables) > 0 {
+		payload["variables"] = gql.Variables
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return gql.Request.User.trackSmoke(&Response{
+			Scenario:   gql.Request.User.Scenario,
+			Step:       gql.Request.Step,
+			RequestURL: gql.Request.URL,
+			Timestamps: &Timestamps{},
+			Error:      err,
+		})
+	}
+	var body io.Reader = bytes.NewReader(data)
+	gql.Request.SetBody(&body)
+	if _, ok := gql.Request.Headers["Content-Type"]; !ok {
+		gql.Request.SetHeader("Content-Type", "application/json")
+	}
+	if _, ok := gql.Request.Headers["Accept"]; !ok {
+		gql.Request.SetHeader("Accept", "application/json")
+	}
+	rsp := sendRequest(gql.Request)
+	rsp.GraphQLOperation = gql.operation()
+	if !gql.AllowErrors && !rsp.ConsideredUnsuccessful() {
+		if errs := rsp.GraphQLErrors(); len(errs) > 0 {
+			rsp.MarkAsFailed(classifyGraphQLErrors(errs))
+		}
+	}
+	return rsp
+}
+
+// operation returns the name of the operation to track (anonymous operations are tracked by their type).
+func (gql *GraphQLRequest) operation() string {
+	if len(gql.OperationName) > 0 {
+		return gql.OperationName
+	}
+	operationType := "query"
+	for _, t := range []string{"mutation", "subscription"} {
+		if strings.HasPrefix(strings.TrimSpace(gql.Query), t) {
+			operationType = t
+		}
+	}
+	return "(anonymous " + operationType + ")"
+}
+
+// GraphQLErrors returns the errors array of the GraphQL response (nil when there are none or the body is not JSON).
+func (response *Response) GraphQLErrors() []GraphQLError {
+	var result struct {
+		Errors []GraphQLError `json:"errors"`
+	}
+	if err := json.Unmarshal(response.Body, &result); err != nil {
+		return nil
+	}
+	return result.Errors
+}
+
+// AssertGraphQLErrorCode asserts the GraphQL response to contain an error with the given code (extensions.code).
+// Use it together with AllowGraphQLErrors.
+func (response *Response) AssertGraphQLErrorCode(code string) *Response {
+	if response.ConsideredUnsuccessful() {
+		return response // earlier checked assertion already failed or error or timeout happened
+	}
+	for _, e := range response.GraphQLErrors() {
+		if e.code() == code {
+			response.markAsPassed("assertion of GraphQL error code passed: %s", code)
+			return response
+		}
+	}
+	response.MarkAsFailed(fmt.Sprint("assertion of GraphQL error code failed (no error with expected code): ", code))
+	return response
+}
+
+func (e GraphQLError) code() string {
+	if code, ok := e.Extensions["code"].(string); ok {
+		return code
+	}
+	return ""
+}
+
+// classifyGraphQLErrors returns the failure root cause of the errors: the code (extensions.code) of the first error
+// or its path if it has no code. Messages are not used, as they often contain variable details (like IDs) and would
+// make the failure types unbounded.
+func classifyGraphQLErrors(errs []GraphQLError) string {
+	if code := errs[0].code(); len(code) > 0 {
+		return "GraphQL error " + code
+	}
+	if path := errs[0].fieldPath(); len(path) > 0 {
+		return "GraphQL error at " + path
+	}
+	return "GraphQL error (no code)"
+}
+
+// fieldPath returns the path of the error without list indices (like orders[].total).
+func (e GraphQLError) fieldPath() string {
+	var path strings.Builder
+	for _, segment := range e.Path {
+		if field, ok := segment.(string); ok {
+			if path.Len() > 0 {
+				path.WriteString(".")
+			}
+			path.WriteString(field)
+		} else if path.Len() > 0 {
+			path.WriteString("[]")
+		}
+	}
+	return path.String()
+}
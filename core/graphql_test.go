@@ -0,0 +1,85 @@
+package goverrun
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphQL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&payload) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch payload.OperationName {
+		case "Product":
+			_, _ = w.Write([]byte(`{"data":{"product":{"id":"` + payload.Variables["id"].(string) + `"}}}`))
+		case "Checkout":
+			_, _ = w.Write([]byte(`{"data":null,"errors":[{"message":"cart 42 is empty","path":["checkout"],"extensions":{"code":"CART_EMPTY"}}]}`))
+		case "Orders":
+			_, _ = w.Write([]byte(`{"data":null,"errors":[{"message":"order 7 has no total","path":["orders",3,"total"]}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"errors":[{"message":"unknown operation"}]}`))
+		}
+	}))
+	defer server.Close()
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{}}
+
+	response := user.Step("graphql").GraphQL(server.URL, "query Product($id: ID!) { product(id: $id) { id } }").
+		SetVariable("id", "p-1").
+		SendWithTimeout(5 * time.Second).
+		AssertStatusCode(200).
+		AssertBodyContains(`"id":"p-1"`)
+	if response.ConsideredUnsuccessful() || response.GraphQLOperation != "Product" || response.stepEntry().GraphQLOperation != "Product" {
+		t.Fatalf("unexpected response: %v %s %q", response.Error, response.AssertionFailed, response.GraphQLOperation)
+	}
+
+	response = user.Step("graphql").GraphQL(server.URL, "mutation Checkout { checkout { id } }").SendWithoutTimeout()
+	if response.StatusCode != 200 || response.AssertionFailed != "GraphQL error CART_EMPTY" {
+		t.Errorf("expected response with errors to fail: %d %q", response.StatusCode, response.AssertionFailed)
+	}
+	if errs := response.GraphQLErrors(); len(errs) != 1 || errs[0].Message != "cart 42 is empty" || errs[0].Path[0] != "checkout" {
+		t.Errorf("unexpected errors: %+v", errs)
+	}
+
+	response = user.Step("graphql").GraphQL(server.URL, "mutation Checkout { checkout { id } }").AllowGraphQLErrors().SendWithoutTimeout().
+		AssertGraphQLErrorCode("CART_EMPTY")
+	if response.ConsideredUnsuccessful() {
+		t.Errorf("expected allowed errors to pass: %q", response.AssertionFailed)
+	}
+
+	response = user.Step("graphql").GraphQL(server.URL, "query Orders { orders { total } }").SendWithoutTimeout()
+	if response.AssertionFailed != "GraphQL error at orders[].total" {
+		t.Errorf("expected error without code to fail by its path: %q", response.AssertionFailed)
+	}
+
+	response = user.Step("graphql").GraphQL(server.URL, "{ unknown }").SendWithoutTimeout()
+	if response.GraphQLOperation != "(anonymous query)" || response.AssertionFailed != "GraphQL error (no code)" {
+		t.Errorf("unexpected anonymous operation: %q %q", response.GraphQLOperation, response.AssertionFailed)
+	}
+}
+
+func TestGraphQLOperationsReport(t *testing.T) {
+	stats := &Stats{
+		Counts: Counts{Requests: 4, Failures: 1},
+		GraphQLOperations: map[string]Counts{
+			"Product":  {Requests: 3},
+			"Checkout": {Requests: 1, Failures: 1},
+		},
+	}
+	report := printDistributions(stats)
+	if !strings.Contains(report, "GraphQL Operations:") || !strings.Contains(report, "3 =  75.00%: Product (0 failures, 0 errors, 0 timeouts)") ||
+		!strings.Contains(report, "1 =  25.00%: Checkout (1 failures, 0 errors, 0 timeouts)") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
@@ -0,0 +1,420 @@
+package goverrun
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GRPCConfig configures the connection of a GRPCClient.
+type GRPCConfig struct {
+	TLS                *TLSConfig // nil for plaintext connections
+	DescriptorSetFiles []string   // FileDescriptorSet files (protoc --include_imports --descriptor_set_out) describing the services (instead of server reflection)
+}
+
+// GRPCClient invokes gRPC methods described dynamically (i.e. without generated code) via server reflection or descriptor set files.
+// It is safe to be used concurrently, so all users of a scenario may share one client (and thereby its connections).
+// As gRPC sends the user agent per connection, users with different user agents (see TransportConfig.UserAgentStrategy)
+// use separate connections.
+type GRPCClient struct {
+	target  string
+	options []grpc.DialOption
+	conn    *grpc.ClientConn            // of the package-level UserAgent (also used for server reflection)
+	conns   map[string]*grpc.ClientConn // per user agent
+	files   *protoFiles                 // from the descriptor set files (nil when using server reflection)
+	lock    sync.Mutex
+	methods map[string]protoreflect.MethodDescriptor
+}
+
+// protoFiles are the file descriptors resolved so far.
+type protoFiles struct {
+	set []*descriptorpb.FileDescriptorProto
+}
+
+// NewGRPCClient connects to the target (like localhost:50051).
+func NewGRPCClient(target string, config GRPCConfig) (*GRPCClient, error) {
+	credentialsOption := grpc.WithTransportCredentials(insecure.NewCredentials())
+	if config.TLS != nil {
+		loaded, err := config.TLS.load()
+		if err != nil {
+			return nil, err
+		}
+		credentialsOption = grpc.WithTransportCredentials(credentials.NewTLS(loaded.forUser(0)))
+	}
+	client := &GRPCClient{
+		target:  target,
+		options: []grpc.DialOption{credentialsOption},
+		conns:   make(map[string]*grpc.ClientConn),
+		methods: make(map[string]protoreflect.MethodDescriptor),
+	}
+	if len(config.DescriptorSetFiles) > 0 {
+		client.files = &protoFiles{}
+		for _, filename := range config.DescriptorSetFiles {
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			var set descriptorpb.FileDescriptorSet
+			if err := proto.Unmarshal(data, &set); err != nil {
+				return nil, fmt.Errorf("unable to parse descriptor set file '%s': %w", filename, err)
+			}
+			client.files.set = append(client.files.set, set.File...)
+		}
+	}
+	conn, err := client.connection(UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	client.conn = conn
+	return client, nil
+}
+
+// connection returns the connection sending the given user agent (dialing it on first use).
+func (client *GRPCClient) connection(userAgent string) (*grpc.ClientConn, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if conn, exists := client.conns[userAgent]; exists {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(client.target, append([]grpc.DialOption{grpc.WithUserAgent(userAgent)}, client.options...)...)
+	if err != nil {
+		return nil, err
+	}
+	client.conns[userAgent] = conn
+	return conn, nil
+}
+
+// Close closes the connections.
+func (client *GRPCClient) Close() error {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	var err error
+	for _, conn := range client.conns {
+		if closeErr := conn.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// method returns the descriptor of the method like "package.Service/Method" (resolving it on first use).
+func (client *GRPCClient) method(ctx context.Context, fullMethod string) (protoreflect.MethodDescriptor, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if method, exists := client.methods[fullMethod]; exists {
+		return method, nil
+	}
+	i := strings.LastIndex(fullMethod, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid gRPC method '%s' (expected package.Service/Method)", fullMethod)
+	}
+	serviceName, methodName := strings.TrimPrefix(fullMethod[:i], "/"), fullMethod[i+1:]
+	files := client.files
+	if files == nil {
+		var err error
+		if files, err = client.reflect(ctx, serviceName); err != nil {
+			return nil, err
+		}
+	}
+	registry, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: files.set})
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := registry.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("unknown gRPC service '%s': %w", serviceName, err)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a gRPC service", serviceName)
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("unknown method '%s' of gRPC service '%s'", methodName, serviceName)
+	}
+	client.methods[fullMethod] = method
+	return method, nil
+}
+
+// reflect fetches the file descriptors of the service including all their dependencies via server reflection.
+func (client *GRPCClient) reflect(ctx context.Context, serviceName string) (*protoFiles, error) {
+	stream, err := rpb.NewServerReflectionClient(client.conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = stream.CloseSend() }()
+	files := &protoFiles{}
+	known := make(map[string]bool)
+	request := &rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: serviceName}}
+	for request != nil {
+		if err := stream.Send(request); err != nil {
+			return nil, err
+		}
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if errorResponse := response.GetErrorResponse(); errorResponse != nil {
+			return nil, fmt.Errorf("server reflection failed for '%s': %s", serviceName, errorResponse.ErrorMessage)
+		}
+		for _, data := range response.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			var file descriptorpb.FileDescriptorProto
+			if err := proto.Unmarshal(data, &file); err != nil {
+				return nil, err
+			}
+			if !known[file.GetName()] {
+				known[file.GetName()] = true
+				files.set = append(files.set, &file)
+			}
+		}
+		request = nil
+		for _, file := range files.set { // request the first missing dependency (if any)
+			for _, dependency := range file.Dependency {
+				if !known[dependency] && request == nil {
+					request = &rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dependency}}
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// GRPCRequest is the invocation of a gRPC method within a step.
+type GRPCRequest struct {
+	Step     *Step
+	Client   *GRPCClient
+	Method   string   // like package.Service/Method
+	Messages []string // request messages in the JSON mapping of protobuf (one for unary and server streaming methods)
+	Metadata map[string]string
+	Timeout  time.Duration
+	Disabled bool
+}
+
+// GRPC prepares the invocation of the method like "package.Service/Method".
+func (step *Step) GRPC(client *GRPCClient, method string) *GRPCRequest {
+	return &GRPCRequest{Step: step, Client: client, Method: method, Disabled: step.User.Disabled}
+}
+
+// SetMessage sets the request message (in the JSON mapping of protobuf).
+func (req *GRPCRequest) SetMessage(json string) *GRPCRequest {
+	req.Messages = []string{json}
+	return req
+}
+
+// AddMessage adds a request message (in the JSON mapping of protobuf) for client streaming methods.
+func (req *GRPCRequest) AddMessage(json string) *GRPCRequest {
+	req.Messages = append(req.Messages, json)
+	return req
+}
+
+func (req *GRPCRequest) SetMetadata(key, value string) *GRPCRequest {
+	if req.Metadata == nil {
+		req.Metadata = make(map[string]string)
+	}
+	req.Metadata[key] = value
+	return req
+}
+
+func (req *GRPCRequest) SendWithoutTimeout() *Response {
+	return req.send()
+}
+
+func (req *GRPCRequest) SendWithTimeout(timeout time.Duration) *Response {
+	req.Timeout = timeout
+	return req.send()
+}
+
+// send invokes the method and receives all response messages. The response body contains the response messages
+// in the JSON mapping of protobuf (separated by newlines), the messages of server streams are also tracked as events.
+// Timeouts (DEADLINE_EXCEEDED) and unavailable servers (UNAVAILABLE) are tracked as timeouts and errors, all other
+// status codes are recorded as gRPC status code of the response (see AssertGRPCCode).
+func (req *GRPCRequest) send() *Response {
+	if req.Disabled {
+		// user got disabled (e.g. ramp-down or exhausted feeder): nothing is sent and nothing will be archived
+		return &Response{Timestamps: &Timestamps{}, archived: true}
+	}
+	user := req.Step.User
+	rsp := &Response{
+		Scenario:   user.Scenario,
+		Step:       req.Step,
+		RequestURL: req.Client.conn.Target() + "/" + strings.TrimPrefix(req.Method, "/"),
+		Protocol:   "grpc",
+		Timestamps: &Timestamps{},
+	}
+	user.trackSmoke(rsp)
+	if verbose {
+		user.printStep(req.Step)
+	}
+	ctx := context.Background()
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+	rsp.Timestamps.Start = time.Now()
+	err := req.invoke(ctx, rsp)
+	rsp.Timestamps.Done = time.Now()
+	rsp.FinalURL = rsp.RequestURL
+	rsp.GRPCCode = status.Code(err)
+	rsp.Status = rsp.GRPCCode.String()
+	switch {
+	case err == nil:
+	case rsp.GRPCCode == codes.DeadlineExceeded:
+		rsp.Timeout = err
+	case rsp.GRPCCode == codes.Unavailable:
+		rsp.Error = err
+	default:
+		if _, isStatus := status.FromError(err); !isStatus {
+			rsp.Error = err // like unknown methods or invalid messages
+		}
+	}
+	return rsp
+}
+
+func (req *GRPCRequest) invoke(ctx context.Context, rsp *Response) error {
+	method, err := req.Client.method(ctx, req.Method)
+	if err != nil {
+		return err
+	}
+	if !method.IsStreamingClient() && len(req.Messages) != 1 {
+		return fmt.Errorf("gRPC method '%s' requires exactly one request message", req.Method)
+	}
+	var requests []proto.Message
+	for _, json := range req.Messages {
+		message := dynamicpb.NewMessage(method.Input())
+		if err := protojson.Unmarshal([]byte(json), message); err != nil {
+			return fmt.Errorf("invalid request message for gRPC method '%s': %w", req.Method, err)
+		}
+		requests = append(requests, message)
+		rsp.RequestSize += proto.Size(message)
+	}
+	conn, err := req.Client.connection(req.Step.User.userAgent())
+	if err != nil {
+		return err
+	}
+	var pairs []string
+	tagged := &http.Request{Header: http.Header{}} // tagging headers are sent as metadata
+	req.Step.User.addTaggingHeaders(tagged, req.Step)
+	header := tagged.Header
+	for key, value := range req.Metadata {
+		header.Set(key, value)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			pairs = append(pairs, strings.ToLower(key), value)
+		}
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{
+		StreamName:    string(method.Name()),
+		ServerStreams: method.IsStreamingServer(),
+		ClientStreams: method.IsStreamingClient(),
+	}, "/"+strings.TrimPrefix(req.Method, "/"))
+	if err != nil {
+		return err
+	}
+	for _, message := range requests {
+		if err := stream.SendMsg(message); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	rsp.Timestamps.WroteRequest = time.Now()
+	var bodies []string
+	for {
+		message := dynamicpb.NewMessage(method.Output())
+		err := stream.RecvMsg(message)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if rsp.Timestamps.GotFirstResponseByte.IsZero() {
+			rsp.Timestamps.GotFirstResponseByte = time.Now()
+			if md, err := stream.Header(); err == nil {
+				rsp.Header = http.Header{}
+				for key, values := range md {
+					for _, value := range values {
+						rsp.Header.Add(key, value) // canonical keys like HTTP responses (metadata keys are lower case)
+					}
+				}
+			}
+		}
+		rsp.ResponseSize += proto.Size(message)
+		json, err := protojson.Marshal(message)
+		if err != nil {
+			return err
+		}
+		bodies = append(bodies, string(json))
+		if method.IsStreamingServer() {
+			rsp.Events = append(rsp.Events, StreamEvent{Received: time.Now(), Data: string(json)})
+		}
+		if !method.IsStreamingServer() {
+			break // unary and client streaming methods return exactly one message
+		}
+	}
+	rsp.Body = []byte(strings.Join(bodies, "\n"))
+	return nil
+}
+
+// AssertGRPCCode asserts the gRPC status code of the response.
+func (response *Response) AssertGRPCCode(code codes.Code) *Response {
+	if response.ConsideredUnsuccessful() {
+		return response // earlier checked assertion already failed or error or timeout happened
+	}
+	if response.GRPCCode != code {
+		response.MarkAsFailed(fmt.Sprint("assertion of gRPC status code failed: got ", response.GRPCCode, " want ", code))
+	} else {
+		response.markAsPassed("assertion of gRPC status code passed: %s", code)
+	}
+	return response
+}
+
+// ExpectGRPCCodePercentageAtLeast sets the expectation of the gRPC status code count (of the received gRPC status codes) for the given step to be at least the given value.
+//
+// When invoked multiple times, only the expectation value when archiving the step's stats for the first time is used
+// (i.e. subsequent invocations post-archive are silently ignored).
+func (step *Step) ExpectGRPCCodePercentageAtLeast(code codes.Code, percentage float64) *Step {
+	addGRPCCodeCheck(step, code, percentage, true)
+	return step
+}
+
+// ExpectGRPCCodePercentageAtMost sets the expectation of the gRPC status code count (of the received gRPC status codes) for the given step to be at most the given value.
+//
+// When invoked multiple times, only the expectation value when archiving the step's stats for the first time is used
+// (i.e. subsequent invocations post-archive are silently ignored).
+func (step *Step) ExpectGRPCCodePercentageAtMost(code codes.Code, percentage float64) *Step {
+	addGRPCCodeCheck(step, code, percentage, false)
+	return step
+}
+
+func addGRPCCodeCheck(step *Step, code codes.Code, percentage float64, isAtLeast bool) {
+	expectation := &StatusCodeExpectation{
+		IsAtLeast:  isAtLeast,
+		StatusCode: int(code),
+		Percentage: percentage,
+	}
+	step.Expectation.GRPCCodeThresholds = append(step.Expectation.GRPCCodeThresholds, expectation)
+}
@@ -0,0 +1,174 @@
+package goverrun
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoDescriptors describes the test service (without generated code): a unary Say and a server streaming Count method.
+func echoDescriptors() *descriptorpb.FileDescriptorSet {
+	echo := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("goverrun/test/echo.proto"),
+		Package:    proto.String("goverrun.test"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		Syntax:     proto.String("proto3"),
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("Say"), InputType: proto.String(".google.protobuf.StringValue"), OutputType: proto.String(".google.protobuf.StringValue")},
+				{Name: proto.String("Count"), InputType: proto.String(".google.protobuf.Int32Value"), OutputType: proto.String(".google.protobuf.StringValue"), ServerStreaming: proto.Bool(true)},
+			},
+		}},
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(wrapperspb.File_google_protobuf_wrappers_proto), echo}}
+}
+
+func startEchoServer(t *testing.T) string {
+	files, err := protodesc.NewFiles(echoDescriptors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "goverrun.test.Echo",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Say",
+			Handler: func(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &wrapperspb.StringValue{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				switch in.Value {
+				case "fail":
+					return nil, status.Error(codes.InvalidArgument, "fail on request")
+				case "slow":
+					time.Sleep(200 * time.Millisecond)
+				}
+				reply := "hello " + in.Value
+				md, _ := metadata.FromIncomingContext(ctx)
+				if len(md.Get("goverrun-scenario-step")) > 0 {
+					reply += " from " + md.Get("goverrun-scenario-step")[0]
+				}
+				_ = grpc.SetHeader(ctx, metadata.Pairs("x-user-agent", strings.Join(md.Get("user-agent"), ", ")))
+				return wrapperspb.String(reply), nil
+			},
+		}},
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "Count",
+			ServerStreams: true,
+			Handler: func(_ interface{}, stream grpc.ServerStream) error {
+				in := &wrapperspb.Int32Value{}
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				for i := int32(1); i <= in.Value; i++ {
+					if err := stream.SendMsg(wrapperspb.String(strings.Repeat("*", int(i)))); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}},
+	}, struct{}{})
+	rpb.RegisterServerReflectionServer(server, reflection.NewServer(reflection.ServerOptions{Services: server, DescriptorResolver: files}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestGRPC(t *testing.T) {
+	target := startEchoServer(t)
+	client, err := NewGRPCClient(target, GRPCConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	transport := &RoundTripperWrapper{UserAgent: "Scenario-Agent"}
+	user := &User{Scenario: "grpc", CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport}, tagging: TaggingConfig{ScenarioStepHeader: true}}
+
+	response := user.Step("say").GRPC(client, "goverrun.test.Echo/Say").SetMessage(`"world"`).SendWithTimeout(5 * time.Second).
+		AssertGRPCCode(codes.OK).
+		AssertBodyContains("hello world from grpc: say")
+	if response.ConsideredUnsuccessful() || response.Protocol != "grpc" || response.Status != "OK" {
+		t.Fatalf("unexpected response: %v %v %s %s", response.Error, response.Timeout, response.AssertionFailed, response.Body)
+	}
+	if ttfb, completed := response.Timestamps.TimeToFirstByte(false); !completed || ttfb <= 0 || response.RequestSize == 0 || response.ResponseSize == 0 {
+		t.Errorf("expected timings and sizes to be tracked: %+v %d %d", response.Timestamps, response.RequestSize, response.ResponseSize)
+	}
+	if userAgent := response.Header.Get("X-User-Agent"); !strings.HasPrefix(userAgent, "Scenario-Agent") {
+		t.Errorf("expected user agent of the transport in canonical response header: %q %v", userAgent, response.Header)
+	}
+
+	response = user.Step("count").GRPC(client, "goverrun.test.Echo/Count").SetMessage("3").SendWithoutTimeout()
+	if response.ConsideredUnsuccessful() || len(response.Events) != 3 || string(response.Body) != "\"*\"\n\"**\"\n\"***\"" {
+		t.Errorf("unexpected server stream: %v %q", response.Error, response.Body)
+	}
+
+	response = user.Step("fail").GRPC(client, "goverrun.test.Echo/Say").SetMessage(`"fail"`).SendWithoutTimeout()
+	if response.Error != nil || response.Timeout != nil || response.GRPCCode != codes.InvalidArgument || response.stepEntry().GRPCCode != codes.InvalidArgument {
+		t.Errorf("expected status code to be recorded: %v %v %v", response.Error, response.Timeout, response.GRPCCode)
+	}
+	if !response.AssertGRPCCode(codes.OK).IsFailed() {
+		t.Error("expected gRPC status code assertion to fail")
+	}
+
+	if response := user.Step("slow").GRPC(client, "goverrun.test.Echo/Say").SetMessage(`"slow"`).SendWithTimeout(50 * time.Millisecond); response.Timeout == nil || response.GRPCCode != codes.DeadlineExceeded {
+		t.Errorf("expected timeout: %v %v", response.Error, response.GRPCCode)
+	}
+	if response := user.Step("unknown").GRPC(client, "goverrun.test.Echo/Shout").SetMessage(`"x"`).SendWithoutTimeout(); response.Error == nil {
+		t.Error("expected unknown method to be an error")
+	}
+}
+
+func TestGRPCDescriptorSetFile(t *testing.T) {
+	target := startEchoServer(t)
+	data, err := proto.Marshal(echoDescriptors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "echo.protoset")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewGRPCClient(target, GRPCConfig{DescriptorSetFiles: []string{filename}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	user := &User{Scenario: "grpc", CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{}}
+	response := user.Step("say").GRPC(client, "goverrun.test.Echo/Say").SetMessage(`"protoset"`).SendWithoutTimeout()
+	if response.ConsideredUnsuccessful() || string(response.Body) != `"hello protoset"` {
+		t.Errorf("unexpected response: %v %s", response.Error, response.Body)
+	}
+}
+
+func TestGRPCCodeExpectations(t *testing.T) {
+	step := &Step{Expectation: &Expectation{}}
+	step.ExpectGRPCCodePercentageAtLeast(codes.OK, 90).ExpectGRPCCodePercentageAtMost(codes.Unavailable, 1)
+	result, unmet := writeStatusCodeExpectations(step.Expectation.GRPCCodeThresholds, map[int]int{int(codes.OK): 95, int(codes.Unavailable): 5}, "gRPC status code")
+	if !unmet || !strings.Contains(result, "Met gRPC status code percentage expectation: wanted at least 90.00% of gRPC status code 0") ||
+		!strings.Contains(result, "Unmet gRPC status code percentage expectation: wanted at most 1.00% of gRPC status code 14") {
+		t.Errorf("unexpected expectation result: %v %s", unmet, result)
+	}
+}
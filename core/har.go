@@ -0,0 +1,135 @@
+package goverrun
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// har is the subset of the HTTP Archive format (as exported by browser developer tools) needed for generating scenarios.
+type har struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"` // in milliseconds
+	ResourceType    string    `json:"_resourceType"`
+	Request         struct {
+		Method   string      `json:"method"`
+		URL      string      `json:"url"`
+		Headers  []harHeader `json:"headers"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status      int         `json:"status"`
+		Headers     []harHeader `json:"headers"`
+		RedirectURL string      `json:"redirectURL"`
+		Content     struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ReadHAR reads the requests of a HAR recording.
+func ReadHAR(r io.Reader) ([]RecordedRequest, error) {
+	var archive har
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("unable to parse HAR: %w", err)
+	}
+	recorded := make([]RecordedRequest, 0, len(archive.Log.Entries))
+	for _, entry := range archive.Log.Entries {
+		rec := RecordedRequest{
+			Started:      entry.StartedDateTime,
+			Duration:     time.Duration(entry.Time * float64(time.Millisecond)),
+			Method:       entry.Request.Method,
+			URL:          entry.Request.URL,
+			Header:       http.Header{},
+			StatusCode:   entry.Response.Status,
+			RedirectURL:  entry.Response.RedirectURL,
+			ContentType:  entry.Response.Content.MimeType,
+			ResourceType: entry.ResourceType,
+		}
+		for _, header := range entry.Request.Headers {
+			rec.Header.Add(header.Name, header.Value)
+		}
+		if entry.Request.PostData != nil {
+			rec.Body = []byte(entry.Request.PostData.Text)
+			if len(rec.Header.Get("Content-Type")) == 0 && len(entry.Request.PostData.MimeType) > 0 {
+				rec.Header.Set("Content-Type", entry.Request.PostData.MimeType)
+			}
+		}
+		if len(rec.RedirectURL) == 0 {
+			for _, header := range entry.Response.Headers {
+				if http.CanonicalHeaderKey(header.Name) == "Location" && rec.StatusCode >= 300 && rec.StatusCode < 400 {
+					rec.RedirectURL = header.Value
+				}
+			}
+		}
+		if entry.Response.Content.Encoding == "base64" {
+			rec.ResponseBody, _ = base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+		} else {
+			rec.ResponseBody = []byte(entry.Response.Content.Text)
+		}
+		recorded = append(recorded, rec)
+	}
+	return recorded, nil
+}
+
+// GenerateScenarioFromHARFile generates the scenario code from the HAR file (see GenerateScenarioCode).
+func GenerateScenarioFromHARFile(filename string, options CodegenOptions) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	recorded, err := ReadHAR(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(options.Source) == 0 {
+		options.Source = "the HAR recording " + filename
+	}
+	return GenerateScenarioCode(recorded, options)
+}
+
+// generateScenarioFromHARArgs runs the har subcommand.
+func generateScenarioFromHARArgs() {
+	if len(CommandlineArgs.SubcommandArgs) == 0 {
+		LogError("Missing HAR file (use as argument at the end)")
+		os.Exit(ExitUsage)
+	}
+	options := CodegenOptions{
+		Package:             *CommandlineArgs.Har.Package,
+		FuncName:            *CommandlineArgs.Har.Func,
+		IncludeStaticAssets: *CommandlineArgs.Har.IncludeStaticAssets,
+		MinThinkTime:        *CommandlineArgs.Har.MinThinkTime,
+		Timeout:             *CommandlineArgs.Har.Timeout,
+	}
+	if hosts := *CommandlineArgs.Har.Hosts; len(hosts) > 0 {
+		options.Hosts = strings.Split(hosts, ",")
+	}
+	code, err := GenerateScenarioFromHARFile(CommandlineArgs.SubcommandArgs[0], options)
+	CheckErrAndLogFatal(err, "unable to generate scenario")
+	err = ioutil.WriteFile(*CommandlineArgs.Har.Output, code, 0644)
+	CheckErrAndLogFatal(err, "unable to write scenario")
+	LogSuccess("Scenario code written to:", *CommandlineArgs.Har.Output)
+}
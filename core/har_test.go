@@ -0,0 +1,110 @@
+package goverrun
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHAR = `{"log": {"entries": [
+  {"startedDateTime": "2022-11-02T10:00:00.000Z", "time": 120, "_resourceType": "document",
+   "request": {"method": "GET", "url": "https://shop.example.com/login", "headers": [{"name": "Accept", "value": "text/html"}, {"name": "Cookie", "value": "a=b"}, {"name": ":authority", "value": "shop.example.com"}]},
+   "response": {"status": 200, "headers": [], "content": {"mimeType": "text/html", "text": "<input name=\"csrf_token\" value=\"a1b2c3d4e5f6\"><input name=\"ref\" value=\"0123456789abcdef\">"}}},
+  {"startedDateTime": "2022-11-02T10:00:00.150Z", "time": 30, "_resourceType": "image",
+   "request": {"method": "GET", "url": "https://shop.example.com/logo.png", "headers": []},
+   "response": {"status": 200, "headers": [], "content": {"mimeType": "image/png"}}},
+  {"startedDateTime": "2022-11-02T10:00:00.160Z", "time": 40,
+   "request": {"method": "GET", "url": "https://cdn.analytics.example.org/collect?id=1", "headers": []},
+   "response": {"status": 204, "headers": [], "content": {"mimeType": ""}}},
+  {"startedDateTime": "2022-11-02T10:00:03.000Z", "time": 80, "_resourceType": "document",
+   "request": {"method": "POST", "url": "https://shop.example.com/login", "headers": [{"name": "Content-Type", "value": "application/x-www-form-urlencoded"}, {"name": "Content-Length", "value": "55"}],
+               "postData": {"mimeType": "application/x-www-form-urlencoded", "text": "user=alice&csrf_token=a1b2c3d4e5f6&ref=0123456789abcdef"}},
+   "response": {"status": 302, "redirectURL": "/account", "headers": [{"name": "Location", "value": "/account"}], "content": {"mimeType": "text/html"}}},
+  {"startedDateTime": "2022-11-02T10:00:03.090Z", "time": 60, "_resourceType": "document",
+   "request": {"method": "GET", "url": "https://shop.example.com/account", "headers": []},
+   "response": {"status": 200, "headers": [], "content": {"mimeType": "text/html"}}},
+  {"startedDateTime": "2022-11-02T10:00:03.200Z", "time": 50, "_resourceType": "xhr",
+   "request": {"method": "PUT", "url": "https://shop.example.com/api/cart?session=xyz", "headers": [{"name": "Content-Type", "value": "application/json"}],
+               "postData": {"mimeType": "application/json", "text": "{\"item\": 42}"}},
+   "response": {"status": 201, "headers": [], "content": {"mimeType": "application/json"}}}
+]}}`
+
+func TestGenerateScenarioFromHAR(t *testing.T) {
+	recorded, err := ReadHAR(strings.NewReader(testHAR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 6 || recorded[3].Duration != 80*time.Millisecond || !recorded[1].IsStaticAsset() || recorded[3].RedirectURL != "/account" {
+		t.Fatalf("unexpected recorded requests: %+v", recorded)
+	}
+	code, err := GenerateScenarioCode(recorded, CodegenOptions{FuncName: "checkout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(code)
+	for _, expected := range []string{
+		`var baseURL = "https://shop.example.com"`,
+		"func checkout(user *User) {\n\tvar body io.Reader\n",
+		`user.Step("GET /login")`,
+		`Request(http.MethodGet, baseURL+"/login")`,
+		`SetHeader("Accept", "text/html")`,
+		"\tuser.ThinkTime(2800 * time.Millisecond)\n\tuser.Step(\"POST /login\")",
+		`// TODO correlate form param "csrf_token" (name suggests a dynamic value)`,
+		`// TODO correlate form param "ref" (value was received in a previous response)`,
+		`SetFormParam("user", "alice")`,
+		"AssertStatusCode(200).\n\t\tArchiveStats()\n\tbody = strings.NewReader(`{\"item\": 42}`)",
+		`// TODO correlate query param "session" (name suggests a dynamic value)`,
+		`Request(http.MethodPut, baseURL+"/api/cart?session=xyz")`,
+		"SetBody(&body).\n\t\tSendWithTimeout(10 * time.Second).\n\t\tAssertStatusCode(201)",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected generated code to contain %q:\n%s", expected, src)
+		}
+	}
+	for _, unexpected := range []string{"logo.png", "analytics", "/account", "Cookie", "Content-Length", ":authority", `SetHeader("Content-Type", "application/x-www-form-urlencoded")`} {
+		if strings.Contains(src, unexpected) {
+			t.Errorf("expected generated code not to contain %q:\n%s", unexpected, src)
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "recording.har")
+	if err := os.WriteFile(filename, []byte(testHAR), 0644); err != nil {
+		t.Fatal(err)
+	}
+	code, err = GenerateScenarioFromHARFile(filename, CodegenOptions{IncludeStaticAssets: true, Hosts: []string{"shop.example.com", "cdn.analytics.example.org"}})
+	if err != nil || !strings.Contains(string(code), `baseURL+"/logo.png"`) || !strings.Contains(string(code), `"https://cdn.analytics.example.org/collect?id=1"`) ||
+		!strings.Contains(string(code), "// recordedScenario was generated from the HAR recording "+filename+".") {
+		t.Errorf("expected static assets and allowed hosts to be kept: %v\n%s", err, code)
+	}
+}
+
+func TestGenerateScenarioFollowingRedirects(t *testing.T) {
+	started := time.Date(2022, 11, 2, 10, 0, 0, 0, time.UTC)
+	form := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	recorded := []RecordedRequest{
+		{Started: started, Method: http.MethodPost, URL: "https://shop.example.com/login", Header: form, Body: []byte("tags=a&tags=b"),
+			StatusCode: http.StatusFound, RedirectURL: "/sso"},
+		{Started: started.Add(10 * time.Millisecond), Method: http.MethodGet, URL: "https://shop.example.com/api/ping", StatusCode: http.StatusNoContent},
+		{Started: started.Add(20 * time.Millisecond), Method: http.MethodGet, URL: "https://shop.example.com/sso", StatusCode: http.StatusFound, RedirectURL: "/account"},
+		{Started: started.Add(30 * time.Millisecond), Method: http.MethodGet, URL: "https://shop.example.com/account", StatusCode: http.StatusOK},
+	}
+	code, err := GenerateScenarioCode(recorded, CodegenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(code)
+	for _, expected := range []string{
+		"SetFormParam(\"tags\", \"a\").\n\t\tAddFormParam(\"tags\", \"b\").\n\t\tSendWithTimeout(10 * time.Second).\n\t\tAssertStatusCode(200)",
+		"Request(http.MethodGet, baseURL+\"/api/ping\").\n\t\tSendWithTimeout(10 * time.Second).\n\t\tAssertStatusCode(204)",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected generated code to contain %q:\n%s", expected, src)
+		}
+	}
+	if strings.Contains(src, "/sso") || strings.Contains(src, "/account") {
+		t.Errorf("expected followed redirects not to be requested explicitly:\n%s", src)
+	}
+}
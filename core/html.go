@@ -0,0 +1,211 @@
+package goverrun
+
+import (
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTMLForm is a form parsed from an HTML response (see Response.Forms).
+type HTMLForm struct {
+	ID, Name string
+	Action   string // absolute URL (resolved against the final URL of the response)
+	Method   string // upper case, defaults to GET
+	EncType  string
+	Fields   []HTMLFormField // in document order
+}
+
+// HTMLFormField is a successful control of a form (i.e. a control which would be submitted by a browser),
+// including hidden inputs. Submit buttons are not included.
+type HTMLFormField struct {
+	Name, Value, Type string
+}
+
+// HTMLLink is a link parsed from an HTML response (see Response.Links).
+type HTMLLink struct {
+	URL  string // absolute URL (resolved against the final URL of the response)
+	Text string
+}
+
+// Values returns the field values of the form.
+func (form *HTMLForm) Values() url.Values {
+	values := url.Values{}
+	for _, field := range form.Fields {
+		values.Add(field.Name, field.Value)
+	}
+	return values
+}
+
+// Value returns the value of the first field with the given name.
+func (form *HTMLForm) Value(name string) string {
+	for _, field := range form.Fields {
+		if field.Name == name {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// baseURL returns the URL relative references of the document are resolved against
+// (i.e. the final URL of the response, unless the document overrides it via <base href>).
+func (response *Response) baseURL(document *html.Node) *url.URL {
+	base, err := url.Parse(response.FinalURL)
+	if err != nil || len(response.FinalURL) == 0 {
+		base, _ = url.Parse(response.RequestURL)
+	}
+	if node := cascadia.MustCompile("base[href]").MatchFirst(document); node != nil {
+		href, _ := attribute(node, "href")
+		if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = u
+		}
+	}
+	return base
+}
+
+func resolveReference(base *url.URL, reference string) string {
+	u, err := base.Parse(strings.TrimSpace(reference))
+	if err != nil {
+		return reference
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// Forms returns all forms of the HTML response body.
+func (response *Response) Forms() (forms []*HTMLForm) {
+	document, err := response.HTMLDocument()
+	if err != nil {
+		return nil
+	}
+	base := response.baseURL(document)
+	for _, node := range cascadia.MustCompile("form").MatchAll(document) {
+		forms = append(forms, parseForm(node, base))
+	}
+	return forms
+}
+
+// Form returns the first form of the HTML response body matching the given CSS selector (like "#login" or "form[action*=search]").
+// When no form matches, the response is marked as failed and nil is returned.
+func (response *Response) Form(selector string) *HTMLForm {
+	compiled, err := cascadia.Compile(selector)
+	if err != nil {
+		LogError("invalid form selector:", err)
+		return nil
+	}
+	document, err := response.HTMLDocument()
+	if err == nil {
+		for _, node := range compiled.MatchAll(document) {
+			if node.Type == html.ElementNode && node.Data == "form" {
+				return parseForm(node, response.baseURL(document))
+			}
+		}
+	}
+	if !response.ConsideredUnsuccessful() {
+		response.MarkAsFailed("form not found: " + selector)
+	}
+	return nil
+}
+
+// Links returns all links (anchors with href) of the HTML response body.
+func (response *Response) Links() (links []*HTMLLink) {
+	document, err := response.HTMLDocument()
+	if err != nil {
+		return nil
+	}
+	base := response.baseURL(document)
+	for _, node := range cascadia.MustCompile("a[href]").MatchAll(document) {
+		href, _ := attribute(node, "href")
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(href)), "javascript:") {
+			continue
+		}
+		links = append(links, &HTMLLink{
+			URL:  resolveReference(base, href),
+			Text: strings.Join(strings.Fields(htmlquery.InnerText(node)), " "),
+		})
+	}
+	return links
+}
+
+func parseForm(node *html.Node, base *url.URL) *HTMLForm {
+	form := &HTMLForm{Method: http.MethodGet, EncType: "application/x-www-form-urlencoded"}
+	form.ID, _ = attribute(node, "id")
+	form.Name, _ = attribute(node, "name")
+	if method, _ := attribute(node, "method"); len(method) > 0 {
+		form.Method = strings.ToUpper(method)
+	}
+	if encType, _ := attribute(node, "enctype"); len(encType) > 0 {
+		form.EncType = encType
+	}
+	action, _ := attribute(node, "action")
+	form.Action = resolveReference(base, action)
+	for _, control := range cascadia.MustCompile("input[name], select[name], textarea[name]").MatchAll(node) {
+		if _, disabled := attribute(control, "disabled"); disabled {
+			continue
+		}
+		name, _ := attribute(control, "name")
+		switch control.Data {
+		case "input":
+			inputType, _ := attribute(control, "type")
+			inputType = strings.ToLower(inputType)
+			if len(inputType) == 0 {
+				inputType = "text"
+			}
+			switch inputType {
+			case "submit", "button", "image", "reset", "file":
+				continue
+			case "checkbox", "radio":
+				if _, checked := attribute(control, "checked"); !checked {
+					continue
+				}
+			}
+			value, hasValue := attribute(control, "value")
+			if !hasValue && (inputType == "checkbox" || inputType == "radio") {
+				value = "on"
+			}
+			form.Fields = append(form.Fields, HTMLFormField{Name: name, Value: value, Type: inputType})
+		case "textarea":
+			form.Fields = append(form.Fields, HTMLFormField{Name: name, Value: htmlquery.InnerText(control), Type: "textarea"})
+		case "select":
+			options := cascadia.MustCompile("option").MatchAll(control)
+			selected := -1
+			for i, option := range options {
+				if _, isSelected := attribute(option, "selected"); isSelected {
+					selected = i
+					break
+				}
+			}
+			if selected < 0 && len(options) > 0 {
+				selected = 0 // like browsers do: the first option is selected by default
+			}
+			if selected >= 0 {
+				value, hasValue := attribute(options[selected], "value")
+				if !hasValue {
+					value = strings.TrimSpace(htmlquery.InnerText(options[selected]))
+				}
+				form.Fields = append(form.Fields, HTMLFormField{Name: name, Value: value, Type: "select"})
+			}
+		}
+	}
+	return form
+}
+
+// SubmitForm creates a request submitting the given form (parsed via Response.Form or Response.Forms) with
+// all its field values (repeated names keep all their values) encoded according to the enctype of the form.
+// Fields can be overridden or added via SetFormParam and AddFormParam on the returned request.
+func (step *Step) SubmitForm(form *HTMLForm) *Request {
+	if form == nil {
+		LogError("unable to submit form: no form given")
+		form = &HTMLForm{Method: http.MethodGet}
+	}
+	request := step.Request(form.Method, form.Action)
+	for _, field := range form.Fields {
+		request.AddFormParam(field.Name, field.Value)
+	}
+	if strings.EqualFold(form.EncType, "multipart/form-data") && form.Method != http.MethodGet {
+		request.MultipartForm = true
+	}
+	return request
+}
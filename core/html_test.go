@@ -0,0 +1,74 @@
+package goverrun
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const formTestHTML = `<html><body>
+<a href="profile.page?runner=50#top">Runner  John</a> <a href="javascript:void(0)">ignored</a> <a href="/logout.page">Logout</a>
+<form id="search" action="search.page?scope=all"><input name="searchTerm" value="john"><input type="hidden" name="scope" value="runners">
+  <input type="hidden" name="scope" value="teams"><input type="submit" name="go" value="Go"></form>
+<form id="edit" action="update.page" method="post">
+  <input type="hidden" name="state" value="PG1hcC8+">
+  <input name="firstname" value="John">
+  <input type="checkbox" name="newsletter" checked>
+  <input type="checkbox" name="unchecked" value="x">
+  <input type="checkbox" name="tags" value="fast" checked><input type="checkbox" name="tags" value="slow" checked>
+  <input name="disabled" value="x" disabled>
+  <select name="country"><option value="de">Germany</option><option selected>Austria</option></select>
+  <textarea name="bio">Runs a lot</textarea>
+</form>
+<form id="upload" action="upload.page" method="post" enctype="multipart/form-data">
+  <input name="title" value="Race"><input type="hidden" name="tags" value="a"><input type="hidden" name="tags" value="b">
+</form>
+</body></html>`
+
+func TestFormsAndLinks(t *testing.T) {
+	var submitted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app/start.page" {
+			_, _ = fmt.Fprint(w, formTestHTML)
+			return
+		}
+		_ = r.ParseMultipartForm(1 << 20) // also parses URL-encoded forms
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		submitted = append(submitted, r.Method+" "+r.URL.Path+" "+mediaType+" "+r.Form.Encode())
+	}))
+	defer server.Close()
+
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: server.Client()}
+	response := user.Step("start").Request(http.MethodGet, server.URL+"/app/start.page").SendWithoutTimeout()
+	links := response.Links()
+	if len(links) != 2 || links[0].URL != server.URL+"/app/profile.page?runner=50" || links[0].Text != "Runner John" || links[1].URL != server.URL+"/logout.page" {
+		t.Errorf("unexpected links: %+v %+v", links[0], links[1])
+	}
+	if forms := response.Forms(); len(forms) != 3 {
+		t.Fatalf("got %d forms want 3", len(forms))
+	}
+	edit := response.Form("#edit")
+	if edit.Method != http.MethodPost || edit.Action != server.URL+"/app/update.page" || edit.Value("state") != "PG1hcC8+" {
+		t.Errorf("unexpected form: %+v", edit)
+	}
+	request := user.Step("submit edit").SubmitForm(edit)
+	if request.FormParams["tags"] != "fast" {
+		t.Errorf("expected the first of the repeated values in FormParams: %q", request.FormParams["tags"])
+	}
+	request.SetFormParam("firstname", "Edited").SetFormParam("tags", "medium").SendWithoutTimeout()
+	user.Step("submit search").SubmitForm(response.Form("#search")).SendWithoutTimeout()
+	user.Step("submit upload").SubmitForm(response.Form("#upload")).SendWithoutTimeout()
+	want := []string{
+		"POST /app/update.page application/x-www-form-urlencoded bio=Runs+a+lot&country=Austria&firstname=Edited&newsletter=on&state=PG1hcC8%2B&tags=medium",
+		"GET /app/search.page  scope=runners&scope=teams&searchTerm=john",
+		"POST /app/upload.page multipart/form-data tags=a&tags=b&title=Race",
+	}
+	if fmt.Sprint(submitted) != fmt.Sprint(want) {
+		t.Errorf("got %v want %v", submitted, want)
+	}
+	if response.Form("#missing") != nil || response.AssertionFailed != "form not found: #missing" {
+		t.Errorf("expected missing form to fail the response: %s", response.AssertionFailed)
+	}
+}
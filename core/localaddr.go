@@ -0,0 +1,55 @@
+package goverrun
+
+import (
+	"fmt"
+	"net"
+)
+
+// LocalAddressStrategy defines how the local (source) addresses are assigned to the outgoing connections.
+type LocalAddressStrategy int
+
+const (
+	// LocalAddressPerUser binds all connections of a user to the same local address (chosen by user number).
+	LocalAddressPerUser LocalAddressStrategy = iota
+	// LocalAddressRoundRobin binds every new connection to the next local address.
+	LocalAddressRoundRobin
+)
+
+// localAddressRoundRobin tracks the round-robin position per scenario.
+var localAddressRoundRobin = safeTracker{counters: make(map[string]int)}
+
+// validateLocalAddresses checks the local addresses to be IPs.
+func (config TransportConfig) validateLocalAddresses() error {
+	for _, address := range config.LocalAddresses {
+		if net.ParseIP(address) == nil {
+			return fmt.Errorf("invalid local address '%s' (must be an IP)", address)
+		}
+	}
+	if config.LocalAddressStrategy != LocalAddressPerUser && config.LocalAddressStrategy != LocalAddressRoundRobin {
+		return fmt.Errorf("unknown local address strategy %d", config.LocalAddressStrategy)
+	}
+	return nil
+}
+
+// localAddress returns the local address to bind the next TCP connection or UDP socket (of QUIC) to (nil for any).
+// Transports shared by all users (i.e. without user) always assign the addresses round-robin.
+func (dialer *countingDialer) localAddress(network string) net.Addr {
+	if len(dialer.localAddresses) == 0 {
+		return nil
+	}
+	udp := network == "udp" || network == "udp4" || network == "udp6"
+	if !udp && network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil
+	}
+	var index int
+	if dialer.localAddressStrategy == LocalAddressPerUser && dialer.currentUser > 0 {
+		index = (dialer.currentUser - 1) % len(dialer.localAddresses)
+	} else {
+		index = (localAddressRoundRobin.Inc(dialer.scenario) - 1) % len(dialer.localAddresses)
+	}
+	ip := net.ParseIP(dialer.localAddresses[index])
+	if udp {
+		return &net.UDPAddr{IP: ip}
+	}
+	return &net.TCPAddr{IP: ip}
+}
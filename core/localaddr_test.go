@@ -0,0 +1,72 @@
+package goverrun
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/quic-go/quic-go/http3"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalAddresses(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		_, _ = fmt.Fprint(w, host)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	defer Reset()
+	localAddresses := []string{"127.0.0.2", "127.0.0.3"}
+
+	for _, test := range []struct {
+		strategy    LocalAddressStrategy
+		currentUser int
+		want        []string
+	}{
+		{LocalAddressPerUser, 1, []string{"127.0.0.2", "127.0.0.2", "127.0.0.2"}},
+		{LocalAddressPerUser, 2, []string{"127.0.0.3", "127.0.0.3", "127.0.0.3"}},
+		{LocalAddressRoundRobin, 1, []string{"127.0.0.2", "127.0.0.3", "127.0.0.2"}},
+	} {
+		config := TransportConfig{LocalAddresses: localAddresses, LocalAddressStrategy: test.strategy}
+		if err := config.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		scenario := fmt.Sprint("local address test ", test.strategy, test.currentUser)
+		transport := wrapRoundTripper(config, newRoundTripper(config, ConnectionPolicy{DisableKeepAlive: true}, &tls.Config{}, scenario, test.currentUser), test.currentUser)
+		user := &User{Scenario: scenario, CurrentUser: test.currentUser, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport}}
+		for i, want := range test.want {
+			response := user.Step("request").Request(http.MethodGet, server.URL).SendWithoutTimeout()
+			if response.Error != nil || string(response.Body) != want || response.stepEntry().LocalAddress != want {
+				t.Errorf("strategy %d user %d request %d: got %q recorded as %q (%v) want %s", test.strategy, test.currentUser, i, response.Body, response.LocalAddress, response.Error, want)
+			}
+		}
+	}
+
+	tlsServer := httptest.NewTLSServer(handler) // for its certificate
+	defer tlsServer.Close()
+	h3Listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h3Server := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(tlsServer.TLS.Clone())}
+	go func() { _ = h3Server.Serve(h3Listener) }()
+	defer h3Server.Close()
+	config := TransportConfig{Protocol: ProtocolHTTP3, LocalAddresses: localAddresses}
+	config.TLS.InsecureSkipVerify = true
+	transport, err := NewRoundTripperWrapperWithConfig(config, ConnectionPolicy{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+	user := &User{CurrentUser: 2, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport}}
+	response := user.Step("request").Request(http.MethodGet, "https://"+h3Listener.LocalAddr().String()).SendWithoutTimeout()
+	if response.Error != nil || string(response.Body) != "127.0.0.3" {
+		t.Errorf("expected HTTP/3 to be sent from the local address of the user: got %q (%v)", response.Body, response.Error)
+	}
+
+	if err := (TransportConfig{LocalAddresses: []string{"eth0"}}).Validate(); err == nil {
+		t.Error("expected non-IP local address to be rejected")
+	}
+}
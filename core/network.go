@@ -0,0 +1,205 @@
+package goverrun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NetworkProfile emulates the network conditions of a user (like a mobile client) by limiting the bandwidth
+// and adding latency to every request sent over the network (responses served by the HTTP cache are not affected).
+// Slow clients thereby also occupy the server connections for as long as real ones would.
+type NetworkProfile struct {
+	Name                   string
+	DownloadBytesPerSecond int           // zero means unlimited
+	UploadBytesPerSecond   int           // zero means unlimited
+	Latency                time.Duration // round-trip time added to every request
+}
+
+// predefined network profiles (similar to the throttling presets of browser developer tools)
+var (
+	NetworkGPRS  = NetworkProfile{Name: "GPRS", DownloadBytesPerSecond: 50000 / 8, UploadBytesPerSecond: 20000 / 8, Latency: 500 * time.Millisecond}
+	Network2G    = NetworkProfile{Name: "2G", DownloadBytesPerSecond: 250000 / 8, UploadBytesPerSecond: 50000 / 8, Latency: 300 * time.Millisecond}
+	Network3G    = NetworkProfile{Name: "3G", DownloadBytesPerSecond: 1600000 / 8, UploadBytesPerSecond: 750000 / 8, Latency: 150 * time.Millisecond}
+	Network4G    = NetworkProfile{Name: "4G", DownloadBytesPerSecond: 9000000 / 8, UploadBytesPerSecond: 3000000 / 8, Latency: 60 * time.Millisecond}
+	NetworkDSL   = NetworkProfile{Name: "DSL", DownloadBytesPerSecond: 16000000 / 8, UploadBytesPerSecond: 2000000 / 8, Latency: 20 * time.Millisecond}
+	NetworkCable = NetworkProfile{Name: "Cable", DownloadBytesPerSecond: 100000000 / 8, UploadBytesPerSecond: 10000000 / 8, Latency: 10 * time.Millisecond}
+)
+
+// NetworkProfileByName returns the predefined network profile with the given (case-insensitive) name.
+func NetworkProfileByName(name string) (NetworkProfile, bool) {
+	for _, profile := range []NetworkProfile{NetworkGPRS, Network2G, Network3G, Network4G, NetworkDSL, NetworkCable} {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, true
+		}
+	}
+	return NetworkProfile{}, false
+}
+
+// Validate checks the profile for invalid values.
+func (profile NetworkProfile) Validate() error {
+	if profile.DownloadBytesPerSecond < 0 || profile.UploadBytesPerSecond < 0 || profile.Latency < 0 {
+		return fmt.Errorf("negative values in network profile '%s'", profile.Name)
+	}
+	return nil
+}
+
+func (profile NetworkProfile) enabled() bool {
+	return profile.DownloadBytesPerSecond > 0 || profile.UploadBytesPerSecond > 0 || profile.Latency > 0
+}
+
+func (profile NetworkProfile) String() string {
+	if !profile.enabled() {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%s (down %d B/s, up %d B/s, latency %s)", profile.Name, profile.DownloadBytesPerSecond, profile.UploadBytesPerSecond, profile.Latency)
+}
+
+// networkProfile returns the network profile of the given user (one-based).
+func (config TransportConfig) networkProfile(currentUser int) NetworkProfile {
+	if len(config.NetworkMix) > 0 && currentUser > 0 {
+		return config.NetworkMix[(currentUser-1)%len(config.NetworkMix)]
+	}
+	return config.Network
+}
+
+// EmulateNetwork applies the network profile to all further requests (an empty profile disables the emulation).
+func (trans *RoundTripperWrapper) EmulateNetwork(profile NetworkProfile) {
+	if !profile.enabled() {
+		trans.network = nil
+		return
+	}
+	trans.network = &emulatedNetwork{
+		profile:  profile,
+		next:     trans.realRoundTripper,
+		download: newBandwidth(profile.DownloadBytesPerSecond),
+		upload:   newBandwidth(profile.UploadBytesPerSecond),
+	}
+}
+
+// Network returns the emulated network profile.
+func (trans *RoundTripperWrapper) Network() NetworkProfile {
+	if trans.network == nil {
+		return NetworkProfile{}
+	}
+	return trans.network.profile
+}
+
+// emulatedNetwork is the round-tripper throttling the traffic of a single user. The bandwidth is shared
+// by all concurrent requests of the user (like parallel downloads of embedded resources).
+type emulatedNetwork struct {
+	profile          NetworkProfile
+	next             http.RoundTripper
+	download, upload *bandwidth
+}
+
+func (network *emulatedNetwork) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := sleep(ctx, network.profile.Latency/2); err != nil {
+		return nil, err
+	}
+	if err := network.upload.transfer(ctx, HeaderSize(req.Header)); err != nil {
+		return nil, err
+	}
+	if req.Body != nil && network.upload != nil {
+		req = req.Clone(ctx)
+		req.Body = &throttledBody{ReadCloser: req.Body, bandwidth: network.upload, ctx: ctx}
+	}
+	resp, err := network.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := sleep(ctx, network.profile.Latency/2); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if err := network.download.transfer(ctx, HeaderSize(resp.Header)); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if resp.Body != nil && network.download != nil {
+		resp.Body = &throttledBody{ReadCloser: resp.Body, bandwidth: network.download, ctx: ctx}
+	}
+	return resp, nil
+}
+
+func (network *emulatedNetwork) CloseIdleConnections() {
+	if transport, ok := network.next.(interface{ CloseIdleConnections() }); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// bandwidth limits the transferred bytes per second.
+type bandwidth struct {
+	bytesPerSecond int
+	lock           sync.Mutex
+	availableAt    time.Time // when all bytes transferred so far went through
+}
+
+// newBandwidth returns nil (i.e. unlimited) for non-positive rates.
+func newBandwidth(bytesPerSecond int) *bandwidth {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidth{bytesPerSecond: bytesPerSecond}
+}
+
+// transfer waits until the given number of bytes went through.
+func (b *bandwidth) transfer(ctx context.Context, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+	b.lock.Lock()
+	now := time.Now()
+	if b.availableAt.Before(now) {
+		b.availableAt = now
+	}
+	b.availableAt = b.availableAt.Add(time.Duration(n) * time.Second / time.Duration(b.bytesPerSecond))
+	wait := b.availableAt.Sub(now)
+	b.lock.Unlock()
+	return sleep(ctx, wait)
+}
+
+// chunkSize is the amount of bytes to read at once so that the transfer is smooth (ten chunks per second).
+func (b *bandwidth) chunkSize() int {
+	if size := b.bytesPerSecond / 10; size > 512 {
+		return size
+	}
+	return 512
+}
+
+type throttledBody struct {
+	io.ReadCloser
+	bandwidth *bandwidth
+	ctx       context.Context
+}
+
+func (body *throttledBody) Read(p []byte) (int, error) {
+	if size := body.bandwidth.chunkSize(); len(p) > size {
+		p = p[:size]
+	}
+	n, err := body.ReadCloser.Read(p)
+	if throttleErr := body.bandwidth.transfer(body.ctx, n); throttleErr != nil {
+		return n, throttleErr
+	}
+	return n, err
+}
+
+// sleep waits for the given duration unless the context is done before.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package goverrun
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetworkEmulation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 20000))
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		profile  NetworkProfile
+		method   string
+		form     string
+		min, max time.Duration
+	}{
+		{NetworkProfile{}, http.MethodGet, "", 0, 100 * time.Millisecond},
+		{NetworkProfile{Name: "slow download", DownloadBytesPerSecond: 100000}, http.MethodGet, "", 200 * time.Millisecond, time.Second},
+		{NetworkProfile{Name: "slow upload", UploadBytesPerSecond: 50000}, http.MethodPost, strings.Repeat("y", 10000), 200 * time.Millisecond, time.Second},
+		{NetworkProfile{Name: "latency", Latency: 300 * time.Millisecond}, http.MethodGet, "", 300 * time.Millisecond, time.Second},
+	} {
+		transport := wrapRoundTripper(TransportConfig{Network: test.profile}, newRoundTripper(TransportConfig{}, ConnectionPolicy{}, &tls.Config{}, "network test", 1), 1)
+		if transport.Network() != test.profile {
+			t.Errorf("got profile %v want %v", transport.Network(), test.profile)
+		}
+		user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport}}
+		start := time.Now()
+		request := user.Step(test.profile.Name).Request(test.method, server.URL)
+		if len(test.form) > 0 {
+			request.SetFormParam("data", test.form)
+		}
+		response := request.SendWithoutTimeout()
+		if took := time.Since(start); response.Error != nil || len(response.Body) != 20000 || took < test.min || took > test.max {
+			t.Errorf("%v: took %v (%v) want between %v and %v", test.profile, took, response.Error, test.min, test.max)
+		}
+	}
+}
+
+func TestNetworkMix(t *testing.T) {
+	config := TransportConfig{Network: NetworkDSL, NetworkMix: []NetworkProfile{Network3G, NetworkCable}}
+	for currentUser, want := range map[int]NetworkProfile{0: NetworkDSL, 1: Network3G, 2: NetworkCable, 3: Network3G} {
+		if got := config.networkProfile(currentUser); got != want {
+			t.Errorf("user %d: got %v want %v", currentUser, got, want)
+		}
+	}
+	if profile, found := NetworkProfileByName("3g"); !found || profile != Network3G {
+		t.Errorf("got %v (%v) want 3G profile", profile, found)
+	}
+	if err := (TransportConfig{NetworkMix: []NetworkProfile{{Name: "broken", Latency: -time.Second}}}).Validate(); err == nil {
+		t.Error("expected negative latency to be rejected")
+	}
+}
@@ -0,0 +1,913 @@
+package goverrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// OpenAPISpec is the subset of an OpenAPI 3 specification needed for generating scenarios and validating responses.
+type OpenAPISpec struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]*OpenAPIPathItem `json:"paths"`
+	Components struct {
+		Schemas       map[string]*OpenAPISchema      `json:"schemas"`
+		Responses     map[string]*OpenAPIResponse    `json:"responses"`
+		Parameters    map[string]*OpenAPIParameter   `json:"parameters"`
+		RequestBodies map[string]*OpenAPIRequestBody `json:"requestBodies"`
+	} `json:"components"`
+}
+
+type OpenAPIPathItem struct {
+	Parameters []*OpenAPIParameter `json:"parameters"`
+	Get        *OpenAPIOperation   `json:"get"`
+	Put        *OpenAPIOperation   `json:"put"`
+	Post       *OpenAPIOperation   `json:"post"`
+	Delete     *OpenAPIOperation   `json:"delete"`
+	Options    *OpenAPIOperation   `json:"options"`
+	Head       *OpenAPIOperation   `json:"head"`
+	Patch      *OpenAPIOperation   `json:"patch"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Parameters  []*OpenAPIParameter         `json:"parameters"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Ref      string         `json:"$ref"`
+	Name     string         `json:"name"`
+	In       string         `json:"in"` // path, query, header or cookie
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+	Example  interface{}    `json:"example"`
+}
+
+type OpenAPIRequestBody struct {
+	Ref      string                       `json:"$ref"`
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Ref         string                       `json:"$ref"`
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema   *OpenAPISchema `json:"schema"`
+	Example  interface{}    `json:"example"`
+	Examples map[string]struct {
+		Value interface{} `json:"value"`
+	} `json:"examples"`
+}
+
+// OpenAPISchema is the subset of JSON schema used by OpenAPI 3.0 and 3.1 supported for validation.
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref"`
+	Type                 interface{}               `json:"type"` // a type name or (OpenAPI 3.1) a list of type names
+	Format               string                    `json:"format"`
+	Nullable             bool                      `json:"nullable"`
+	Enum                 []interface{}             `json:"enum"`
+	Properties           map[string]*OpenAPISchema `json:"properties"`
+	Required             []string                  `json:"required"`
+	AdditionalProperties interface{}               `json:"additionalProperties"` // false or a schema
+	Items                *OpenAPISchema            `json:"items"`
+	AllOf                []*OpenAPISchema          `json:"allOf"`
+	AnyOf                []*OpenAPISchema          `json:"anyOf"`
+	OneOf                []*OpenAPISchema          `json:"oneOf"`
+	Minimum              *float64                  `json:"minimum"`
+	Maximum              *float64                  `json:"maximum"`
+	ExclusiveMinimum     interface{}               `json:"exclusiveMinimum"` // a flag (OpenAPI 3.0) or a number (OpenAPI 3.1)
+	ExclusiveMaximum     interface{}               `json:"exclusiveMaximum"`
+	MinLength            *int                      `json:"minLength"`
+	MaxLength            *int                      `json:"maxLength"`
+	Pattern              string                    `json:"pattern"`
+	MinItems             *int                      `json:"minItems"`
+	MaxItems             *int                      `json:"maxItems"`
+	Example              interface{}               `json:"example"`
+	Default              interface{}               `json:"default"`
+	// internal
+	pattern              *regexp.Regexp // compiled Pattern
+	additionalProperties *OpenAPISchema // AdditionalProperties if it is a schema
+}
+
+// SchemaViolation is a mismatch of a response with the spec. The pointer (RFC 6901) locates the violating value in the body.
+type SchemaViolation struct {
+	Pointer string
+	Message string
+	// internal
+	inBody bool // the pointer is meaningful (the empty pointer locates the whole body)
+}
+
+func (v SchemaViolation) String() string {
+	if !v.inBody {
+		return v.Message
+	}
+	return fmt.Sprintf("%s at JSON pointer %q", v.Message, v.Pointer)
+}
+
+func addViolation(violations *[]SchemaViolation, pointer, format string, args ...interface{}) {
+	*violations = append(*violations, SchemaViolation{Pointer: pointer, Message: fmt.Sprintf(format, args...), inBody: true})
+}
+
+// maxSchemaDepth stops resolving recursive references (like tree structures) when generating examples.
+const maxSchemaDepth = 8
+
+// LoadOpenAPISpec reads an OpenAPI 3 specification in YAML or JSON format.
+func LoadOpenAPISpec(filename string) (*OpenAPISpec, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseOpenAPISpec(data)
+}
+
+// ParseOpenAPISpec parses an OpenAPI 3 specification in YAML or JSON format.
+func ParseOpenAPISpec(data []byte) (*OpenAPISpec, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse OpenAPI spec: %w", err)
+	}
+	// YAML allows non-string keys (like unquoted status codes), so it is converted to JSON before mapping the structure
+	data, err := json.Marshal(jsonCompatible(doc))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse OpenAPI spec: %w", err)
+	}
+	spec := &OpenAPISpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("unable to parse OpenAPI spec: %w", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q (only OpenAPI 3 is supported)", spec.OpenAPI)
+	}
+	if err := spec.compileSchemas(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// compileSchemas compiles the patterns of all schemas of the spec once, instead of on every validated string.
+func (spec *OpenAPISpec) compileSchemas() error {
+	for name, schema := range spec.Components.Schemas {
+		if err := compileSchema(schema, "#/components/schemas/"+escapeJSONPointer(name)); err != nil {
+			return err
+		}
+	}
+	for name, parameter := range spec.Components.Parameters {
+		if err := compileSchema(parameter.Schema, "#/components/parameters/"+escapeJSONPointer(name)+"/schema"); err != nil {
+			return err
+		}
+	}
+	for name, response := range spec.Components.Responses {
+		if err := compileContentSchemas(response.Content, "#/components/responses/"+escapeJSONPointer(name)); err != nil {
+			return err
+		}
+	}
+	for name, body := range spec.Components.RequestBodies {
+		if err := compileContentSchemas(body.Content, "#/components/requestBodies/"+escapeJSONPointer(name)); err != nil {
+			return err
+		}
+	}
+	for template, item := range spec.Paths {
+		pointer := "#/paths/" + escapeJSONPointer(template)
+		for i, parameter := range item.Parameters {
+			if err := compileSchema(parameter.Schema, fmt.Sprintf("%s/parameters/%d/schema", pointer, i)); err != nil {
+				return err
+			}
+		}
+		for method, operation := range item.operations() {
+			pointer := pointer + "/" + strings.ToLower(method)
+			for i, parameter := range operation.Parameters {
+				if err := compileSchema(parameter.Schema, fmt.Sprintf("%s/parameters/%d/schema", pointer, i)); err != nil {
+					return err
+				}
+			}
+			if operation.RequestBody != nil {
+				if err := compileContentSchemas(operation.RequestBody.Content, pointer+"/requestBody"); err != nil {
+					return err
+				}
+			}
+			for status, response := range operation.Responses {
+				if err := compileContentSchemas(response.Content, pointer+"/responses/"+escapeJSONPointer(status)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func compileContentSchemas(content map[string]*OpenAPIMediaType, pointer string) error {
+	for contentType, mediaType := range content {
+		if mediaType == nil {
+			continue
+		}
+		if err := compileSchema(mediaType.Schema, pointer+"/content/"+escapeJSONPointer(contentType)+"/schema"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileSchema compiles the pattern of the schema and its subschemas, the pointer locates the schema in the spec.
+func compileSchema(schema *OpenAPISchema, pointer string) error {
+	if schema == nil {
+		return nil
+	}
+	if len(schema.Pattern) > 0 {
+		pattern, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q of schema %s: %w", schema.Pattern, pointer, err)
+		}
+		schema.pattern = pattern
+	}
+	for name, property := range schema.Properties {
+		if err := compileSchema(property, pointer+"/properties/"+escapeJSONPointer(name)); err != nil {
+			return err
+		}
+	}
+	if additional, ok := schema.AdditionalProperties.(map[string]interface{}); ok {
+		schema.additionalProperties = &OpenAPISchema{}
+		data, err := json.Marshal(additional)
+		if err == nil {
+			err = json.Unmarshal(data, schema.additionalProperties)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid schema %s/additionalProperties: %w", pointer, err)
+		}
+		if err := compileSchema(schema.additionalProperties, pointer+"/additionalProperties"); err != nil {
+			return err
+		}
+	}
+	if err := compileSchema(schema.Items, pointer+"/items"); err != nil {
+		return err
+	}
+	for keyword, schemas := range map[string][]*OpenAPISchema{"allOf": schema.AllOf, "anyOf": schema.AnyOf, "oneOf": schema.OneOf} {
+		for i, sub := range schemas {
+			if err := compileSchema(sub, fmt.Sprintf("%s/%s/%d", pointer, keyword, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonCompatible(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonCompatible(item)
+		}
+		return v
+	case time.Time: // unquoted YAML timestamps in examples
+		return v.Format(time.RFC3339)
+	}
+	return value
+}
+
+// operations returns the operations of the path item by method.
+func (item *OpenAPIPathItem) operations() map[string]*OpenAPIOperation {
+	operations := make(map[string]*OpenAPIOperation)
+	for method, operation := range map[string]*OpenAPIOperation{
+		http.MethodGet: item.Get, http.MethodPut: item.Put, http.MethodPost: item.Post, http.MethodDelete: item.Delete,
+		http.MethodOptions: item.Options, http.MethodHead: item.Head, http.MethodPatch: item.Patch,
+	} {
+		if operation != nil {
+			operations[method] = operation
+		}
+	}
+	return operations
+}
+
+// basePath returns the path of the first server URL (like /v1), the paths of the spec are relative to it.
+func (spec *OpenAPISpec) basePath() string {
+	if len(spec.Servers) == 0 {
+		return ""
+	}
+	u, err := url.Parse(spec.Servers[0].URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// FindOperation returns the operation matching the method and the URL path (including the base path of the server)
+// together with its path template.
+func (spec *OpenAPISpec) FindOperation(method, path string) (operation *OpenAPIOperation, template string, ok bool) {
+	path = strings.TrimPrefix(path, spec.basePath())
+	var candidates []string
+	for template := range spec.Paths {
+		candidates = append(candidates, template)
+	}
+	// concrete paths (like /pets/mine) take precedence over templated ones (like /pets/{id})
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := strings.Count(candidates[i], "{"), strings.Count(candidates[j], "{")
+		if ci != cj {
+			return ci < cj
+		}
+		return candidates[i] < candidates[j]
+	})
+	for _, template := range candidates {
+		if !matchesPathTemplate(template, path) {
+			continue
+		}
+		if operation := spec.Paths[template].operations()[strings.ToUpper(method)]; operation != nil {
+			return operation, template, true
+		}
+	}
+	return nil, "", false
+}
+
+func matchesPathTemplate(template, path string) bool {
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(templateSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range templateSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if len(pathSegments[i]) == 0 {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateResponse validates the status code, content type and body of a response to the operation against the spec.
+func (spec *OpenAPISpec) ValidateResponse(operation *OpenAPIOperation, statusCode int, contentType string, body []byte) []SchemaViolation {
+	code := strconv.Itoa(statusCode)
+	documented := operation.Responses[code]
+	if documented == nil {
+		documented = operation.Responses[code[:1]+"XX"]
+	}
+	if documented == nil {
+		documented = operation.Responses[code[:1]+"xx"]
+	}
+	if documented == nil {
+		documented = operation.Responses["default"]
+	}
+	if documented == nil {
+		return []SchemaViolation{{Message: fmt.Sprintf("status code %d not documented", statusCode)}}
+	}
+	documented = spec.resolveResponse(documented)
+	if len(documented.Content) == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	media := documentedMediaType(documented.Content, strings.ToLower(mediaType))
+	if media == nil {
+		return []SchemaViolation{{Message: fmt.Sprintf("content type %q not documented for status code %d", mediaType, statusCode)}}
+	}
+	if media.Schema == nil || !strings.Contains(mediaType, "json") {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []SchemaViolation{{Message: "body is not valid JSON"}}
+	}
+	var violations []SchemaViolation
+	spec.validate(media.Schema, value, "", &violations)
+	return violations
+}
+
+// documentedMediaType finds the media type by exact match or by wildcards (like application/* or */*).
+func documentedMediaType(content map[string]*OpenAPIMediaType, mediaType string) *OpenAPIMediaType {
+	for documented, media := range content {
+		if strings.ToLower(documented) == mediaType {
+			return media
+		}
+	}
+	for documented, media := range content {
+		documented = strings.ToLower(documented)
+		if documented == "*/*" || (strings.HasSuffix(documented, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(documented, "*"))) {
+			return media
+		}
+	}
+	return nil
+}
+
+func (spec *OpenAPISpec) resolveSchema(schema *OpenAPISchema) *OpenAPISchema {
+	for i := 0; schema != nil && len(schema.Ref) > 0 && i < maxSchemaDepth; i++ {
+		schema = spec.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (spec *OpenAPISpec) resolveResponse(response *OpenAPIResponse) *OpenAPIResponse {
+	if resolved := spec.Components.Responses[strings.TrimPrefix(response.Ref, "#/components/responses/")]; len(response.Ref) > 0 && resolved != nil {
+		return resolved
+	}
+	return response
+}
+
+func (spec *OpenAPISpec) resolveParameter(parameter *OpenAPIParameter) *OpenAPIParameter {
+	if resolved := spec.Components.Parameters[strings.TrimPrefix(parameter.Ref, "#/components/parameters/")]; len(parameter.Ref) > 0 && resolved != nil {
+		return resolved
+	}
+	return parameter
+}
+
+func (spec *OpenAPISpec) resolveRequestBody(body *OpenAPIRequestBody) *OpenAPIRequestBody {
+	if resolved := spec.Components.RequestBodies[strings.TrimPrefix(body.Ref, "#/components/requestBodies/")]; len(body.Ref) > 0 && resolved != nil {
+		return resolved
+	}
+	return body
+}
+
+func (schema *OpenAPISchema) types() []string {
+	switch t := schema.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			types = append(types, fmt.Sprint(item))
+		}
+		return types
+	}
+	return nil
+}
+
+func (spec *OpenAPISpec) validate(schema *OpenAPISchema, value interface{}, pointer string, violations *[]SchemaViolation) {
+	schema = spec.resolveSchema(schema)
+	if schema == nil {
+		return
+	}
+	for _, sub := range schema.AllOf {
+		spec.validate(sub, value, pointer, violations)
+	}
+	if len(schema.AnyOf) > 0 && spec.countMatches(schema.AnyOf, value) == 0 {
+		addViolation(violations, pointer, "body value matches no schema of anyOf")
+	}
+	if len(schema.OneOf) > 0 {
+		if matches := spec.countMatches(schema.OneOf, value); matches != 1 {
+			addViolation(violations, pointer, "body value matches %d schemas of oneOf (exactly one expected)", matches)
+		}
+	}
+	types := schema.types()
+	if value == nil {
+		if len(types) > 0 && !schema.Nullable && !containsString(types, "null") {
+			addViolation(violations, pointer, "body value is null but not nullable")
+		}
+		return
+	}
+	if len(types) > 0 {
+		actual := jsonType(value)
+		if !containsString(types, actual) && !(actual == "integer" && containsString(types, "number")) {
+			addViolation(violations, pointer, "body value has type %s but expected %s", actual, strings.Join(types, " or "))
+			return
+		}
+	}
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			addViolation(violations, pointer, "body value is not in enum")
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				addViolation(violations, pointer+"/"+escapeJSONPointer(name), "body is missing required property")
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				spec.validate(property, v[name], pointer+"/"+escapeJSONPointer(name), violations)
+				continue
+			}
+			if schema.AdditionalProperties == false {
+				addViolation(violations, pointer+"/"+escapeJSONPointer(name), "body has additional property not allowed")
+			} else if schema.additionalProperties != nil {
+				spec.validate(schema.additionalProperties, v[name], pointer+"/"+escapeJSONPointer(name), violations)
+			}
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			addViolation(violations, pointer, "body array has less than %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			addViolation(violations, pointer, "body array has more than %d items", *schema.MaxItems)
+		}
+		if schema.Items != nil {
+			for i, item := range v {
+				spec.validate(schema.Items, item, pointer+"/"+strconv.Itoa(i), violations)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			addViolation(violations, pointer, "body string is shorter than %d characters", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			addViolation(violations, pointer, "body string is longer than %d characters", *schema.MaxLength)
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(v) {
+			addViolation(violations, pointer, "body string does not match pattern %s", schema.Pattern)
+		}
+		if !matchesFormat(schema.Format, v) {
+			addViolation(violations, pointer, "body string is not of format %s", schema.Format)
+		}
+	case float64:
+		if schema.Minimum != nil && (v < *schema.Minimum || (v == *schema.Minimum && schema.ExclusiveMinimum == true)) {
+			addViolation(violations, pointer, "body number is less than minimum %v", *schema.Minimum)
+		}
+		if limit, ok := schema.ExclusiveMinimum.(float64); ok && v <= limit {
+			addViolation(violations, pointer, "body number is not greater than exclusive minimum %v", limit)
+		}
+		if schema.Maximum != nil && (v > *schema.Maximum || (v == *schema.Maximum && schema.ExclusiveMaximum == true)) {
+			addViolation(violations, pointer, "body number is greater than maximum %v", *schema.Maximum)
+		}
+		if limit, ok := schema.ExclusiveMaximum.(float64); ok && v >= limit {
+			addViolation(violations, pointer, "body number is not less than exclusive maximum %v", limit)
+		}
+	}
+}
+
+func (spec *OpenAPISpec) countMatches(schemas []*OpenAPISchema, value interface{}) int {
+	matches := 0
+	for _, schema := range schemas {
+		var violations []SchemaViolation
+		spec.validate(schema, value, "", &violations)
+		if len(violations) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "null"
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// matchesFormat checks the common string formats (unknown formats are not validated).
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	}
+	return true
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertMatchesOpenAPI validates the status code, content type and (JSON) body of the response against the operation
+// of the spec matching the request method and path. The first violation (located by JSON pointer) becomes the root cause.
+func (response *Response) AssertMatchesOpenAPI(spec *OpenAPISpec) *Response {
+	if response.ConsideredUnsuccessful() {
+		return response // earlier checked assertion already failed or error or timeout happened
+	}
+	u, err := url.Parse(response.RequestURL)
+	if err != nil {
+		response.MarkAsFailed(fmt.Sprint("assertion of OpenAPI response failed (invalid request URL): ", response.RequestURL))
+		return response
+	}
+	operation, template, ok := spec.FindOperation(response.RequestMethod, u.Path)
+	if !ok {
+		response.MarkAsFailed(fmt.Sprint("assertion of OpenAPI response failed (no operation documented): ", response.RequestMethod, " ", u.Path))
+		return response
+	}
+	violations := spec.ValidateResponse(operation, response.StatusCode, response.Header.Get("Content-Type"), response.Body)
+	if len(violations) > 0 {
+		response.MarkAsFailed(fmt.Sprint("assertion of OpenAPI response of ", response.RequestMethod, " ", template, " failed: ", violations[0]))
+	} else {
+		response.markAsPassed("assertion of OpenAPI response of %s %s passed", response.RequestMethod, template)
+	}
+	return response
+}
+
+// Example returns an example value of the schema: its (or its first enum) example, otherwise one built from its type.
+func (spec *OpenAPISpec) Example(schema *OpenAPISchema) interface{} {
+	return spec.example(schema, 0)
+}
+
+func (spec *OpenAPISpec) example(schema *OpenAPISchema, depth int) interface{} {
+	schema = spec.resolveSchema(schema)
+	if schema == nil || depth > maxSchemaDepth {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	case len(schema.AllOf) > 0:
+		merged := make(map[string]interface{})
+		for _, sub := range schema.AllOf {
+			if object, ok := spec.example(sub, depth+1).(map[string]interface{}); ok {
+				for key, value := range object {
+					merged[key] = value
+				}
+			}
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return spec.example(schema.OneOf[0], depth+1)
+	case len(schema.AnyOf) > 0:
+		return spec.example(schema.AnyOf[0], depth+1)
+	}
+	types := schema.types()
+	if len(types) == 0 && len(schema.Properties) > 0 {
+		types = []string{"object"}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	switch types[0] {
+	case "object":
+		object := make(map[string]interface{})
+		for name, property := range schema.Properties {
+			object[name] = spec.example(property, depth+1)
+		}
+		return object
+	case "array":
+		return []interface{}{spec.example(schema.Items, depth+1)}
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			return "2022-01-01T00:00:00Z"
+		case "date":
+			return "2022-01-01"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "email":
+			return "user@example.com"
+		}
+		return "string"
+	}
+	return nil
+}
+
+// parameterExample returns an example value of the parameter in its string form.
+func (spec *OpenAPISpec) parameterExample(parameter *OpenAPIParameter) string {
+	if parameter.Example != nil {
+		return jsonValueToString(parameter.Example)
+	}
+	if example := spec.Example(parameter.Schema); example != nil {
+		return jsonValueToString(example)
+	}
+	return parameter.Name
+}
+
+// mediaTypeExample returns the example payload of the media type (its example, first named example or one from its schema).
+func (spec *OpenAPISpec) mediaTypeExample(media *OpenAPIMediaType) interface{} {
+	if media.Example != nil {
+		return media.Example
+	}
+	names := make([]string, 0, len(media.Examples))
+	for name := range media.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := media.Examples[name].Value; value != nil {
+			return value
+		}
+	}
+	return spec.Example(media.Schema)
+}
+
+var openAPIMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions}
+
+// RecordedRequests returns one request per operation (ordered by path and method) with example parameters and
+// payloads taken from the spec, the base URL is the first server URL (or http://localhost if missing or relative).
+func (spec *OpenAPISpec) RecordedRequests() []RecordedRequest {
+	baseURL := "http://localhost"
+	if len(spec.Servers) > 0 {
+		if u, err := url.Parse(spec.Servers[0].URL); err == nil && len(u.Host) > 0 {
+			baseURL = strings.TrimSuffix(spec.Servers[0].URL, "/")
+		} else if err == nil {
+			baseURL += strings.TrimSuffix(u.Path, "/")
+		}
+	}
+	templates := make([]string, 0, len(spec.Paths))
+	for template := range spec.Paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+	var recorded []RecordedRequest
+	for _, template := range templates {
+		item := spec.Paths[template]
+		operations := item.operations()
+		for _, method := range openAPIMethods {
+			operation := operations[method]
+			if operation == nil {
+				continue
+			}
+			recorded = append(recorded, spec.recordedRequest(baseURL, template, method, item, operation))
+		}
+	}
+	return recorded
+}
+
+func (spec *OpenAPISpec) recordedRequest(baseURL, template, method string, item *OpenAPIPathItem, operation *OpenAPIOperation) RecordedRequest {
+	rec := RecordedRequest{
+		Method:     method,
+		Header:     http.Header{},
+		StatusCode: expectedStatusCode(operation),
+		Step:       operation.OperationID,
+	}
+	if len(rec.Step) == 0 {
+		rec.Step = method + " " + template
+	}
+	path := template
+	query := url.Values{}
+	for _, parameter := range append(append([]*OpenAPIParameter(nil), item.Parameters...), operation.Parameters...) {
+		parameter = spec.resolveParameter(parameter)
+		switch parameter.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+parameter.Name+"}", url.PathEscape(spec.parameterExample(parameter)))
+		case "query":
+			if parameter.Required {
+				query.Set(parameter.Name, spec.parameterExample(parameter))
+			}
+		case "header":
+			if parameter.Required {
+				rec.Header.Set(parameter.Name, spec.parameterExample(parameter))
+			}
+		}
+	}
+	rec.URL = baseURL + path
+	if len(query) > 0 {
+		rec.URL += "?" + query.Encode()
+	}
+	if operation.RequestBody != nil {
+		body := spec.resolveRequestBody(operation.RequestBody)
+		contentTypes := make([]string, 0, len(body.Content))
+		for contentType := range body.Content {
+			contentTypes = append(contentTypes, contentType)
+		}
+		sort.Slice(contentTypes, func(i, j int) bool { // prefer JSON payloads
+			ji, jj := strings.Contains(contentTypes[i], "json"), strings.Contains(contentTypes[j], "json")
+			if ji != jj {
+				return ji
+			}
+			return contentTypes[i] < contentTypes[j]
+		})
+		if len(contentTypes) > 0 {
+			example := spec.mediaTypeExample(body.Content[contentTypes[0]])
+			rec.Header.Set("Content-Type", contentTypes[0])
+			if s, ok := example.(string); ok && !strings.Contains(contentTypes[0], "json") {
+				rec.Body = []byte(s)
+			} else if contentTypes[0] == "application/x-www-form-urlencoded" {
+				form := url.Values{}
+				if object, ok := example.(map[string]interface{}); ok {
+					for key, value := range object {
+						form.Set(key, jsonValueToString(value))
+					}
+				}
+				rec.Body = []byte(form.Encode())
+			} else {
+				rec.Body, _ = json.Marshal(example)
+			}
+		}
+	}
+	return rec
+}
+
+// expectedStatusCode returns the lowest documented success status code (defaults to 200).
+func expectedStatusCode(operation *OpenAPIOperation) int {
+	expected := 0
+	for code := range operation.Responses {
+		if statusCode, err := strconv.Atoi(code); err == nil && statusCode >= 200 && statusCode < 300 && (expected == 0 || statusCode < expected) {
+			expected = statusCode
+		}
+	}
+	if expected == 0 {
+		return http.StatusOK
+	}
+	return expected
+}
+
+// GenerateScenarioFromOpenAPIFile generates a skeleton scenario with one step per operation of the OpenAPI spec file
+// (see GenerateScenarioCode).
+func GenerateScenarioFromOpenAPIFile(filename string, options CodegenOptions) ([]byte, error) {
+	spec, err := LoadOpenAPISpec(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(options.Source) == 0 {
+		options.Source = "the OpenAPI spec " + filename
+	}
+	if len(options.FuncName) == 0 {
+		options.FuncName = "apiScenario"
+	}
+	options.IncludeStaticAssets = true // operations are never static assets, even when serving images
+	options.Hosts = nil
+	return GenerateScenarioCode(spec.RecordedRequests(), options)
+}
+
+// generateScenarioFromOpenAPIArgs runs the openapi subcommand.
+func generateScenarioFromOpenAPIArgs() {
+	if len(CommandlineArgs.SubcommandArgs) == 0 {
+		LogError("Missing OpenAPI spec file (use as argument at the end)")
+		os.Exit(ExitUsage)
+	}
+	options := CodegenOptions{
+		Package:  *CommandlineArgs.OpenAPI.Package,
+		FuncName: *CommandlineArgs.OpenAPI.Func,
+		Timeout:  *CommandlineArgs.OpenAPI.Timeout,
+	}
+	code, err := GenerateScenarioFromOpenAPIFile(CommandlineArgs.SubcommandArgs[0], options)
+	CheckErrAndLogFatal(err, "unable to generate scenario")
+	err = ioutil.WriteFile(*CommandlineArgs.OpenAPI.Output, code, 0644)
+	CheckErrAndLogFatal(err, "unable to write scenario")
+	LogSuccess("Scenario code written to:", *CommandlineArgs.OpenAPI.Output)
+}
@@ -0,0 +1,155 @@
+package goverrun
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testOpenAPISpec = `openapi: 3.0.3
+info:
+  title: Pet Store
+  version: 1.0.0
+servers:
+  - url: SERVER/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: query
+          required: true
+          schema: {type: integer, example: 10}
+      responses:
+        200:
+          description: pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Pet'}
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+            example: {id: 7, name: Rex}
+      responses:
+        201: {$ref: '#/components/responses/Pet'}
+        default: {description: error}
+  /pets/{petId}:
+    parameters:
+      - {$ref: '#/components/parameters/petId'}
+    get:
+      responses:
+        2XX: {$ref: '#/components/responses/Pet'}
+        404: {description: not found}
+components:
+  parameters:
+    petId: {name: petId, in: path, required: true, schema: {type: integer, example: 42}}
+  responses:
+    Pet:
+      description: pet
+      content:
+        application/json:
+          schema: {$ref: '#/components/schemas/Pet'}
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      additionalProperties: false
+      properties:
+        id: {type: integer, minimum: 1}
+        name: {type: string, minLength: 1, pattern: '^[A-Z]'}
+        tag: {type: string, nullable: true}
+        status: {type: string, enum: [available, sold]}
+        born: {type: string, format: date}
+`
+
+func TestGenerateScenarioFromOpenAPI(t *testing.T) {
+	spec, err := ParseOpenAPISpec([]byte(strings.Replace(testOpenAPISpec, "SERVER", "https://api.example.com", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := GenerateScenarioCode(spec.RecordedRequests(), CodegenOptions{FuncName: "apiScenario"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(code)
+	for _, expected := range []string{
+		`var baseURL = "https://api.example.com"`,
+		"user.Step(\"listPets\").\n\t\tRequest(http.MethodGet, baseURL+\"/v1/pets?limit=10\")",
+		"body = strings.NewReader(`{\"id\":7,\"name\":\"Rex\"}`)\n\tuser.Step(\"createPet\")",
+		`SetHeader("Content-Type", "application/json")`,
+		"AssertStatusCode(201)",
+		"user.Step(\"GET /pets/{petId}\").\n\t\tRequest(http.MethodGet, baseURL+\"/v1/pets/42\")",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected generated code to contain %q:\n%s", expected, src)
+		}
+	}
+	if _, err := ParseOpenAPISpec([]byte(`swagger: "2.0"`)); err == nil {
+		t.Error("expected unsupported version error")
+	}
+}
+
+func TestAssertMatchesOpenAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		switch r.URL.Path {
+		case "/v1/pets/1":
+			_, _ = w.Write([]byte(`{"id": 1, "name": "Rex", "tag": null, "born": "2020-02-29"}`))
+		case "/v1/pets/2":
+			_, _ = w.Write([]byte(`{"id": 2, "name": "Tom", "status": "lost"}`))
+		case "/v1/pets":
+			_, _ = w.Write([]byte(`[{"id": 1, "name": "Rex"}, {"id": 2.5, "name": "Tom", "owner": "Ann"}]`))
+		case "/v1/pets/3":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("Rex"))
+		case "/v1/pets/4":
+			w.WriteHeader(http.StatusTeapot)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	spec, err := ParseOpenAPISpec([]byte(strings.Replace(testOpenAPISpec, "SERVER", server.URL, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{}}
+
+	for path, expected := range map[string]string{
+		"/v1/pets/1":        "",
+		"/v1/pets/404":      "",
+		"/v1/pets/2":        `assertion of OpenAPI response of GET /pets/{petId} failed: body value is not in enum at JSON pointer "/status"`,
+		"/v1/pets?limit=10": `assertion of OpenAPI response of GET /pets failed: body value has type number but expected integer at JSON pointer "/1/id"`,
+		"/v1/pets/3":        `assertion of OpenAPI response of GET /pets/{petId} failed: content type "text/plain" not documented for status code 200`,
+		"/v1/pets/4":        "assertion of OpenAPI response of GET /pets/{petId} failed: status code 418 not documented",
+		"/v1/owners":        "assertion of OpenAPI response failed (no operation documented): GET /v1/owners",
+	} {
+		response := user.Step("openapi").Request(http.MethodGet, server.URL+path).SendWithTimeout(5 * time.Second).AssertMatchesOpenAPI(spec)
+		if response.Error != nil || response.AssertionFailed != expected {
+			t.Errorf("unexpected result of %s: %v %q", path, response.Error, response.AssertionFailed)
+		}
+	}
+
+	operation, _, _ := spec.FindOperation(http.MethodGet, "/v1/pets")
+	violations := spec.ValidateResponse(operation, 200, "application/json", []byte(`[{"id": 0, "owner~/x": 1}]`))
+	if len(violations) != 3 || violations[0].Pointer != "/0/name" || violations[1].Pointer != "/0/id" || violations[2].Pointer != "/0/owner~0~1x" {
+		t.Errorf("unexpected violations: %+v", violations)
+	}
+	violations = spec.ValidateResponse(operation, 200, "application/json", []byte(`[{"id": 1, "name": "rex"}]`))
+	if len(violations) != 1 || violations[0].String() != `body string does not match pattern ^[A-Z] at JSON pointer "/0/name"` {
+		t.Errorf("unexpected violations: %+v", violations)
+	}
+
+	_, err = ParseOpenAPISpec([]byte(strings.Replace(testOpenAPISpec, "pattern: '^[A-Z]'", "pattern: '^[A-Z'", 1)))
+	if err == nil || err.Error() != "invalid pattern \"^[A-Z\" of schema #/components/schemas/Pet/properties/name: error parsing regexp: missing closing ]: `[A-Z`" {
+		t.Errorf("unexpected error of invalid pattern: %v", err)
+	}
+}
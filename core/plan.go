@@ -0,0 +1,802 @@
+package goverrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plan is a declarative test plan (in YAML or JSON) describing scenarios without writing Go, see LoadPlan.
+// A minimal plan looks like:
+//
+//	variables:
+//	  baseURL: https://shop.example.com
+//	load:
+//	  users: 10
+//	  rampUp: 30s
+//	  plateau: 5m
+//	scenarios:
+//	  - title: browse
+//	    steps:
+//	      - name: open start page
+//	        thinkTime: {min: 1s, max: 3s}
+//	        request: {url: "${data.baseURL}/", timeout: 3s}
+//	        assert: {statusCode: 200, bodyContains: Welcome}
+//	        extract:
+//	          - {key: csrf, css: "input[name=csrf]", attr: value, required: true}
+//	        expect: {successPercentageAtLeast: 95}
+//
+// Requests are templated (see Request.Templated), so variables and extracted values are usable as ${data.<key>}.
+type Plan struct {
+	Variables map[string]string `yaml:"variables"` // seeded into User.Data at the start of every loop
+	Load      *PlanLoad         `yaml:"load"`      // defaults of all scenarios
+	Scenarios []*PlanScenario   `yaml:"scenarios"`
+}
+
+// PlanLoad configures the LoadConfig of scenarios, unset fields keep their defaults.
+type PlanLoad struct {
+	Users                     *int          `yaml:"users"`
+	RampUp                    *PlanDuration `yaml:"rampUp"`
+	Plateau                   *PlanDuration `yaml:"plateau"`
+	RampDown                  *PlanDuration `yaml:"rampDown"`
+	StartDelay                *PlanInterval `yaml:"startDelay"`
+	LoopDelay                 *PlanInterval `yaml:"loopDelay"`
+	ClearCookieJarOnEveryLoop *bool         `yaml:"clearCookieJarOnEveryLoop"`
+	HTTPCache                 *bool         `yaml:"httpCache"`
+	// internal
+	line int
+}
+
+type PlanScenario struct {
+	Title       string      `yaml:"title"`
+	Description string      `yaml:"description"`
+	Ignored     bool        `yaml:"ignored"`
+	Load        *PlanLoad   `yaml:"load"` // overrides the load of the plan
+	Steps       []*PlanStep `yaml:"steps"`
+	// internal
+	line int
+}
+
+type PlanStep struct {
+	Name      string            `yaml:"name"`
+	ThinkTime *PlanInterval     `yaml:"thinkTime"` // before the step
+	Request   *PlanRequest      `yaml:"request"`
+	Assert    *PlanAssertions   `yaml:"assert"`
+	Extract   []*PlanExtraction `yaml:"extract"`
+	Expect    *PlanExpectations `yaml:"expect"`
+	// internal
+	line int
+}
+
+type PlanRequest struct {
+	Method  string            `yaml:"method"` // defaults to GET
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Cookies map[string]string `yaml:"cookies"`
+	Form    map[string]string `yaml:"form"`
+	Body    string            `yaml:"body"`
+	JSON    interface{}       `yaml:"json"`    // sent as JSON body (with Content-Type application/json unless set)
+	Timeout *PlanDuration     `yaml:"timeout"` // defaults to no timeout
+	// internal
+	line int
+}
+
+type PlanAssertions struct {
+	StatusCode      int            `yaml:"statusCode"`
+	BodyContains    planStringList `yaml:"bodyContains"`
+	BodyMatches     planStringList `yaml:"bodyMatches"`
+	BodySizeAtLeast *int           `yaml:"bodySizeAtLeast"`
+	BodySizeAtMost  *int           `yaml:"bodySizeAtMost"`
+	// internal
+	line        int
+	bodyMatches []*regexp.Regexp
+}
+
+// PlanExtraction stores a value of the response into User.Data, exactly one of the kinds must be set (see Extractor).
+type PlanExtraction struct {
+	Key      string  `yaml:"key"`
+	Regex    string  `yaml:"regex"`
+	JSONPath string  `yaml:"jsonPath"`
+	Header   string  `yaml:"header"`
+	Cookie   string  `yaml:"cookie"`
+	CSS      string  `yaml:"css"`
+	XPath    string  `yaml:"xpath"`
+	Attr     string  `yaml:"attr"` // only for CSS
+	Default  *string `yaml:"default"`
+	Required bool    `yaml:"required"`
+	// internal
+	line      int
+	extractor *Extractor
+}
+
+type PlanExpectations struct {
+	SuccessPercentageAtLeast        *float64                    `yaml:"successPercentageAtLeast"`
+	SuccessCountAtLeast             *uint64                     `yaml:"successCountAtLeast"`
+	FailurePercentageAtMost         *float64                    `yaml:"failurePercentageAtMost"`
+	FailureCountAtMost              *uint64                     `yaml:"failureCountAtMost"`
+	ErrorPercentageAtMost           *float64                    `yaml:"errorPercentageAtMost"`
+	ErrorCountAtMost                *uint64                     `yaml:"errorCountAtMost"`
+	TimeoutPercentageAtMost         *float64                    `yaml:"timeoutPercentageAtMost"`
+	TimeoutCountAtMost              *uint64                     `yaml:"timeoutCountAtMost"`
+	TotalTimePercentiles            []*PlanPercentile           `yaml:"totalTimePercentiles"`
+	TimeToFirstBytePercentiles      []*PlanPercentile           `yaml:"timeToFirstBytePercentiles"`
+	TimeAfterRequestSentPercentiles []*PlanPercentile           `yaml:"timeAfterRequestSentPercentiles"`
+	RequestBytesWithin              *PlanRange                  `yaml:"requestBytesWithin"`
+	ResponseBytesWithin             *PlanRange                  `yaml:"responseBytesWithin"`
+	StatusCodePercentages           []*PlanStatusCodePercentage `yaml:"statusCodePercentages"`
+	// internal
+	line int
+}
+
+type PlanPercentile struct {
+	Percentile float64      `yaml:"percentile"`
+	Limit      PlanDuration `yaml:"limit"`
+	// internal
+	line int
+}
+
+type PlanRange struct {
+	Min uint64 `yaml:"min"`
+	Max uint64 `yaml:"max"`
+	// internal
+	line int
+}
+
+type PlanStatusCodePercentage struct {
+	StatusCode int      `yaml:"statusCode"`
+	AtLeast    *float64 `yaml:"atLeast"`
+	AtMost     *float64 `yaml:"atMost"`
+	// internal
+	line int
+}
+
+// PlanDuration is a non-negative duration written like 500ms, 2m30s or as number of seconds like 90.
+type PlanDuration struct {
+	time.Duration
+}
+
+// PlanInterval is a fixed duration (like 2s) or a random interval (like {min: 1s, max: 3s}).
+type PlanInterval struct {
+	Min PlanDuration `yaml:"min"`
+	Max PlanDuration `yaml:"max"`
+	// internal
+	line int
+}
+
+// planStringList is a single string or a list of strings.
+type planStringList []string
+
+// PlanProblem is a problem of an invalid plan located by its line in the plan file.
+type PlanProblem struct {
+	Line    int
+	Message string
+}
+
+// PlanError lists all problems of an invalid plan.
+type PlanError struct {
+	Filename string
+	Problems []PlanProblem
+}
+
+func (e *PlanError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, fmt.Sprintf("%s:%d: %s", e.Filename, problem.Line, problem.Message))
+	}
+	return "invalid plan:\n" + strings.Join(lines, "\n")
+}
+
+func (load *PlanLoad) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanLoad
+	load.line = node.Line
+	return node.Decode((*plain)(load))
+}
+
+func (scenario *PlanScenario) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanScenario
+	scenario.line = node.Line
+	return node.Decode((*plain)(scenario))
+}
+
+func (step *PlanStep) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanStep
+	step.line = node.Line
+	return node.Decode((*plain)(step))
+}
+
+func (request *PlanRequest) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanRequest
+	request.line = node.Line
+	return node.Decode((*plain)(request))
+}
+
+func (assertions *PlanAssertions) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanAssertions
+	assertions.line = node.Line
+	return node.Decode((*plain)(assertions))
+}
+
+func (extraction *PlanExtraction) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanExtraction
+	extraction.line = node.Line
+	return node.Decode((*plain)(extraction))
+}
+
+func (expectations *PlanExpectations) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanExpectations
+	expectations.line = node.Line
+	return node.Decode((*plain)(expectations))
+}
+
+func (percentile *PlanPercentile) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanPercentile
+	percentile.line = node.Line
+	return node.Decode((*plain)(percentile))
+}
+
+func (r *PlanRange) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanRange
+	r.line = node.Line
+	return node.Decode((*plain)(r))
+}
+
+func (percentage *PlanStatusCodePercentage) UnmarshalYAML(node *yaml.Node) error {
+	type plain PlanStatusCodePercentage
+	percentage.line = node.Line
+	return node.Decode((*plain)(percentage))
+}
+
+func (d *PlanDuration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: invalid duration (expected like 500ms, 2m30s or seconds like 90)", node.Line)}}
+	}
+	duration, err := parseSeconds(node.Value) // like the duration flags of the run subcommand
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %s", node.Line, err)}}
+	}
+	d.Duration = duration
+	return nil
+}
+
+func (interval *PlanInterval) UnmarshalYAML(node *yaml.Node) error {
+	interval.line = node.Line
+	if node.Kind == yaml.ScalarNode {
+		if err := interval.Min.UnmarshalYAML(node); err != nil {
+			return err
+		}
+		interval.Max = interval.Min
+		return nil
+	}
+	type plain PlanInterval
+	return node.Decode((*plain)(interval))
+}
+
+func (list *planStringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*list = planStringList{node.Value}
+		return nil
+	}
+	return node.Decode((*[]string)(list))
+}
+
+func (interval PlanInterval) randomInterval() RandomInterval {
+	return RandomInterval{Min: interval.Min.Duration, Max: interval.Max.Duration}
+}
+
+// LoadPlan reads and validates the plan file (YAML or JSON). Problems are reported as PlanError with their lines.
+func LoadPlan(filename string) (*Plan, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePlan(data, filename)
+}
+
+var yamlErrorLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// ParsePlan parses and validates the plan (YAML or JSON), the filename is used in the problem locations only.
+func ParsePlan(data []byte, filename string) (*Plan, error) {
+	planErr := &PlanError{Filename: filename}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		planErr.addYAMLError(err)
+		return nil, planErr
+	}
+	if len(root.Content) == 0 {
+		planErr.add(1, "empty plan")
+		return nil, planErr
+	}
+	checkPlanFields(root.Content[0], reflect.TypeOf(Plan{}), planErr)
+	plan := &Plan{}
+	if err := root.Content[0].Decode(plan); err != nil {
+		planErr.addYAMLError(err)
+	}
+	if len(planErr.Problems) == 0 {
+		plan.validate(planErr)
+	}
+	if len(planErr.Problems) > 0 {
+		sort.SliceStable(planErr.Problems, func(i, j int) bool { return planErr.Problems[i].Line < planErr.Problems[j].Line })
+		return nil, planErr
+	}
+	return plan, nil
+}
+
+func (e *PlanError) add(line int, format string, args ...interface{}) {
+	e.Problems = append(e.Problems, PlanProblem{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// addYAMLError adds the problems of a syntax or type error (formatted like "line 3: ..." by the YAML decoder).
+func (e *PlanError) addYAMLError(err error) {
+	messages := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	}
+	for _, message := range messages {
+		if match := yamlErrorLinePattern.FindStringSubmatch(message); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			e.add(line, "%s", match[2])
+		} else {
+			e.add(1, "%s", strings.TrimPrefix(message, "yaml: "))
+		}
+	}
+}
+
+// checkPlanFields reports unknown fields (like typos) of mappings decoded into structs.
+func checkPlanFields(node *yaml.Node, t reflect.Type, planErr *PlanError) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			if name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]; len(name) > 0 && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				planErr.add(key.Line, "unknown field %q (expected one of %s)", key.Value, strings.Join(sortedFieldNames(fields), ", "))
+				continue
+			}
+			checkPlanFields(node.Content[i+1], fieldType, planErr)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			checkPlanFields(item, t.Elem(), planErr)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			checkPlanFields(node.Content[i], t.Elem(), planErr)
+		}
+	}
+}
+
+func sortedFieldNames(fields map[string]reflect.Type) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var planMethodPattern = regexp.MustCompile(`^[A-Z]+$`)
+
+func (plan *Plan) validate(planErr *PlanError) {
+	if len(plan.Scenarios) == 0 {
+		planErr.add(1, "no scenarios defined")
+	}
+	plan.Load.validate(planErr)
+	titles := make(map[string]bool)
+	for _, scenario := range plan.Scenarios {
+		switch {
+		case len(scenario.Title) == 0:
+			planErr.add(scenario.line, "scenario without title")
+		case titles[scenario.Title]:
+			planErr.add(scenario.line, "duplicate scenario title %q", scenario.Title)
+		}
+		titles[scenario.Title] = true
+		scenario.Load.validate(planErr)
+		if len(scenario.Steps) == 0 {
+			planErr.add(scenario.line, "scenario %q without steps", scenario.Title)
+		}
+		for _, step := range scenario.Steps {
+			step.validate(planErr)
+		}
+	}
+}
+
+func (load *PlanLoad) validate(planErr *PlanError) {
+	if load == nil {
+		return
+	}
+	if load.Users != nil && *load.Users <= 0 {
+		planErr.add(load.line, "users must be positive: %d", *load.Users)
+	}
+	for name, d := range map[string]*PlanDuration{"rampUp": load.RampUp, "plateau": load.Plateau, "rampDown": load.RampDown} {
+		if d != nil && d.Duration < 0 {
+			planErr.add(load.line, "%s must not be negative: %s", name, d.Duration)
+		}
+	}
+	load.StartDelay.validate("startDelay", planErr)
+	load.LoopDelay.validate("loopDelay", planErr)
+}
+
+func (interval *PlanInterval) validate(name string, planErr *PlanError) {
+	if interval == nil {
+		return
+	}
+	if interval.Min.Duration < 0 || interval.Max.Duration < interval.Min.Duration {
+		planErr.add(interval.line, "%s must have 0 <= min <= max: %s and %s", name, interval.Min.Duration, interval.Max.Duration)
+	}
+}
+
+func (step *PlanStep) validate(planErr *PlanError) {
+	if len(step.Name) == 0 {
+		planErr.add(step.line, "step without name")
+	}
+	step.ThinkTime.validate("thinkTime", planErr)
+	if step.Request == nil {
+		planErr.add(step.line, "step %q without request", step.Name)
+	} else {
+		step.Request.validate(planErr)
+	}
+	if assertions := step.Assert; assertions != nil {
+		if assertions.StatusCode != 0 && (assertions.StatusCode < 100 || assertions.StatusCode > 599) {
+			planErr.add(assertions.line, "invalid statusCode: %d", assertions.StatusCode)
+		}
+		for _, expression := range assertions.BodyMatches {
+			re, err := regexp.Compile(expression)
+			if err != nil {
+				planErr.add(assertions.line, "invalid bodyMatches regular expression: %s", err)
+				continue
+			}
+			assertions.bodyMatches = append(assertions.bodyMatches, re)
+		}
+	}
+	keys := make(map[string]bool)
+	for _, extraction := range step.Extract {
+		extraction.validate(planErr)
+		if keys[extraction.Key] {
+			planErr.add(extraction.line, "duplicate extraction key %q", extraction.Key)
+		}
+		keys[extraction.Key] = true
+	}
+	step.Expect.validate(planErr)
+}
+
+func (request *PlanRequest) validate(planErr *PlanError) {
+	if len(request.Method) == 0 {
+		request.Method = http.MethodGet
+	}
+	if !planMethodPattern.MatchString(request.Method) {
+		planErr.add(request.line, "invalid method %q (expected uppercase like GET or POST)", request.Method)
+	}
+	if len(request.URL) == 0 {
+		planErr.add(request.line, "request without url")
+	}
+	bodies := 0
+	for _, set := range []bool{len(request.Body) > 0, request.JSON != nil, len(request.Form) > 0} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		planErr.add(request.line, "only one of body, json and form may be set")
+	}
+	if request.Timeout != nil && request.Timeout.Duration <= 0 {
+		planErr.add(request.line, "timeout must be positive: %s", request.Timeout.Duration)
+	}
+}
+
+func (extraction *PlanExtraction) validate(planErr *PlanError) {
+	if len(extraction.Key) == 0 {
+		planErr.add(extraction.line, "extraction without key")
+	}
+	var extractors []*Extractor
+	if len(extraction.Regex) > 0 {
+		re, err := regexp.Compile(extraction.Regex)
+		if err != nil {
+			planErr.add(extraction.line, "invalid regex: %s", err)
+			return
+		}
+		extractors = append(extractors, ExtractRegex(extraction.Key, re))
+	}
+	if len(extraction.JSONPath) > 0 {
+		extractors = append(extractors, ExtractJSONPath(extraction.Key, extraction.JSONPath))
+	}
+	if len(extraction.Header) > 0 {
+		extractors = append(extractors, ExtractHeader(extraction.Key, extraction.Header))
+	}
+	if len(extraction.Cookie) > 0 {
+		extractors = append(extractors, ExtractCookie(extraction.Key, extraction.Cookie))
+	}
+	if len(extraction.CSS) > 0 {
+		extractor, err := ExtractCSS(extraction.Key, extraction.CSS)
+		if err != nil {
+			planErr.add(extraction.line, "%s", err)
+			return
+		}
+		extractors = append(extractors, extractor)
+	}
+	if len(extraction.XPath) > 0 {
+		extractor, err := ExtractXPath(extraction.Key, extraction.XPath)
+		if err != nil {
+			planErr.add(extraction.line, "%s", err)
+			return
+		}
+		extractors = append(extractors, extractor)
+	}
+	if len(extractors) != 1 {
+		planErr.add(extraction.line, "extraction %q must have exactly one of regex, jsonPath, header, cookie, css and xpath", extraction.Key)
+		return
+	}
+	extractor := extractors[0]
+	if len(extraction.Attr) > 0 {
+		if extractor.Kind != "css" {
+			planErr.add(extraction.line, "attr is only supported for css extractions")
+		}
+		extractor.Attr(extraction.Attr)
+	}
+	if extraction.Default != nil {
+		extractor.Default(*extraction.Default)
+	}
+	if extraction.Required {
+		extractor.Required()
+	}
+	extraction.extractor = extractor
+}
+
+func (expectations *PlanExpectations) validate(planErr *PlanError) {
+	if expectations == nil {
+		return
+	}
+	for name, percentage := range map[string]*float64{
+		"successPercentageAtLeast": expectations.SuccessPercentageAtLeast, "failurePercentageAtMost": expectations.FailurePercentageAtMost,
+		"errorPercentageAtMost": expectations.ErrorPercentageAtMost, "timeoutPercentageAtMost": expectations.TimeoutPercentageAtMost,
+	} {
+		if percentage != nil && (*percentage < 0 || *percentage > 100) {
+			planErr.add(expectations.line, "%s must be between 0 and 100: %v", name, *percentage)
+		}
+	}
+	for _, percentiles := range [][]*PlanPercentile{expectations.TotalTimePercentiles, expectations.TimeToFirstBytePercentiles, expectations.TimeAfterRequestSentPercentiles} {
+		for _, percentile := range percentiles {
+			if percentile.Percentile <= 0 || percentile.Percentile > 100 {
+				planErr.add(percentile.line, "percentile must be above 0 and at most 100: %v", percentile.Percentile)
+			}
+			if percentile.Limit.Duration <= 0 {
+				planErr.add(percentile.line, "percentile limit must be positive")
+			}
+		}
+	}
+	for _, r := range []*PlanRange{expectations.RequestBytesWithin, expectations.ResponseBytesWithin} {
+		if r != nil && r.Max < r.Min {
+			planErr.add(r.line, "range must have min <= max: %d and %d", r.Min, r.Max)
+		}
+	}
+	for _, threshold := range expectations.StatusCodePercentages {
+		if (threshold.AtLeast == nil) == (threshold.AtMost == nil) {
+			planErr.add(threshold.line, "status code percentage must have exactly one of atLeast and atMost")
+		}
+		for _, percentage := range []*float64{threshold.AtLeast, threshold.AtMost} {
+			if percentage != nil && (*percentage < 0 || *percentage > 100) {
+				planErr.add(threshold.line, "status code percentage must be between 0 and 100: %v", *percentage)
+			}
+		}
+	}
+}
+
+// apply overrides the fields of the load config which are set.
+func (load *PlanLoad) apply(config *LoadConfig) {
+	if load == nil {
+		return
+	}
+	if load.Users != nil {
+		config.LoopingUsers = *load.Users
+	}
+	if load.RampUp != nil {
+		config.RampUp = load.RampUp.Duration
+	}
+	if load.Plateau != nil {
+		config.Plateau = load.Plateau.Duration
+	}
+	if load.RampDown != nil {
+		config.RampDown = load.RampDown.Duration
+	}
+	if load.StartDelay != nil {
+		config.StartDelay = load.StartDelay.randomInterval()
+	}
+	if load.LoopDelay != nil {
+		config.LoopDelay = load.LoopDelay.randomInterval()
+	}
+	if load.ClearCookieJarOnEveryLoop != nil {
+		config.ClearCookieJarOnEveryLoop = *load.ClearCookieJarOnEveryLoop
+	}
+	if load.HTTPCache != nil {
+		config.HTTPCache = *load.HTTPCache
+	}
+}
+
+// AddScenarios adds the scenarios of the plan (see AddScenario). Their load config is based on the given defaults
+// (like DefaultLoadConfigFromArgs), overridden by the load of the plan and then by the load of the scenario.
+func (plan *Plan) AddScenarios(defaults LoadConfig) error {
+	for _, scenario := range plan.Scenarios {
+		config := defaults
+		plan.Load.apply(&config)
+		scenario.Load.apply(&config)
+		err := AddScenario(&Scenario{
+			Title:       scenario.Title,
+			Description: scenario.Description,
+			Runner:      plan.runner(scenario),
+			LoadConfig:  config,
+			Ignored:     scenario.Ignored,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePlansFromArgs runs the validate subcommand, reporting the problems of every given plan file.
+func validatePlansFromArgs() {
+	if len(CommandlineArgs.SubcommandArgs) == 0 {
+		LogError("Missing plan file (use as argument at the end)")
+		os.Exit(ExitUsage)
+	}
+	valid := true
+	for _, filename := range CommandlineArgs.SubcommandArgs {
+		plan, err := LoadPlan(filename)
+		if err != nil {
+			LogError(err)
+			valid = false
+			continue
+		}
+		LogSuccessf("Plan is valid: %s (%d scenarios)", filename, len(plan.Scenarios))
+	}
+	if !valid {
+		os.Exit(ExitError)
+	}
+}
+
+func (plan *Plan) runner(scenario *PlanScenario) func(user *User) {
+	return func(user *User) {
+		if user.Data == nil {
+			user.Data = make(map[string]interface{})
+		}
+		for key, value := range plan.Variables {
+			user.Data[key] = value
+		}
+		for _, step := range scenario.Steps {
+			step.run(user)
+		}
+	}
+}
+
+func (step *PlanStep) run(user *User) {
+	if step.ThinkTime != nil {
+		user.ThinkTimeRandom(step.ThinkTime.Min.Duration, step.ThinkTime.Max.Duration)
+	}
+	s := user.Step(step.Name)
+	step.Expect.apply(s)
+	spec := step.Request
+	request := s.Request(spec.Method, spec.URL).Templated()
+	for key, value := range spec.Headers {
+		request.SetHeader(key, value)
+	}
+	for key, value := range spec.Cookies {
+		request.SetCookie(key, value)
+	}
+	for key, value := range spec.Form {
+		request.SetFormParam(key, value)
+	}
+	if len(spec.Body) > 0 {
+		var body io.Reader = strings.NewReader(spec.Body)
+		request.SetBody(&body)
+	}
+	if spec.JSON != nil {
+		data, err := json.Marshal(jsonCompatible(spec.JSON))
+		CheckErrAndLogError(err, "unable to encode JSON body")
+		var body io.Reader = bytes.NewReader(data)
+		request.SetBody(&body)
+		if _, ok := spec.Headers["Content-Type"]; !ok {
+			request.SetHeader("Content-Type", "application/json")
+		}
+	}
+	var response *Response
+	if spec.Timeout != nil {
+		response = request.SendWithTimeout(spec.Timeout.Duration)
+	} else {
+		response = request.SendWithoutTimeout()
+	}
+	if assertions := step.Assert; assertions != nil {
+		if assertions.StatusCode != 0 {
+			response.AssertStatusCode(assertions.StatusCode)
+		}
+		for _, s := range assertions.BodyContains {
+			response.AssertBodyContains(s)
+		}
+		for _, re := range assertions.bodyMatches {
+			response.AssertBodyMatches(re)
+		}
+		if assertions.BodySizeAtLeast != nil {
+			response.AssertBodySizeAtLeast(*assertions.BodySizeAtLeast)
+		}
+		if assertions.BodySizeAtMost != nil {
+			response.AssertBodySizeAtMost(*assertions.BodySizeAtMost)
+		}
+	}
+	for _, extraction := range step.Extract {
+		response.Extract(extraction.extractor)
+	}
+	response.ArchiveStats()
+}
+
+func (expectations *PlanExpectations) apply(step *Step) {
+	if expectations == nil {
+		return
+	}
+	if expectations.SuccessPercentageAtLeast != nil {
+		step.ExpectSuccessPercentageAtLeast(*expectations.SuccessPercentageAtLeast)
+	}
+	if expectations.SuccessCountAtLeast != nil {
+		step.ExpectSuccessCountAtLeast(*expectations.SuccessCountAtLeast)
+	}
+	if expectations.FailurePercentageAtMost != nil {
+		step.ExpectFailurePercentageAtMost(*expectations.FailurePercentageAtMost)
+	}
+	if expectations.FailureCountAtMost != nil {
+		step.ExpectFailureCountAtMost(*expectations.FailureCountAtMost)
+	}
+	if expectations.ErrorPercentageAtMost != nil {
+		step.ExpectErrorPercentageAtMost(*expectations.ErrorPercentageAtMost)
+	}
+	if expectations.ErrorCountAtMost != nil {
+		step.ExpectErrorCountAtMost(*expectations.ErrorCountAtMost)
+	}
+	if expectations.TimeoutPercentageAtMost != nil {
+		step.ExpectTimeoutPercentageAtMost(*expectations.TimeoutPercentageAtMost)
+	}
+	if expectations.TimeoutCountAtMost != nil {
+		step.ExpectTimeoutCountAtMost(*expectations.TimeoutCountAtMost)
+	}
+	for _, percentile := range expectations.TotalTimePercentiles {
+		step.ExpectTotalRequestResponseTimePercentileLimit(percentile.Percentile, percentile.Limit.Duration)
+	}
+	for _, percentile := range expectations.TimeToFirstBytePercentiles {
+		step.ExpectTimeToFirstBytePercentileLimit(percentile.Percentile, percentile.Limit.Duration)
+	}
+	for _, percentile := range expectations.TimeAfterRequestSentPercentiles {
+		step.ExpectTimeAfterRequestSentPercentileLimit(percentile.Percentile, percentile.Limit.Duration)
+	}
+	if r := expectations.RequestBytesWithin; r != nil {
+		step.ExpectTotalRequestBytesWithin(r.Min, r.Max)
+	}
+	if r := expectations.ResponseBytesWithin; r != nil {
+		step.ExpectTotalResponseBytesWithin(r.Min, r.Max)
+	}
+	for _, threshold := range expectations.StatusCodePercentages {
+		if threshold.AtLeast != nil {
+			step.ExpectStatusCodePercentageAtLeast(threshold.StatusCode, *threshold.AtLeast)
+		} else {
+			step.ExpectStatusCodePercentageAtMost(threshold.StatusCode, *threshold.AtMost)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package goverrun
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPlan = `variables:
+  greeting: hello
+load:
+  users: 3
+  plateau: 60
+scenarios:
+  - title: shop
+    load: {users: 2, loopDelay: {min: 1s, max: 2s}}
+    steps:
+      - name: open login page
+        request: {url: "${data.baseURL}/login", timeout: 2s}
+        assert: {statusCode: 200, bodyContains: [Login, csrf], bodyMatches: "value=\"\\w+\""}
+        extract:
+          - {key: csrf, css: "input[name=csrf]", attr: value, required: true}
+          - {key: missing, header: X-Missing, default: none}
+        expect:
+          successPercentageAtLeast: 95
+          totalTimePercentiles: [{percentile: 95, limit: 500ms}]
+          statusCodePercentages: [{statusCode: 200, atLeast: 90}]
+      - name: submit login
+        thinkTime: 1ms
+        request:
+          method: POST
+          url: "${data.baseURL}/login"
+          json: {user: alice, csrf: "${data.csrf}", greeting: "${data.greeting}", remember: true}
+        assert: {statusCode: 200}
+        extract:
+          - {key: token, jsonPath: $.token}
+`
+
+func TestPlan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, `<h1>Login</h1><input name="csrf" value="abc123">`)
+			return
+		}
+		var payload map[string]interface{}
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&payload) != nil ||
+			payload["csrf"] != "abc123" || payload["greeting"] != "hello" || payload["remember"] != true {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, `{"token": "t-1"}`)
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := os.WriteFile(filename, []byte(testPlan), 0644); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := LoadPlan(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Reset()
+	if err := plan.AddScenarios(LoadConfig{LoopingUsers: 1, RampUp: time.Second, ClearCookieJarOnEveryLoop: true}); err != nil {
+		t.Fatal(err)
+	}
+	scenario := scenarios["shop"]
+	if config := scenario.LoadConfig; config.LoopingUsers != 2 || config.Plateau != time.Minute || config.RampUp != time.Second ||
+		config.LoopDelay.Max != 2*time.Second || !config.ClearCookieJarOnEveryLoop {
+		t.Errorf("unexpected load config: %+v", config)
+	}
+
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{}, Data: map[string]interface{}{"baseURL": server.URL}}
+	scenario.Runner(user)
+	if user.Data["csrf"] != "abc123" || user.Data["missing"] != "none" || user.Data["token"] != "t-1" {
+		t.Errorf("unexpected extracted data: %v", user.Data)
+	}
+	step := user.Step("expect")
+	plan.Scenarios[0].Steps[0].Expect.apply(step)
+	if step.Expectation.SuccessPercentageAtLeast.Percentage != 95 || step.Expectation.TotalRequestResponseTimePercentileLimits[0].Duration != 500*time.Millisecond ||
+		step.Expectation.StatusCodeThresholds[0].StatusCode != 200 {
+		t.Errorf("unexpected expectation: %+v", step.Expectation)
+	}
+}
+
+func TestParsePlanProblems(t *testing.T) {
+	for _, test := range []struct {
+		plan     string
+		expected string
+	}{
+		{"scenarios:\n  - title: a\n    step: []\n",
+			"invalid plan:\nplan.yaml:3: unknown field \"step\" (expected one of description, ignored, load, steps, title)"},
+		{"load:\n  users: many\n  rampUp: 10 minutes\n  plateau: -1s\nscenarios: []\n",
+			"invalid plan:\nplan.yaml:2: cannot unmarshal !!str `many` into int\nplan.yaml:3: invalid duration \"10 minutes\" (expected like 500ms, 2m30s or seconds like 90)\nplan.yaml:4: negative duration -1s"},
+		{"scenarios:\n  - title: a\n    steps:\n      - name: s\n        request: {method: get}\n        extract:\n          - {key: k, regex: x, css: y}\n        expect: {errorPercentageAtMost: 101}\n",
+			"invalid plan:\nplan.yaml:5: invalid method \"get\" (expected uppercase like GET or POST)\nplan.yaml:5: request without url\nplan.yaml:7: extraction \"k\" must have exactly one of regex, jsonPath, header, cookie, css and xpath\nplan.yaml:8: errorPercentageAtMost must be between 0 and 100: 101"},
+		{"scenarios:\n  - title: a\n    steps:\n      - name: s\n        request: {url: \"http://x\"}\n        extract:\n          - {key: k, xpath: \"//input[@name='csrf'\"}\n          - {key: l, css: \"input[name=\"}\n",
+			"invalid plan:\nplan.yaml:7: invalid XPath expression '//input[@name='csrf'': //input[@name='csrf' has an invalid token\nplan.yaml:8: invalid CSS selector 'input[name=': unexpected EOF in attribute selector"},
+		{`{"scenarios": [{"title": "a", "steps": [{"request": {"url": "http://x", "body": "b", "form": {"a": "b"}}}]}]}`,
+			"invalid plan:\nplan.yaml:1: step without name\nplan.yaml:1: only one of body, json and form may be set"},
+		{"scenarios: [\n", "invalid plan:\nplan.yaml:1: did not find expected node content"},
+	} {
+		_, err := ParsePlan([]byte(test.plan), "plan.yaml")
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("unexpected problems of plan:\n%s\ngot:\n%v\nwant:\n%s", test.plan, err, test.expected)
+		}
+	}
+}
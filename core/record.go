@@ -0,0 +1,476 @@
+package goverrun
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecorderConfig configures the recording proxy.
+type RecorderConfig struct {
+	Address   string         // listen address of the proxy (like 127.0.0.1:8888)
+	CADir     string         // directory of the CA certificate and key for HTTPS interception (generated on first use)
+	StepNames []StepNameRule // naming scheme of the steps (requests matching no rule are grouped into steps by idle gaps)
+	IdleGap   time.Duration  // a new step starts after this gap without requests (defaults to 2s)
+}
+
+// StepNameRule names the recorded requests whose method and URL (like "GET https://example.com/login") match the pattern.
+type StepNameRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// ParseStepNameRules parses comma-separated name=pattern rules (like "login=/login,cart=/api/cart").
+func ParseStepNameRules(rules string) ([]StepNameRule, error) {
+	var parsed []StepNameRule
+	for _, rule := range strings.Split(rules, ",") {
+		if len(strings.TrimSpace(rule)) == 0 {
+			continue
+		}
+		i := strings.Index(rule, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid step name rule '%s' (expected name=pattern)", rule)
+		}
+		pattern, err := regexp.Compile(rule[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of step name rule '%s': %w", rule, err)
+		}
+		parsed = append(parsed, StepNameRule{Name: strings.TrimSpace(rule[:i]), Pattern: pattern})
+	}
+	return parsed, nil
+}
+
+const (
+	caCertFilename = "goverrun-ca.pem"
+	caKeyFilename  = "goverrun-ca-key.pem"
+)
+
+// Recorder is a local HTTP(S) proxy capturing the traffic (like of a browser) into raw request files or scenario code.
+// HTTPS is intercepted with certificates signed by the recorder's CA, which has to be trusted by the browser (its
+// certificate is served at http://<proxy address>/ when requested directly).
+type Recorder struct {
+	config    RecorderConfig
+	ca        *x509.Certificate
+	caKey     *ecdsa.PrivateKey
+	caPEM     []byte
+	transport *http.Transport
+	listener  net.Listener
+	server    *http.Server
+	lock      sync.Mutex
+	recorded  []RecordedRequest
+	certs     map[string]*tls.Certificate // leaf certificates by host
+}
+
+// NewRecorder creates the recorder loading (or generating) the CA.
+func NewRecorder(config RecorderConfig) (*Recorder, error) {
+	if config.IdleGap == 0 {
+		config.IdleGap = 2 * time.Second
+	}
+	recorder := &Recorder{
+		config: config,
+		transport: &http.Transport{
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: SkipCertificateValidation},
+			MaxIdleConnsPerHost: 10,
+		},
+		certs: make(map[string]*tls.Certificate),
+	}
+	if err := recorder.loadCA(); err != nil {
+		return nil, err
+	}
+	return recorder, nil
+}
+
+// loadCA loads the CA from the CA directory or generates (and stores) a new one.
+func (recorder *Recorder) loadCA() error {
+	certFile, keyFile := filepath.Join(recorder.config.CADir, caCertFilename), filepath.Join(recorder.config.CADir, caKeyFilename)
+	certPEM, certErr := ioutil.ReadFile(certFile)
+	keyPEM, keyErr := ioutil.ReadFile(keyFile)
+	if certErr != nil || keyErr != nil {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: "goverrun recording CA", Organization: []string{"goverrun"}},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().AddDate(10, 0, 0),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			return err
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+		if len(recorder.config.CADir) > 0 {
+			if err := os.MkdirAll(recorder.config.CADir, 0700); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+				return err
+			}
+		}
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("unable to load recording CA: %w", err)
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("unsupported key type of recording CA")
+	}
+	if recorder.ca, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+		return err
+	}
+	recorder.caKey, recorder.caPEM = key, certPEM
+	return nil
+}
+
+// CACertificatePEM returns the CA certificate to be trusted by the recorded client.
+func (recorder *Recorder) CACertificatePEM() []byte {
+	return recorder.caPEM
+}
+
+// certificate returns the (cached) leaf certificate for the host signed by the CA.
+func (recorder *Recorder) certificate(host string) (*tls.Certificate, error) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	if cert, exists := recorder.certs[host]; exists {
+		return cert, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, recorder.ca, &key.PublicKey, recorder.caKey)
+	if err != nil {
+		return nil, err
+	}
+	cert := &tls.Certificate{Certificate: [][]byte{der, recorder.ca.Raw}, PrivateKey: key}
+	recorder.certs[host] = cert
+	return cert, nil
+}
+
+// Start starts listening (in the background).
+func (recorder *Recorder) Start() error {
+	listener, err := net.Listen("tcp", recorder.config.Address)
+	if err != nil {
+		return err
+	}
+	recorder.listener = listener
+	recorder.server = &http.Server{Handler: recorder}
+	go func() { _ = recorder.server.Serve(listener) }()
+	return nil
+}
+
+// Addr returns the address the proxy is listening on.
+func (recorder *Recorder) Addr() string {
+	return recorder.listener.Addr().String()
+}
+
+// Close stops the proxy.
+func (recorder *Recorder) Close() error {
+	recorder.transport.CloseIdleConnections()
+	return recorder.server.Close()
+}
+
+func (recorder *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodConnect:
+		recorder.intercept(w, r)
+	case !r.URL.IsAbs(): // not a proxy request: serve the CA certificate for convenient installation
+		w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+		w.Header().Set("Content-Disposition", "attachment; filename="+caCertFilename)
+		_, _ = w.Write(recorder.caPEM)
+	default:
+		rsp := recorder.forward(r)
+		for key, values := range rsp.Header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(rsp.StatusCode)
+		_, _ = io.Copy(w, rsp.Body)
+		_ = rsp.Body.Close()
+	}
+}
+
+// intercept terminates the TLS connection tunneled via CONNECT with a certificate for the host and forwards its requests.
+func (recorder *Recorder) intercept(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	tlsConn := tls.Server(conn, &tls.Config{
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if len(hello.ServerName) > 0 {
+				return recorder.certificate(hello.ServerName)
+			}
+			return recorder.certificate(host)
+		},
+	})
+	defer tlsConn.Close()
+	reader := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		req.URL.Scheme, req.URL.Host = "https", r.Host
+		rsp := recorder.forward(req)
+		err = rsp.Write(tlsConn)
+		_ = rsp.Body.Close()
+		if err != nil || rsp.Close || req.Close {
+			return
+		}
+	}
+}
+
+// hopByHopHeaders are not forwarded by proxies.
+var hopByHopHeaders = []string{"Connection", "Proxy-Connection", "Proxy-Authorization", "Keep-Alive", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// forward sends the request upstream and records it. Responses are decompressed to find dynamic values within them.
+func (recorder *Recorder) forward(r *http.Request) *http.Response {
+	started := time.Now()
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		_ = r.Body.Close()
+	}
+	out, err := http.NewRequest(r.Method, r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return errorResponse(r, err)
+	}
+	out.Header = r.Header.Clone()
+	for _, header := range hopByHopHeaders {
+		out.Header.Del(header)
+	}
+	out.Header.Del("Accept-Encoding") // let the transport decompress the responses
+	if len(body) == 0 {
+		out.Body, out.ContentLength = nil, 0
+	}
+	rsp, err := recorder.transport.RoundTrip(out)
+	if err != nil {
+		return errorResponse(r, err)
+	}
+	responseBody, err := io.ReadAll(rsp.Body)
+	_ = rsp.Body.Close()
+	if err != nil {
+		return errorResponse(r, err)
+	}
+	rec := RecordedRequest{
+		Started:      started,
+		Duration:     time.Since(started),
+		Method:       r.Method,
+		URL:          r.URL.String(),
+		Header:       out.Header,
+		Body:         body,
+		StatusCode:   rsp.StatusCode,
+		ContentType:  rsp.Header.Get("Content-Type"),
+		ResponseBody: responseBody,
+	}
+	if rsp.StatusCode >= 300 && rsp.StatusCode < 400 {
+		rec.RedirectURL = rsp.Header.Get("Location")
+	}
+	recorder.lock.Lock()
+	recorder.recorded = append(recorder.recorded, rec)
+	recorder.lock.Unlock()
+	for _, header := range hopByHopHeaders {
+		rsp.Header.Del(header)
+	}
+	rsp.Header.Del("Content-Length")
+	rsp.Body, rsp.ContentLength = ioutil.NopCloser(bytes.NewReader(responseBody)), int64(len(responseBody))
+	rsp.TransferEncoding = nil
+	return rsp
+}
+
+func errorResponse(r *http.Request, err error) *http.Response {
+	LogWarning("unable to forward recorded request", r.URL, err)
+	return &http.Response{
+		StatusCode:    http.StatusBadGateway,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          ioutil.NopCloser(strings.NewReader(err.Error())),
+		ContentLength: int64(len(err.Error())),
+		Request:       r,
+	}
+}
+
+// Recorded returns the requests recorded so far in chronological order with their step names assigned:
+// requests matching a step name rule are named by it (and start a group continued by the following requests),
+// all others are grouped into steps by idle gaps (named by the method and path of the first request of the group).
+func (recorder *Recorder) Recorded() []RecordedRequest {
+	recorder.lock.Lock()
+	recorded := append([]RecordedRequest(nil), recorder.recorded...)
+	recorder.lock.Unlock()
+	sort.SliceStable(recorded, func(i, j int) bool { return recorded[i].Started.Before(recorded[j].Started) })
+	var group string
+	var lastEnd time.Time
+	for i := range recorded {
+		rec := &recorded[i]
+		for _, rule := range recorder.config.StepNames {
+			if rule.Pattern.MatchString(rec.Method + " " + rec.URL) {
+				rec.Step, group = rule.Name, rule.Name
+				break
+			}
+		}
+		if len(rec.Step) == 0 {
+			if len(group) == 0 || rec.Started.Sub(lastEnd) >= recorder.config.IdleGap {
+				group = rec.Method + " " + rec.URL
+				if u, err := url.Parse(rec.URL); err == nil {
+					group = rec.Method + " " + u.Path
+				}
+			}
+			rec.Step = group
+		}
+		if end := rec.Started.Add(rec.Duration); end.After(lastEnd) {
+			lastEnd = end
+		}
+	}
+	return recorded
+}
+
+// WriteRawFiles writes every recorded request into a file of the directory in the raw format of Step.RequestRawFromFile.
+// The files are named by sequence number and step name.
+func (recorder *Recorder) WriteRawFiles(dir string) (filenames []string, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	for i, rec := range recorder.Recorded() {
+		r, err := http.NewRequest(rec.Method, rec.URL, bytes.NewReader(rec.Body))
+		if err != nil {
+			return filenames, err
+		}
+		r.Header = rec.Header.Clone()
+		r.Header.Del("Accept-Encoding") // added (and the response decompressed) by the transport when replaying
+		var raw bytes.Buffer
+		if err := r.Write(&raw); err != nil {
+			return filenames, err
+		}
+		filename := filepath.Join(dir, fmt.Sprintf("%03d-%s.txt", i+1, slug(rec.Step)))
+		if err := ioutil.WriteFile(filename, raw.Bytes(), 0644); err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, nil
+}
+
+// GenerateScenarioCode generates the scenario code of the recorded requests (see GenerateScenarioCode).
+func (recorder *Recorder) GenerateScenarioCode(options CodegenOptions) ([]byte, error) {
+	if len(options.Source) == 0 {
+		options.Source = "a proxy recording"
+	}
+	return GenerateScenarioCode(recorder.Recorded(), options)
+}
+
+var nonSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(s string) string {
+	return strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// defaultCADir is the .goverrun directory within the home directory.
+func defaultCADir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".goverrun"
+	}
+	return filepath.Join(home, ".goverrun")
+}
+
+// recordFromArgs runs the record subcommand until interrupted and writes the recording.
+func recordFromArgs() {
+	steps, err := ParseStepNameRules(*CommandlineArgs.Record.Steps)
+	CheckErrAndLogFatal(err, "invalid step names")
+	recorder, err := NewRecorder(RecorderConfig{
+		Address:   *CommandlineArgs.Record.Listen,
+		CADir:     *CommandlineArgs.Record.CADir,
+		StepNames: steps,
+		IdleGap:   *CommandlineArgs.Record.IdleGap,
+	})
+	CheckErrAndLogFatal(err, "unable to create recording proxy")
+	CheckErrAndLogFatal(recorder.Start(), "unable to start recording proxy")
+	LogInfo("Recording proxy listening on", recorder.Addr(), "(stop recording with Ctrl+C)")
+	LogInfo("For HTTPS trust the CA certificate", filepath.Join(*CommandlineArgs.Record.CADir, caCertFilename), "(also served at http://"+recorder.Addr()+"/)")
+	interrupted := notifyInterrupt()
+	defer stopInterruptNotify(interrupted) // only after the recording is written
+	<-interrupted
+	_ = recorder.Close()
+
+	output := *CommandlineArgs.Record.Output
+	switch *CommandlineArgs.Record.Format {
+	case "raw":
+		filenames, err := recorder.WriteRawFiles(output)
+		CheckErrAndLogFatal(err, "unable to write raw request files")
+		LogSuccess(len(filenames), "raw request files written to:", output)
+	case "code":
+		options := CodegenOptions{IncludeStaticAssets: *CommandlineArgs.Record.IncludeStaticAssets}
+		if hosts := *CommandlineArgs.Record.Hosts; len(hosts) > 0 {
+			options.Hosts = strings.Split(hosts, ",")
+		}
+		code, err := recorder.GenerateScenarioCode(options)
+		CheckErrAndLogFatal(err, "unable to generate scenario")
+		err = ioutil.WriteFile(output, code, 0644)
+		CheckErrAndLogFatal(err, "unable to write scenario")
+		LogSuccess("Scenario code written to:", output)
+	default:
+		LogFatal("Unknown output format (choose from 'code' and 'raw'): ", *CommandlineArgs.Record.Format)
+	}
+}
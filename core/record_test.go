@@ -0,0 +1,208 @@
+package goverrun
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	backend := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			if r.Method == http.MethodPost {
+				http.Redirect(w, r, "/account", http.StatusSeeOther)
+				return
+			}
+			_, _ = io.WriteString(w, `<form><input name="csrf" value="token-1234567890"></form>`)
+		case "/account":
+			_, _ = io.WriteString(w, "welcome")
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	plain := httptest.NewServer(http.HandlerFunc(backend))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(backend))
+	defer secure.Close()
+	SkipCertificateValidation = true // of the backends (by the recorder)
+	defer Reset()
+
+	caDir := t.TempDir()
+	recorder, err := NewRecorder(RecorderConfig{
+		Address:   "127.0.0.1:0",
+		CADir:     caDir,
+		StepNames: []StepNameRule{{Name: "submit login", Pattern: regexp.MustCompile(`^POST .*/login$`)}},
+		IdleGap:   200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(recorder.CACertificatePEM()) {
+		t.Fatal("invalid CA certificate")
+	}
+	proxyURL, _ := url.Parse("http://" + recorder.Addr())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL), TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	rsp, err := client.Get(secure.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(rsp.Body)
+	_ = rsp.Body.Close()
+	if !strings.Contains(string(body), "token-1234567890") {
+		t.Fatalf("unexpected intercepted response: %s", body)
+	}
+	rsp, err = client.PostForm(secure.URL+"/login", url.Values{"user": {"alice"}, "csrf": {"token-1234567890"}})
+	if err != nil || rsp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected redirected response: %v", err)
+	}
+	_ = rsp.Body.Close()
+	time.Sleep(250 * time.Millisecond) // idle gap starting a new step
+	rsp, err = client.Get(plain.URL + "/account?tab=orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rsp.Body.Close()
+
+	recorded := recorder.Recorded()
+	if len(recorded) != 4 {
+		t.Fatalf("expected 4 recorded requests: %+v", recorded)
+	}
+	for i, expected := range []string{"GET /login", "submit login", "submit login", "GET /account"} {
+		if recorded[i].Step != expected {
+			t.Errorf("expected step %q of request %d: %q", expected, i+1, recorded[i].Step)
+		}
+	}
+	if recorded[1].StatusCode != http.StatusSeeOther || recorded[1].RedirectURL != "/account" || !strings.HasPrefix(recorded[0].URL, "https://") {
+		t.Errorf("unexpected recorded redirect: %+v", recorded[1])
+	}
+
+	filenames, err := recorder.WriteRawFiles(filepath.Join(t.TempDir(), "raw"))
+	if err != nil || len(filenames) != 4 || filepath.Base(filenames[1]) != "002-submit-login.txt" {
+		t.Fatalf("unexpected raw files: %v %v", err, filenames)
+	}
+	f, err := os.Open(filenames[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	replayed, err := http.ReadRequest(bufio.NewReader(f))
+	if err != nil || replayed.Method != http.MethodPost || replayed.ParseForm() != nil || replayed.PostForm.Get("csrf") != "token-1234567890" {
+		t.Errorf("expected raw file to be readable as request: %v %+v", err, replayed)
+	}
+
+	code, err := recorder.GenerateScenarioCode(CodegenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`user.Step("submit login")`, `// TODO correlate form param "csrf"`, `SetFormParam("user", "alice")`, "AssertStatusCode(200)"} {
+		if !strings.Contains(string(code), expected) {
+			t.Errorf("expected generated code to contain %q:\n%s", expected, code)
+		}
+	}
+
+	// the CA is reused and served when requested directly
+	reloaded, err := NewRecorder(RecorderConfig{CADir: caDir})
+	if err != nil || string(reloaded.CACertificatePEM()) != string(recorder.CACertificatePEM()) {
+		t.Errorf("expected CA to be reused: %v", err)
+	}
+	rsp, err = http.Get("http://" + recorder.Addr() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served, _ := io.ReadAll(rsp.Body)
+	_ = rsp.Body.Close()
+	if string(served) != string(recorder.CACertificatePEM()) {
+		t.Error("expected CA certificate to be served")
+	}
+}
+
+func TestParseStepNameRules(t *testing.T) {
+	rules, err := ParseStepNameRules("login=/login, cart=/api/cart$")
+	if err != nil || len(rules) != 2 || rules[1].Name != "cart" || !rules[1].Pattern.MatchString("PUT https://shop/api/cart") {
+		t.Errorf("unexpected rules: %v %+v", err, rules)
+	}
+	if _, err := ParseStepNameRules("no-pattern"); err == nil {
+		t.Error("expected invalid rule error")
+	}
+}
+
+func TestRecordFromArgsInterrupted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts can't be sent to the own process")
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer backend.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+	output := filepath.Join(t.TempDir(), "raw")
+	defineTestCommandlineFlags()
+	if err := ParseCommandline([]string{"record", "-listen", address, "-ca-dir", t.TempDir(), "-format", "raw", "-out", output}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		recordFromArgs()
+		close(done)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		interruptLock.Lock()
+		handlers := interruptHandlers
+		interruptLock.Unlock()
+		if handlers == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("recording proxy not started")
+		}
+	}
+	proxyURL, _ := url.Parse("http://" + address)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	rsp, err := client.Get(backend.URL + "/orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rsp.Body.Close()
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(os.Interrupt); err != nil { // must not stop the tests (see init)
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording not stopped by interrupt")
+	}
+	if files, err := filepath.Glob(filepath.Join(output, "001-*.txt")); err != nil || len(files) != 1 {
+		t.Errorf("expected written raw request file, got %v %v", files, err)
+	}
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/montanaflynn/stats"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
+	"google.golang.org/grpc/codes"
 	"io"
 	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -27,16 +29,27 @@ var localizationPrinter = message.NewPrinter(language.Make(defaultLanguage))
 
 type Stats struct {
 	Title               string
+	Step                string // name of the step (empty for the overall results)
 	HasUnmetExpectation bool
 
 	Counts                                 Counts
 	StatusCodes                            map[int]int
+	GRPCCodes                              map[int]int // status codes of gRPC calls
+	Protocols                              map[string]int
+	SourceAddresses                        map[string]int    // local IPs the requests were sent from
+	GraphQLOperations                      map[string]Counts // per GraphQL operation name
+	OpenConnectionsPerMinute               map[time.Time]int // maxima of open connections of the scenarios per minute, summed up
 	FailureTypes, ErrorTypes, TimeoutTypes map[string]int
 	RequestBytes, ResponseBytes            uint64
 
-	TTFB, TARS, TRRT                                                []float64 `json:"-"` // ignore in JSON as instead of raw-data we want the analyzed result data (AnalyzedResults)
-	TimeToFirstByte, TimeAfterRequestSent, TotalRequestResponseTime AnalyzedResults
-	Expectation                                                     Expectation
+	TTFB, TARS, TRRT, PLT, DNS                                                                   []float64 `json:"-"` // ignore in JSON as instead of raw-data we want the analyzed result data (AnalyzedResults)
+	TimeToFirstByte, TimeAfterRequestSent, TotalRequestResponseTime, PageLoadTime, DNSLookupTime AnalyzedResults
+	Resources                                                                                    ResourceCounts
+	Messages                                                                                     MessageCounts // of WebSockets
+	TTFE, IEG                                                                                    []float64     `json:"-"` // time to first event and inter-event gaps of streamed responses
+	TimeToFirstEvent, InterEventGap                                                              AnalyzedResults
+	StreamEvents                                                                                 uint64
+	Expectation                                                                                  Expectation
 }
 
 type AnalyzedResults struct {
@@ -84,11 +97,19 @@ func GenerateResultsReport(reportPath string) (unmetExpectation bool) {
 		stepNamesInChronologicalOrder []string
 
 		// collect overall total step stats
-		overallStatusCodes                                          = make(map[int]int)
-		overallFailureTypes, overallErrorTypes, overallTimeoutTypes = make(map[string]int), make(map[string]int), make(map[string]int)
-		overallCounts                                               Counts
-		overallTTFB, overallPARS, overallTODU                       []float64
-		recordingEnv                                                Environment
+		overallStatusCodes                                            = make(map[int]int)
+		overallGRPCCodes                                              = make(map[int]int)
+		overallProtocols                                              = make(map[string]int)
+		overallSourceAddresses                                        = make(map[string]int)
+		overallOperations                                             = make(map[string]Counts)
+		overallOpenConnections                                        = make(map[string]map[time.Time]int) // by scenario
+		overallFailureTypes, overallErrorTypes, overallTimeoutTypes   = make(map[string]int), make(map[string]int), make(map[string]int)
+		overallCounts                                                 Counts
+		overallTTFB, overallPARS, overallTODU, overallPLT, overallDNS []float64
+		overallResources                                              ResourceCounts
+		overallMessages                                               MessageCounts
+		overallStream                                                 streamValues
+		recordingEnv                                                  Environment
 
 		// collect traffic amounts
 		overallRequestBytes, overallResponseBytes uint64
@@ -179,58 +200,110 @@ func GenerateResultsReport(reportPath string) (unmetExpectation bool) {
 	report.StepNamesInChronologicalOrder = stepNamesInChronologicalOrder
 	for i, stepName := range stepNamesInChronologicalOrder {
 		stepStatusCodes := make(map[int]int)
+		stepGRPCCodes := make(map[int]int)
+		stepProtocols := make(map[string]int)
+		stepSourceAddresses := make(map[string]int)
+		stepOperations := make(map[string]Counts)
+		stepOpenConnections := make(map[string]map[time.Time]int) // by scenario
 		stepFailureTypes, stepErrorTypes, stepTimeoutTypes := make(map[string]int), make(map[string]int), make(map[string]int)
 		var allStepCounts Counts
-		var stepTTFB, stepPARS, stepTODU []float64
+		var stepTTFB, stepPARS, stepTODU, stepPLT, stepDNS []float64
+		var stepResources ResourceCounts
+		var stepMessages MessageCounts
+		var stepStream streamValues
 		var stepRequestBytes, stepResponseBytes uint64
 		var latestExpectation Expectation
 		for j, stepFile := range stepFiles[stepName] { // could be multiple step-files per step due to merging of directories from distributed runs
 			// parse step file
-			allCounts, parsedStepExpectation,
-				valuesTTFB, valuesTTFBRS, valuesTODU,
-				statusCodes, failureTypes, errorTypes, timeoutTypes,
-				_, _, _, _, //valuesPerMinuteBlockTTFB, valuesPerMinuteBlockPARS, valuesPerMinuteBlockTODU, countsPerMinuteBlock,
-				requestBytes, responseBytes,
-				example := parseStepFile(stepFile)
+			parsed := parseStepFile(stepFile)
 
 			if j == 0 {
-				examples[stepName] = example
+				examples[stepName] = parsed.example
 			}
 
 			// use the expectation from the latest step file parsed (when multiple are parsed)
-			latestExpectation = parsedStepExpectation
+			latestExpectation = parsed.expectation
 
 			// track results
-			stepRequestBytes += requestBytes
-			stepResponseBytes += responseBytes
-			stepTTFB = append(stepTTFB, valuesTTFB...)
-			stepPARS = append(stepPARS, valuesTTFBRS...)
-			stepTODU = append(stepTODU, valuesTODU...)
-			for k, v := range statusCodes {
+			stepRequestBytes += parsed.requestBytes
+			stepResponseBytes += parsed.responseBytes
+			stepTTFB = append(stepTTFB, parsed.valuesTTFB...)
+			stepPARS = append(stepPARS, parsed.valuesPARS...)
+			stepTODU = append(stepTODU, parsed.valuesTODU...)
+			stepPLT = append(stepPLT, parsed.valuesPLT...)
+			stepDNS = append(stepDNS, parsed.valuesDNS...)
+			stepResources.add(parsed.resources)
+			stepMessages.add(parsed.messages)
+			stepStream.add(parsed.stream)
+			for k, v := range parsed.statusCodes {
 				stepStatusCodes[k] += v
 			}
-			for k, v := range failureTypes {
+			for k, v := range parsed.grpcCodes {
+				stepGRPCCodes[k] += v
+			}
+			for k, v := range parsed.protocols {
+				stepProtocols[k] += v
+			}
+			for k, v := range parsed.sourceAddresses {
+				stepSourceAddresses[k] += v
+			}
+			for k, v := range parsed.operations {
+				stepOperations[k] = stepOperations[k].add(v)
+			}
+			for scenario, perMinute := range parsed.openConnections {
+				for k, v := range perMinute {
+					openConnectionsOf(stepOpenConnections, scenario)[k] += v // multiple step files are from distributed load generators (i.e. distinct connections)
+				}
+			}
+			for k, v := range parsed.failureTypes {
 				stepFailureTypes[k] += v
 			}
-			for k, v := range errorTypes {
+			for k, v := range parsed.errorTypes {
 				stepErrorTypes[k] += v
 			}
-			for k, v := range timeoutTypes {
+			for k, v := range parsed.timeoutTypes {
 				stepTimeoutTypes[k] += v
 			}
-			allStepCounts.Requests += allCounts.Requests
-			allStepCounts.Timeouts += allCounts.Timeouts
-			allStepCounts.Failures += allCounts.Failures
-			allStepCounts.Errors += allCounts.Errors
+			allStepCounts.Requests += parsed.counts.Requests
+			allStepCounts.Timeouts += parsed.counts.Timeouts
+			allStepCounts.Failures += parsed.counts.Failures
+			allStepCounts.Errors += parsed.counts.Errors
+			allStepCounts.CacheHits += parsed.counts.CacheHits
+			allStepCounts.CacheRevalidations += parsed.counts.CacheRevalidations
 		}
 
 		// also track overall
 		overallTTFB = append(overallTTFB, stepTTFB...)
 		overallPARS = append(overallPARS, stepPARS...)
 		overallTODU = append(overallTODU, stepTODU...)
+		overallPLT = append(overallPLT, stepPLT...)
+		overallDNS = append(overallDNS, stepDNS...)
+		overallResources.add(stepResources)
+		overallMessages.add(stepMessages)
+		overallStream.add(stepStream)
 		for k, v := range stepStatusCodes {
 			overallStatusCodes[k] += v
 		}
+		for k, v := range stepGRPCCodes {
+			overallGRPCCodes[k] += v
+		}
+		for k, v := range stepProtocols {
+			overallProtocols[k] += v
+		}
+		for k, v := range stepSourceAddresses {
+			overallSourceAddresses[k] += v
+		}
+		for k, v := range stepOperations {
+			overallOperations[k] = overallOperations[k].add(v)
+		}
+		for scenario, perMinute := range stepOpenConnections {
+			overallPerMinute := openConnectionsOf(overallOpenConnections, scenario)
+			for k, v := range perMinute {
+				if v > overallPerMinute[k] { // the steps of a scenario share its connections
+					overallPerMinute[k] = v
+				}
+			}
+		}
 		for k, v := range stepFailureTypes {
 			overallFailureTypes[k] += v
 		}
@@ -244,20 +317,34 @@ func GenerateResultsReport(reportPath string) (unmetExpectation bool) {
 		overallCounts.Timeouts += allStepCounts.Timeouts
 		overallCounts.Failures += allStepCounts.Failures
 		overallCounts.Errors += allStepCounts.Errors
+		overallCounts.CacheHits += allStepCounts.CacheHits
+		overallCounts.CacheRevalidations += allStepCounts.CacheRevalidations
 		overallRequestBytes += stepRequestBytes
 		overallResponseBytes += stepResponseBytes
 
 		report.StatsByStep[stepName] = Stats{
-			Counts:        allStepCounts,
-			TTFB:          stepTTFB,
-			TARS:          stepPARS,
-			TRRT:          stepTODU,
-			StatusCodes:   stepStatusCodes,
-			FailureTypes:  stepFailureTypes,
-			ErrorTypes:    stepErrorTypes,
-			TimeoutTypes:  stepTimeoutTypes,
-			RequestBytes:  stepRequestBytes,
-			ResponseBytes: stepResponseBytes,
+			Counts:                   allStepCounts,
+			TTFB:                     stepTTFB,
+			TARS:                     stepPARS,
+			TRRT:                     stepTODU,
+			PLT:                      stepPLT,
+			DNS:                      stepDNS,
+			Resources:                stepResources,
+			Messages:                 stepMessages,
+			TTFE:                     stepStream.timeToFirstEvent,
+			IEG:                      stepStream.interEventGaps,
+			StreamEvents:             stepStream.events,
+			StatusCodes:              stepStatusCodes,
+			GRPCCodes:                stepGRPCCodes,
+			Protocols:                stepProtocols,
+			SourceAddresses:          stepSourceAddresses,
+			GraphQLOperations:        stepOperations,
+			OpenConnectionsPerMinute: sumOpenConnectionsOfScenarios(stepOpenConnections),
+			FailureTypes:             stepFailureTypes,
+			ErrorTypes:               stepErrorTypes,
+			TimeoutTypes:             stepTimeoutTypes,
+			RequestBytes:             stepRequestBytes,
+			ResponseBytes:            stepResponseBytes,
 		}
 		report.ExampleByStep[stepName] = examples[stepName]
 
@@ -266,6 +353,7 @@ func GenerateResultsReport(reportPath string) (unmetExpectation bool) {
 		sb.WriteString(fmt.Sprintf("=======================================================================\nStep '%s'\n=======================================================================\n", stepName))
 		statsCollected := report.StatsByStep[stepName]
 		statsCollected.Title = "Step " + strconv.Itoa(i+1)
+		statsCollected.Step = stepName
 		statsCollected.Expectation = latestExpectation
 		sb.WriteString("\n\n")
 		sb.WriteString(analyzeExpectation(&statsCollected))
@@ -289,16 +377,28 @@ func GenerateResultsReport(reportPath string) (unmetExpectation bool) {
 	}
 
 	report.OverallStats = Stats{
-		Counts:        overallCounts,
-		TTFB:          overallTTFB,
-		TARS:          overallPARS,
-		TRRT:          overallTODU,
-		StatusCodes:   overallStatusCodes,
-		FailureTypes:  overallFailureTypes,
-		ErrorTypes:    overallErrorTypes,
-		TimeoutTypes:  overallTimeoutTypes,
-		RequestBytes:  overallRequestBytes,
-		ResponseBytes: overallResponseBytes,
+		Counts:                   overallCounts,
+		TTFB:                     overallTTFB,
+		TARS:                     overallPARS,
+		TRRT:                     overallTODU,
+		PLT:                      overallPLT,
+		DNS:                      overallDNS,
+		Resources:                overallResources,
+		Messages:                 overallMessages,
+		TTFE:                     overallStream.timeToFirstEvent,
+		IEG:                      overallStream.interEventGaps,
+		StreamEvents:             overallStream.events,
+		StatusCodes:              overallStatusCodes,
+		GRPCCodes:                overallGRPCCodes,
+		Protocols:                overallProtocols,
+		SourceAddresses:          overallSourceAddresses,
+		GraphQLOperations:        overallOperations,
+		OpenConnectionsPerMinute: sumOpenConnectionsOfScenarios(overallOpenConnections),
+		FailureTypes:             overallFailureTypes,
+		ErrorTypes:               overallErrorTypes,
+		TimeoutTypes:             overallTimeoutTypes,
+		RequestBytes:             overallRequestBytes,
+		ResponseBytes:            overallResponseBytes,
 	}
 
 	// print overall results as text
@@ -331,14 +431,48 @@ func GenerateResultsReport(reportPath string) (unmetExpectation bool) {
 	return
 }
 
-func parseStepFile(stepFile string) (allCounts Counts, parsedStepExpectation Expectation,
-	valuesTTFB, valuesPARS, valuesTODU []float64,
-	statusCodes map[int]int,
-	failureTypes, errorTypes, timeoutTypes map[string]int,
-	valuesPerMinuteBlockTTFB, valuesPerMinuteBlockPARS, valuesPerMinuteBlockTODU [][]float64,
-	countsPerMinuteBlock []Counts,
-	requestBytes, responseBytes uint64,
-	example string) {
+// parsedStepFile is the content of a step file (see parseStepFile).
+type parsedStepFile struct {
+	counts                                                                       Counts
+	expectation                                                                  Expectation
+	valuesTTFB, valuesPARS, valuesTODU, valuesPLT, valuesDNS                     []float64
+	statusCodes, grpcCodes                                                       map[int]int
+	protocols, sourceAddresses                                                   map[string]int
+	failureTypes, errorTypes, timeoutTypes                                       map[string]int
+	valuesPerMinuteBlockTTFB, valuesPerMinuteBlockPARS, valuesPerMinuteBlockTODU [][]float64
+	countsPerMinuteBlock                                                         []Counts
+	requestBytes, responseBytes                                                  uint64
+	resources                                                                    ResourceCounts
+	messages                                                                     MessageCounts
+	stream                                                                       streamValues
+	operations                                                                   map[string]Counts
+	openConnections                                                              map[string]map[time.Time]int // maximum per minute by scenario
+	example                                                                      string
+}
+
+// openConnectionsOf returns the open connections per minute of the scenario (open connections are tracked per scenario).
+func openConnectionsOf(byScenario map[string]map[time.Time]int, scenario string) map[time.Time]int {
+	perMinute, ok := byScenario[scenario]
+	if !ok {
+		perMinute = make(map[time.Time]int)
+		byScenario[scenario] = perMinute
+	}
+	return perMinute
+}
+
+// sumOpenConnectionsOfScenarios sums up the open connections of the scenarios per minute.
+func sumOpenConnectionsOfScenarios(byScenario map[string]map[time.Time]int) map[time.Time]int {
+	sum := make(map[time.Time]int)
+	for _, perMinute := range byScenario {
+		for minute, connections := range perMinute {
+			sum[minute] += connections
+		}
+	}
+	return sum
+}
+
+func parseStepFile(stepFile string) *parsedStepFile {
+	parsed := &parsedStepFile{}
 	recordedStepFile, err := os.Open(stepFile)
 	panicOnErr(err)
 	defer recordedStepFile.Close()
@@ -356,15 +490,18 @@ func parseStepFile(stepFile string) (allCounts Counts, parsedStepExpectation Exp
 		LogError("unable to decode step name:", err)
 	}
 	// parse the step expectation
-	if err := dec.Decode(&parsedStepExpectation); err != nil {
+	if err := dec.Decode(&parsed.expectation); err != nil {
 		LogError("unable to decode expectation:", err)
 	}
 	// tracking maps
-	statusCodes = make(map[int]int)
-	failureTypes, errorTypes, timeoutTypes = make(map[string]int), make(map[string]int), make(map[string]int)
+	parsed.statusCodes, parsed.grpcCodes = make(map[int]int), make(map[int]int)
+	parsed.protocols, parsed.sourceAddresses = make(map[string]int), make(map[string]int)
+	parsed.operations = make(map[string]Counts)
+	parsed.openConnections = make(map[string]map[time.Time]int)
+	parsed.failureTypes, parsed.errorTypes, parsed.timeoutTypes = make(map[string]int), make(map[string]int), make(map[string]int)
 	// values per minute blocks
-	valuesPerMinuteBlockTTFB, valuesPerMinuteBlockPARS, valuesPerMinuteBlockTODU = make([][]float64, 0), make([][]float64, 0), make([][]float64, 0)
-	countsPerMinuteBlock = make([]Counts, 0)
+	parsed.valuesPerMinuteBlockTTFB, parsed.valuesPerMinuteBlockPARS, parsed.valuesPerMinuteBlockTODU = make([][]float64, 0), make([][]float64, 0), make([][]float64, 0)
+	parsed.countsPerMinuteBlock = make([]Counts, 0)
 	currentMinuteBlock := -1
 	// parse the complete list of stepEntry (until EOF) - thereby also testing if it is parsable
 	for { // read them all until EOF
@@ -376,61 +513,133 @@ func parseStepFile(stepFile string) (allCounts Counts, parsedStepExpectation Exp
 				LogError(err)
 			}
 		}
-		allCounts.Requests++
-		requestBytes += uint64(stepEntry.RequestSize)
-		responseBytes += uint64(stepEntry.ResponseSize)
-		if len(example) == 0 {
+		parsed.counts.Requests++
+		parsed.requestBytes += uint64(stepEntry.RequestSize)
+		parsed.responseBytes += uint64(stepEntry.ResponseSize)
+		if len(parsed.example) == 0 {
 			// TODO Record one sample request for the detailed report
-			// example = stepEntry.Example
+			// parsed.example = stepEntry.Example
 		}
 		// populate values per minute blocks
 		currentMinute := stepEntry.Timestamps.Start.Minute()
 		if currentMinuteBlock != currentMinute {
 			currentMinuteBlock = currentMinute
-			valuesPerMinuteBlockTTFB = append(valuesPerMinuteBlockTTFB, make([]float64, 0))
-			valuesPerMinuteBlockPARS = append(valuesPerMinuteBlockPARS, make([]float64, 0))
-			valuesPerMinuteBlockTODU = append(valuesPerMinuteBlockTODU, make([]float64, 0))
-			countsPerMinuteBlock = append(countsPerMinuteBlock, Counts{})
+			parsed.valuesPerMinuteBlockTTFB = append(parsed.valuesPerMinuteBlockTTFB, make([]float64, 0))
+			parsed.valuesPerMinuteBlockPARS = append(parsed.valuesPerMinuteBlockPARS, make([]float64, 0))
+			parsed.valuesPerMinuteBlockTODU = append(parsed.valuesPerMinuteBlockTODU, make([]float64, 0))
+			parsed.countsPerMinuteBlock = append(parsed.countsPerMinuteBlock, Counts{})
 		}
 		// track the timestamps
 		if ttfb, completed := stepEntry.Timestamps.TimeToFirstByte(false); completed {
-			valuesTTFB = append(valuesTTFB, float64(ttfb.Nanoseconds()))
-			valuesPerMinuteBlockTTFB[len(valuesPerMinuteBlockTTFB)-1] = append(valuesPerMinuteBlockTTFB[len(valuesPerMinuteBlockTTFB)-1], float64(ttfb.Nanoseconds()))
+			parsed.valuesTTFB = append(parsed.valuesTTFB, float64(ttfb.Nanoseconds()))
+			parsed.valuesPerMinuteBlockTTFB[len(parsed.valuesPerMinuteBlockTTFB)-1] = append(parsed.valuesPerMinuteBlockTTFB[len(parsed.valuesPerMinuteBlockTTFB)-1], float64(ttfb.Nanoseconds()))
 		}
 		if pars, completed := stepEntry.Timestamps.TimeToFirstByte(true); completed {
-			valuesPARS = append(valuesPARS, float64(pars.Nanoseconds()))
-			valuesPerMinuteBlockPARS[len(valuesPerMinuteBlockPARS)-1] = append(valuesPerMinuteBlockPARS[len(valuesPerMinuteBlockPARS)-1], float64(pars.Nanoseconds()))
+			parsed.valuesPARS = append(parsed.valuesPARS, float64(pars.Nanoseconds()))
+			parsed.valuesPerMinuteBlockPARS[len(parsed.valuesPerMinuteBlockPARS)-1] = append(parsed.valuesPerMinuteBlockPARS[len(parsed.valuesPerMinuteBlockPARS)-1], float64(pars.Nanoseconds()))
 		}
 		if todu, completed := stepEntry.Timestamps.TotalDuration(); completed {
-			valuesTODU = append(valuesTODU, float64(todu.Nanoseconds()))
-			valuesPerMinuteBlockTODU[len(valuesPerMinuteBlockTODU)-1] = append(valuesPerMinuteBlockTODU[len(valuesPerMinuteBlockTODU)-1], float64(todu.Nanoseconds()))
+			parsed.valuesTODU = append(parsed.valuesTODU, float64(todu.Nanoseconds()))
+			parsed.valuesPerMinuteBlockTODU[len(parsed.valuesPerMinuteBlockTODU)-1] = append(parsed.valuesPerMinuteBlockTODU[len(parsed.valuesPerMinuteBlockTODU)-1], float64(todu.Nanoseconds()))
+		}
+		if plt, completed := stepEntry.Timestamps.PageLoadTime(); completed {
+			parsed.valuesPLT = append(parsed.valuesPLT, float64(plt.Nanoseconds()))
+		}
+		if dns, completed := stepEntry.Timestamps.DNSLookupTime(); completed {
+			parsed.valuesDNS = append(parsed.valuesDNS, float64(dns.Nanoseconds()))
+		}
+		// track the embedded resources
+		for _, resource := range stepEntry.Resources {
+			parsed.resources.Requests++
+			if resource.Error || resource.Timeout || resource.StatusCode >= 400 {
+				parsed.resources.Failures++
+			}
+			parsed.resources.RequestBytes += uint64(resource.RequestSize)
+			parsed.resources.ResponseBytes += uint64(resource.ResponseSize)
+			if resource.CacheHit {
+				parsed.resources.CacheHits++
+			}
+			if resource.CacheRevalidated {
+				parsed.resources.CacheRevalidations++
+			}
+		}
+		// track the WebSocket messages
+		parsed.messages.Sent += uint64(stepEntry.MessagesSent)
+		parsed.messages.Received += uint64(stepEntry.MessagesReceived)
+		// track the streamed events
+		if stepEntry.StreamEvents > 0 {
+			parsed.stream.events += uint64(stepEntry.StreamEvents)
+			parsed.stream.timeToFirstEvent = append(parsed.stream.timeToFirstEvent, float64(stepEntry.TimeToFirstEvent.Nanoseconds()))
+			for _, gap := range stepEntry.InterEventGaps {
+				parsed.stream.interEventGaps = append(parsed.stream.interEventGaps, float64(gap.Nanoseconds()))
+			}
 		}
 		// track the status codes
 		if stepEntry.StatusCode > 0 {
-			statusCodes[stepEntry.StatusCode]++
+			parsed.statusCodes[stepEntry.StatusCode]++
+		}
+		if stepEntry.Protocol == "grpc" {
+			parsed.grpcCodes[int(stepEntry.GRPCCode)]++
+		}
+		// track the maximum of open connections per minute
+		openConnections := openConnectionsOf(parsed.openConnections, stepEntry.Scenario)
+		if minute := stepEntry.Timestamps.Start.Truncate(time.Minute); stepEntry.OpenConnections > openConnections[minute] {
+			openConnections[minute] = stepEntry.OpenConnections
+		}
+		// track the protocols
+		if len(stepEntry.Protocol) > 0 {
+			parsed.protocols[stepEntry.Protocol]++
+		}
+		// track the source IPs
+		if len(stepEntry.LocalAddress) > 0 {
+			parsed.sourceAddresses[stepEntry.LocalAddress]++
 		}
 		// track the Requests
-		countsPerMinuteBlock[len(countsPerMinuteBlock)-1].Requests++
+		parsed.countsPerMinuteBlock[len(parsed.countsPerMinuteBlock)-1].Requests++
+		// track the cache usage
+		if stepEntry.CacheHit {
+			parsed.counts.CacheHits++
+			parsed.countsPerMinuteBlock[len(parsed.countsPerMinuteBlock)-1].CacheHits++
+		}
+		if stepEntry.CacheRevalidated {
+			parsed.counts.CacheRevalidations++
+			parsed.countsPerMinuteBlock[len(parsed.countsPerMinuteBlock)-1].CacheRevalidations++
+		}
 		// track the Failures
 		if stepEntry.AssertionFailed {
-			allCounts.Failures++
-			failureTypes[stepEntry.AssertionFailedRootCause]++
-			countsPerMinuteBlock[len(countsPerMinuteBlock)-1].Failures++
+			parsed.counts.Failures++
+			parsed.failureTypes[stepEntry.AssertionFailedRootCause]++
+			parsed.countsPerMinuteBlock[len(parsed.countsPerMinuteBlock)-1].Failures++
 		}
 		// track the Errors
 		if stepEntry.Error {
-			allCounts.Errors++
-			errorTypes[stepEntry.ErrorRootCause]++
-			countsPerMinuteBlock[len(countsPerMinuteBlock)-1].Errors++
+			parsed.counts.Errors++
+			parsed.errorTypes[stepEntry.ErrorRootCause]++
+			parsed.countsPerMinuteBlock[len(parsed.countsPerMinuteBlock)-1].Errors++
 		}
 		// track the Timeouts
 		if stepEntry.Timeout {
-			allCounts.Timeouts++
-			timeoutTypes[stepEntry.TimeoutRootCause]++
-			countsPerMinuteBlock[len(countsPerMinuteBlock)-1].Timeouts++
+			parsed.counts.Timeouts++
+			parsed.timeoutTypes[stepEntry.TimeoutRootCause]++
+			parsed.countsPerMinuteBlock[len(parsed.countsPerMinuteBlock)-1].Timeouts++
+		}
+		// track the GraphQL operations
+		if len(stepEntry.GraphQLOperation) > 0 {
+			counts := parsed.operations[stepEntry.GraphQLOperation]
+			counts.Requests++
+			if stepEntry.AssertionFailed {
+				counts.Failures++
+			}
+			if stepEntry.Error {
+				counts.Errors++
+			}
+			if stepEntry.Timeout {
+				counts.Timeouts++
+			}
+			parsed.operations[stepEntry.GraphQLOperation] = counts
 		}
 	}
-	return
+	return parsed
 }
 
 func analyzeExpectation(stats *Stats) (result string) {
@@ -492,6 +701,11 @@ func analyzeExpectation(stats *Stats) (result string) {
 	if unmet {
 		stats.HasUnmetExpectation = true
 	}
+	s, unmet = writePercentileDurationExpectations(stats.Expectation.PageLoadTimePercentileLimits, stats.PLT, "percentile duration expectation of Page-Load-Time (PLT)")
+	sb.WriteString(s)
+	if unmet {
+		stats.HasUnmetExpectation = true
+	}
 	s, unmet = writeTotalBytesExpectation(stats.Expectation.TotalRequestBytesWithin, stats.RequestBytes, "total request bytes expectation")
 	sb.WriteString(s)
 	if unmet {
@@ -502,7 +716,12 @@ func analyzeExpectation(stats *Stats) (result string) {
 	if unmet {
 		stats.HasUnmetExpectation = true
 	}
-	s, unmet = writeStatusCodeExpectations(stats.Expectation.StatusCodeThresholds, stats.StatusCodes)
+	s, unmet = writeStatusCodeExpectations(stats.Expectation.StatusCodeThresholds, stats.StatusCodes, "status code")
+	sb.WriteString(s)
+	if unmet {
+		stats.HasUnmetExpectation = true
+	}
+	s, unmet = writeStatusCodeExpectations(stats.Expectation.GRPCCodeThresholds, stats.GRPCCodes, "gRPC status code")
 	sb.WriteString(s)
 	if unmet {
 		stats.HasUnmetExpectation = true
@@ -563,7 +782,7 @@ func writeTypeMatchesPercentageExpectations(thresholds []*TypeMatchesThreshold,
 	return sb.String(), unmetExpectation
 }
 
-func writeStatusCodeExpectations(thresholds []*StatusCodeExpectation, codes map[int]int) (result string, unmetExpectation bool) {
+func writeStatusCodeExpectations(thresholds []*StatusCodeExpectation, codes map[int]int, label string) (result string, unmetExpectation bool) {
 	var sb strings.Builder
 	for _, t := range thresholds {
 		totalCount := 0
@@ -595,7 +814,7 @@ func writeStatusCodeExpectations(thresholds []*StatusCodeExpectation, codes map[
 				}
 			}
 			t.ActualValue = actualPercentage
-			sb.WriteString(localizationPrinter.Sprintf("%s status code percentage expectation: wanted %s %4.2f%% of status code %d: got %4.2f%%\n", met, which, t.Percentage, t.StatusCode, actualPercentage))
+			sb.WriteString(localizationPrinter.Sprintf("%s %s percentage expectation: wanted %s %4.2f%% of %s %d: got %4.2f%%\n", met, label, which, t.Percentage, label, t.StatusCode, actualPercentage))
 		}
 	}
 	return sb.String(), unmetExpectation
@@ -688,6 +907,15 @@ func printDistributions(stats *Stats) (result string) {
 	sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: Errors\n", stats.Counts.Errors, stats.Counts.ErrorPercentage()))
 	sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: Timeouts\n", stats.Counts.Timeouts, stats.Counts.TimeoutPercentage()))
 
+	if stats.Counts.CacheHits > 0 || stats.Counts.CacheRevalidations > 0 {
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString(localizationPrinter.Sprintln("HTTP Cache:", stats.Counts.CacheHits+stats.Counts.CacheRevalidations))
+		sb.WriteString("-----------------------------------------------------------------------\n")
+		sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: Cache Hits\n", stats.Counts.CacheHits, float64(stats.Counts.CacheHits)/float64(stats.Counts.Requests)*100))
+		sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: Revalidations (304)\n", stats.Counts.CacheRevalidations, float64(stats.Counts.CacheRevalidations)/float64(stats.Counts.Requests)*100))
+	}
+
 	statusCodesSum := 0
 	for _, count := range stats.StatusCodes {
 		statusCodesSum += count
@@ -700,6 +928,76 @@ func printDistributions(stats *Stats) (result string) {
 		sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: Response Status %d\n", pair.value, float64(pair.value)/float64(stats.Counts.Requests)*100, pair.key))
 	}
 
+	if len(stats.GRPCCodes) > 0 {
+		grpcCodesSum := 0
+		for _, count := range stats.GRPCCodes {
+			grpcCodesSum += count
+		}
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString(localizationPrinter.Sprintln("gRPC Status Codes:", grpcCodesSum))
+		sb.WriteString("-----------------------------------------------------------------------\n")
+		for _, pair := range sortByCountInt(stats.GRPCCodes) {
+			sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: %d %s\n", pair.value, float64(pair.value)/float64(stats.Counts.Requests)*100, pair.key, codes.Code(pair.key.(int))))
+		}
+	}
+
+	protocolsSum := 0
+	for _, count := range stats.Protocols {
+		protocolsSum += count
+	}
+	sb.WriteString("\n")
+	sb.WriteString("\n")
+	sb.WriteString(localizationPrinter.Sprintln("Protocols:", protocolsSum))
+	sb.WriteString("-----------------------------------------------------------------------\n")
+	for _, pair := range sortByCount(stats.Protocols) {
+		sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: %s\n", pair.value, float64(pair.value)/float64(stats.Counts.Requests)*100, pair.key))
+	}
+
+	if len(stats.SourceAddresses) > 0 {
+		sourceAddressesSum := 0
+		for _, count := range stats.SourceAddresses {
+			sourceAddressesSum += count
+		}
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString(localizationPrinter.Sprintln("Source IPs:", sourceAddressesSum))
+		sb.WriteString("-----------------------------------------------------------------------\n")
+		for _, pair := range sortByCount(stats.SourceAddresses) {
+			sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: %s\n", pair.value, float64(pair.value)/float64(stats.Counts.Requests)*100, pair.key))
+		}
+	}
+
+	if len(stats.GraphQLOperations) > 0 {
+		operationRequests := make(map[string]int)
+		for operation, counts := range stats.GraphQLOperations {
+			operationRequests[operation] = int(counts.Requests)
+		}
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString("GraphQL Operations:\n")
+		sb.WriteString("-----------------------------------------------------------------------\n")
+		for _, pair := range sortByCount(operationRequests) {
+			counts := stats.GraphQLOperations[pair.key.(string)]
+			sb.WriteString(localizationPrinter.Sprintf("%9d = %6.2f%%: %s (%d failures, %d errors, %d timeouts)\n", pair.value, float64(pair.value)/float64(stats.Counts.Requests)*100, pair.key, counts.Failures, counts.Errors, counts.Timeouts))
+		}
+	}
+
+	if len(stats.OpenConnectionsPerMinute) > 0 {
+		var minutes []time.Time
+		for minute := range stats.OpenConnectionsPerMinute {
+			minutes = append(minutes, minute)
+		}
+		sort.Slice(minutes, func(i, j int) bool { return minutes[i].Before(minutes[j]) })
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString("Open Connections (maxima of the scenarios per minute, summed up):\n")
+		sb.WriteString("-----------------------------------------------------------------------\n")
+		for _, minute := range minutes {
+			sb.WriteString(localizationPrinter.Sprintf("%s: %9d\n", minute.Format("2006-01-02 15:04"), stats.OpenConnectionsPerMinute[minute]))
+		}
+	}
+
 	sb.WriteString("\n")
 	sb.WriteString("\n")
 	sb.WriteString(localizationPrinter.Sprintln("Failures:", stats.Counts.Failures))
@@ -782,6 +1080,93 @@ func printDistributions(stats *Stats) (result string) {
 	stats.TimeAfterRequestSent.Histogram = resultHistogram
 	sb.WriteString(s)
 
+	if stats.Messages.Sent > 0 || stats.Messages.Received > 0 {
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString(localizationPrinter.Sprintf("WebSocket Messages: %d\n", stats.Messages.Sent+stats.Messages.Received))
+		sb.WriteString("-----------------------------------------------------------------------\n")
+		sb.WriteString(localizationPrinter.Sprintf("Sent:           %15d\n", stats.Messages.Sent))
+		sb.WriteString(localizationPrinter.Sprintf("Received:       %15d\n", stats.Messages.Received))
+	}
+
+	if stats.StreamEvents > 0 {
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString(localizationPrinter.Sprintln("Time-to-First-Event (TTFE):", len(stats.TTFE), "Streams with", stats.StreamEvents, "Events"))
+		sb.WriteString("-----------------------------------------------------------------------")
+		sb.WriteString("\n>>> Stats <<<\n")
+		s, resultStats = printStats(stats.TTFE)
+		stats.TimeToFirstEvent.Stats = resultStats
+		sb.WriteString(s)
+		sb.WriteString("\n>>> Percentiles <<<\n")
+		s, resultPercentiles = printPercentiles(stats.TTFE)
+		stats.TimeToFirstEvent.Percentiles = resultPercentiles
+		sb.WriteString(s)
+		sb.WriteString("\n>>> Histogram <<<\n")
+		s, resultHistogram = printHistogram(stats.TTFE)
+		stats.TimeToFirstEvent.Histogram = resultHistogram
+		sb.WriteString(s)
+
+		if len(stats.IEG) > 0 {
+			sb.WriteString("\n")
+			sb.WriteString("\n")
+			sb.WriteString(localizationPrinter.Sprintln("Inter-Event-Gap (IEG):", len(stats.IEG), "Gaps"))
+			sb.WriteString("-----------------------------------------------------------------------")
+			sb.WriteString("\n>>> Stats <<<\n")
+			s, resultStats = printStats(stats.IEG)
+			stats.InterEventGap.Stats = resultStats
+			sb.WriteString(s)
+			sb.WriteString("\n>>> Percentiles <<<\n")
+			s, resultPercentiles = printPercentiles(stats.IEG)
+			stats.InterEventGap.Percentiles = resultPercentiles
+			sb.WriteString(s)
+		}
+	}
+
+	if len(stats.PLT) > 0 || stats.Resources.Requests > 0 {
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString(localizationPrinter.Sprintf("Embedded Resources: %d\n", stats.Resources.Requests))
+		sb.WriteString("-----------------------------------------------------------------------\n")
+		sb.WriteString(localizationPrinter.Sprintf("Failures:       %15d\n", stats.Resources.Failures))
+		sb.WriteString(localizationPrinter.Sprintf("Request Bytes:  %15d\n", stats.Resources.RequestBytes))
+		sb.WriteString(localizationPrinter.Sprintf("Response Bytes: %15d\n", stats.Resources.ResponseBytes))
+		sb.WriteString(localizationPrinter.Sprintf("Cache Hits:     %15d\n", stats.Resources.CacheHits))
+		sb.WriteString(localizationPrinter.Sprintf("Revalidations:  %15d\n", stats.Resources.CacheRevalidations))
+
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString(localizationPrinter.Sprintln("Page-Load-Time (PLT):", len(stats.PLT), "Requests"))
+		sb.WriteString("-----------------------------------------------------------------------")
+		sb.WriteString("\n>>> Stats <<<\n")
+		s, resultStats = printStats(stats.PLT)
+		stats.PageLoadTime.Stats = resultStats
+		sb.WriteString(s)
+		sb.WriteString("\n>>> Percentiles <<<\n")
+		s, resultPercentiles = printPercentiles(stats.PLT)
+		stats.PageLoadTime.Percentiles = resultPercentiles
+		sb.WriteString(s)
+		sb.WriteString("\n>>> Histogram <<<\n")
+		s, resultHistogram = printHistogram(stats.PLT)
+		stats.PageLoadTime.Histogram = resultHistogram
+		sb.WriteString(s)
+	}
+
+	if len(stats.DNS) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString("\n")
+		sb.WriteString(localizationPrinter.Sprintln("DNS-Lookup-Time:", len(stats.DNS), "Lookups"))
+		sb.WriteString("-----------------------------------------------------------------------")
+		sb.WriteString("\n>>> Stats <<<\n")
+		s, resultStats = printStats(stats.DNS)
+		stats.DNSLookupTime.Stats = resultStats
+		sb.WriteString(s)
+		sb.WriteString("\n>>> Percentiles <<<\n")
+		s, resultPercentiles = printPercentiles(stats.DNS)
+		stats.DNSLookupTime.Percentiles = resultPercentiles
+		sb.WriteString(s)
+	}
+
 	sb.WriteString("\n")
 	return sb.String()
 }
@@ -0,0 +1,137 @@
+package goverrun
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ResolverConfig configures how the users of a scenario resolve host names, e.g. to target a single node behind
+// a load balancer or a blue/green slot without editing /etc/hosts. Only the dialed IP changes, the Host header
+// and the TLS server name (SNI) stay the ones of the requested URL.
+type ResolverConfig struct {
+	Hosts     map[string][]string // static host name to IP(s) mappings (multiple IPs are used round-robin per new connection)
+	DNSServer string              // custom DNS server like 10.0.0.53 or 10.0.0.53:5353 (instead of the system resolver)
+	StickyIP  bool                // each user sticks to one of the IPs of a host (chosen by user number) instead of round-robin
+}
+
+// dnsRoundRobin tracks the round-robin position per scenario and host of the statically mapped IPs.
+var dnsRoundRobin = safeTracker{counters: make(map[string]int)}
+
+// Validate checks the config for invalid values.
+func (config ResolverConfig) Validate() error {
+	for host, ips := range config.Hosts {
+		if len(ips) == 0 {
+			return fmt.Errorf("no IPs mapped for host '%s'", host)
+		}
+		for _, ip := range ips {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("invalid IP '%s' mapped for host '%s'", ip, host)
+			}
+		}
+	}
+	if len(config.DNSServer) > 0 {
+		host, port, err := net.SplitHostPort(config.dnsServerAddress())
+		if err != nil {
+			return fmt.Errorf("invalid DNS server '%s': %w", config.DNSServer, err)
+		}
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 || (net.ParseIP(host) == nil && strings.ContainsAny(host, ":/")) {
+			return fmt.Errorf("invalid DNS server '%s'", config.DNSServer)
+		}
+	}
+	return nil
+}
+
+func (config ResolverConfig) enabled() bool {
+	return len(config.Hosts) > 0 || len(config.DNSServer) > 0 || config.StickyIP
+}
+
+func (config ResolverConfig) dnsServerAddress() string {
+	if _, _, err := net.SplitHostPort(config.DNSServer); err == nil {
+		return config.DNSServer
+	}
+	return net.JoinHostPort(strings.Trim(config.DNSServer, "[]"), "53")
+}
+
+// resolver resolves the host names of a single user (or of all users of a scenario sharing the transport).
+type resolver struct {
+	config      ResolverConfig
+	hosts       map[string][]string // lower-case host names
+	dns         *net.Resolver
+	scenario    string
+	currentUser int
+}
+
+func newResolver(config ResolverConfig, dialer *net.Dialer, scenario string, currentUser int) *resolver {
+	r := &resolver{config: config, hosts: make(map[string][]string), dns: net.DefaultResolver, scenario: scenario, currentUser: currentUser}
+	for host, ips := range config.Hosts {
+		r.hosts[strings.ToLower(host)] = ips
+	}
+	if len(config.DNSServer) > 0 {
+		server := config.dnsServerAddress()
+		r.dns = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return r
+}
+
+// addresses returns the addresses (IP and port) to dial for the given address in the order to try them.
+// DNS lookups are done with the request context so that their duration is traced (see Timestamps.DNSLookupTime).
+func (r *resolver) addresses(ctx context.Context, addr string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, static := r.hosts[strings.ToLower(host)]
+	if !static {
+		if ip := net.ParseIP(host); ip != nil {
+			return []string{addr}, nil
+		}
+		resolved, err := r.dns.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range resolved {
+			ips = append(ips, ip.String())
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no IPs found for host '%s'", host)
+		}
+	}
+	var first int
+	switch {
+	case r.config.StickyIP && r.currentUser > 0:
+		first = (r.currentUser - 1) % len(ips)
+	case static:
+		first = (dnsRoundRobin.Inc(r.scenario+" "+strings.ToLower(host)) - 1) % len(ips)
+	}
+	addresses := make([]string, 0, len(ips))
+	for i := range ips {
+		addresses = append(addresses, net.JoinHostPort(ips[(first+i)%len(ips)], port))
+	}
+	if r.config.StickyIP {
+		return addresses[:1], nil // sticky users don't fall back to other nodes
+	}
+	return addresses, nil
+}
+
+// dial connects to the first reachable of the resolved addresses.
+func (r *resolver) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	addresses, err := r.addresses(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	for _, address := range addresses {
+		if conn, err = dialer.DialContext(ctx, network, address); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,137 @@
+package goverrun
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolverAddresses(t *testing.T) {
+	defer Reset()
+	config := ResolverConfig{Hosts: map[string][]string{"Node.Example": {"10.0.0.1", "10.0.0.2"}}}
+	r := newResolver(config, &net.Dialer{}, "round-robin test", 1)
+	first, err := r.addresses(context.Background(), "node.example:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := r.addresses(context.Background(), "node.example:443")
+	if fmt.Sprint(first) != "[10.0.0.1:443 10.0.0.2:443]" || fmt.Sprint(second) != "[10.0.0.2:443 10.0.0.1:443]" {
+		t.Errorf("expected round-robin: got %v and %v", first, second)
+	}
+
+	config.StickyIP = true
+	for _, currentUser := range []int{2, 4} {
+		r := newResolver(config, &net.Dialer{}, "sticky test", currentUser)
+		for i := 0; i < 2; i++ {
+			if addresses, _ := r.addresses(context.Background(), "node.example:80"); fmt.Sprint(addresses) != "[10.0.0.2:80]" {
+				t.Errorf("user %d: expected sticky IP: got %v", currentUser, addresses)
+			}
+		}
+	}
+
+	for _, config := range []ResolverConfig{
+		{Hosts: map[string][]string{"node.example": {}}},
+		{Hosts: map[string][]string{"node.example": {"not-an-ip"}}},
+		{DNSServer: "10.0.0.53:port:53"},
+	} {
+		if err := (TransportConfig{Resolver: config}).Validate(); err == nil {
+			t.Errorf("%+v: expected validation error", config)
+		}
+	}
+}
+
+func TestResolverPinning(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Host, " ", r.TLS.ServerName)
+	}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// the certificate of the test server is valid for example.com
+	config := TransportConfig{
+		TLS:      TLSConfig{RootCAFiles: []string{caFile}},
+		Resolver: ResolverConfig{Hosts: map[string][]string{"example.com": {"127.0.0.1"}}},
+	}
+	transport, err := NewRoundTripperWrapperWithConfig(config, ConnectionPolicy{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport}}
+	response := user.Step("pinned").Request(http.MethodGet, "https://example.com:"+port+"/").SendWithoutTimeout()
+	if want := "example.com:" + port + " example.com"; response.Error != nil || string(response.Body) != want {
+		t.Errorf("got %q (%v) want %q", response.Body, response.Error, want)
+	}
+	if _, completed := response.Timestamps.DNSLookupTime(); completed {
+		t.Error("expected no DNS lookup for a statically mapped host")
+	}
+}
+
+func TestResolverDNSServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Host)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// minimal DNS server answering all A queries with 127.0.0.1
+	dnsServer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dnsServer.Close()
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, addr, err := dnsServer.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buffer[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			answer := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if question := query.Questions[0]; question.Type == dnsmessage.TypeA {
+				answer.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			}
+			if packed, err := answer.Pack(); err == nil {
+				_, _ = dnsServer.WriteTo(packed, addr)
+			}
+		}
+	}()
+
+	config := TransportConfig{Resolver: ResolverConfig{DNSServer: dnsServer.LocalAddr().String()}}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	transport, err := NewRoundTripperWrapperWithConfig(config, ConnectionPolicy{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport}}
+	response := user.Step("custom DNS").Request(http.MethodGet, "http://service.goverrun.test:"+port+"/").SendWithoutTimeout()
+	if response.Error != nil || !strings.HasPrefix(string(response.Body), "service.goverrun.test:") {
+		t.Fatalf("got %q (%v)", response.Body, response.Error)
+	}
+	if _, completed := response.Timestamps.DNSLookupTime(); !completed {
+		t.Error("expected the DNS lookup time to be recorded")
+	}
+}
@@ -0,0 +1,304 @@
+package goverrun
+
+import (
+	"context"
+	"github.com/andybalholm/cascadia"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultResourceParallelism = 6 // like the connection pool per host of common browsers
+
+// EmbeddedResources configures the browser-like fetching of resources (stylesheets, scripts, images, fonts, etc.)
+// referenced by an HTML response (see Request.FetchEmbeddedResources).
+type EmbeddedResources struct {
+	Parallelism  int
+	AllowedHosts []string // hosts besides the same origin, also as wildcard like *.cdn.example.com
+}
+
+// ResourceEntry is the recorded result of a fetched embedded resource (i.e. a child entry of a StepEntry).
+type ResourceEntry struct {
+	URL              string
+	Timestamps       Timestamps
+	StatusCode       int
+	Timeout          bool
+	Error            bool
+	RootCause        string
+	RequestSize      int
+	ResponseSize     int
+	CacheHit         bool
+	CacheRevalidated bool
+}
+
+var (
+	reCSSURL    = regexp.MustCompile(`url\(\s*['"]?([^'")]+?)['"]?\s*\)`)
+	reCSSImport = regexp.MustCompile(`@import\s+['"]([^'"]+)['"]`)
+
+	resourceSelectors = []struct {
+		selector  cascadia.Selector
+		attribute string
+	}{
+		{cascadia.MustCompile("link[rel~=stylesheet][href]"), "href"},
+		{cascadia.MustCompile("link[rel~=icon][href]"), "href"},
+		{cascadia.MustCompile("link[rel~=preload][href]"), "href"},
+		{cascadia.MustCompile("script[src]"), "src"},
+		{cascadia.MustCompile("img[src]"), "src"},
+		{cascadia.MustCompile("input[type=image][src]"), "src"},
+		{cascadia.MustCompile("video[poster]"), "poster"},
+		{cascadia.MustCompile("audio[src], video[src], source[src], track[src]"), "src"},
+		{cascadia.MustCompile("embed[src]"), "src"},
+	}
+	selectorInlineStyle = cascadia.MustCompile("style")
+)
+
+// FetchEmbeddedResources enables the browser-like fetching of the resources referenced by the HTML response
+// (stylesheets, scripts, images, media, icons as well as fonts and images referenced by the stylesheets).
+// Only resources of the same origin as the document or of the allowed hosts are fetched, with the given
+// parallelism (zero or less means the default of 6 parallel requests). The fetched resources are recorded as child
+// entries of the step, and the page-load time (i.e. including all resources) is tracked besides the document time.
+func (req *Request) FetchEmbeddedResources(parallelism int, allowedHosts ...string) *Request {
+	if parallelism <= 0 {
+		parallelism = defaultResourceParallelism
+	}
+	req.EmbeddedResources = &EmbeddedResources{
+		Parallelism:  parallelism,
+		AllowedHosts: allowedHosts,
+	}
+	return req
+}
+
+// PageLoadTime returns the duration from the start of the document request until all embedded resources are fetched.
+func (stats *Timestamps) PageLoadTime() (d time.Duration, completed bool) {
+	if stats.PageLoadDone.IsZero() {
+		return 0, false
+	}
+	res := stats.PageLoadDone.Sub(stats.Start)
+	if res < 0 {
+		res = 0
+	}
+	return res, true
+}
+
+func (config *EmbeddedResources) isAllowed(document, resource *url.URL) bool {
+	if resource.Scheme != "http" && resource.Scheme != "https" {
+		return false
+	}
+	if resource.Scheme == document.Scheme && resource.Host == document.Host {
+		return true
+	}
+	host := resource.Hostname()
+	for _, allowed := range config.AllowedHosts {
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) || strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedResourceURLs returns the allowed and de-duplicated resource URLs referenced by the HTML document.
+func (response *Response) embeddedResourceURLs(config *EmbeddedResources, documentURL *url.URL) (resourceURLs []string) {
+	document, err := response.HTMLDocument()
+	if err != nil {
+		return nil
+	}
+	base := response.baseURL(document)
+	seen := map[string]bool{}
+	add := func(reference string) {
+		reference = strings.TrimSpace(reference)
+		if len(reference) == 0 || strings.HasPrefix(reference, "data:") {
+			return
+		}
+		u, err := base.Parse(reference)
+		if err != nil {
+			return
+		}
+		u.Fragment = ""
+		if !seen[u.String()] && config.isAllowed(documentURL, u) {
+			seen[u.String()] = true
+			resourceURLs = append(resourceURLs, u.String())
+		}
+	}
+	for _, s := range resourceSelectors {
+		for _, node := range s.selector.MatchAll(document) {
+			reference, _ := attribute(node, s.attribute)
+			add(reference)
+		}
+	}
+	for _, node := range selectorInlineStyle.MatchAll(document) {
+		if node.FirstChild != nil {
+			for _, reference := range cssReferences(node.FirstChild.Data) {
+				add(reference)
+			}
+		}
+	}
+	return resourceURLs
+}
+
+func cssReferences(css string) (references []string) {
+	for _, match := range reCSSImport.FindAllStringSubmatch(css, -1) {
+		references = append(references, match[1])
+	}
+	for _, match := range reCSSURL.FindAllStringSubmatch(css, -1) {
+		references = append(references, match[1])
+	}
+	return references
+}
+
+// fetchEmbeddedResources fetches the embedded resources of the (successful HTML) document response in waves:
+// first the resources referenced by the document, then the ones referenced by the fetched stylesheets (and so on).
+func (user *User) fetchEmbeddedResources(request *Request, document *Response) {
+	config := request.EmbeddedResources
+	if document.ConsideredUnsuccessful() || !strings.Contains(document.Header.Get("Content-Type"), "html") {
+		return
+	}
+	documentURL, err := url.Parse(document.FinalURL)
+	if err != nil {
+		return
+	}
+	seen := map[string]bool{document.FinalURL: true}
+	wave := document.embeddedResourceURLs(config, documentURL)
+	for len(wave) > 0 {
+		for _, resourceURL := range wave {
+			seen[resourceURL] = true
+		}
+		fetched := user.fetchResources(request, document.FinalURL, wave, config.Parallelism)
+		document.Resources = append(document.Resources, fetched...)
+		wave = nil
+		for _, resource := range fetched {
+			if resource.ConsideredUnsuccessful() || !strings.Contains(resource.Header.Get("Content-Type"), "css") {
+				continue
+			}
+			stylesheetURL, err := url.Parse(resource.FinalURL)
+			if err != nil {
+				continue
+			}
+			for _, reference := range cssReferences(string(resource.Body)) {
+				u, err := stylesheetURL.Parse(strings.TrimSpace(reference))
+				if err != nil || strings.HasPrefix(reference, "data:") {
+					continue
+				}
+				u.Fragment = ""
+				if !seen[u.String()] && config.isAllowed(documentURL, u) {
+					seen[u.String()] = true
+					wave = append(wave, u.String())
+				}
+			}
+		}
+	}
+	document.Timestamps.PageLoadDone = time.Now()
+}
+
+func (user *User) fetchResources(request *Request, referer string, resourceURLs []string, parallelism int) []*Response {
+	fetched := make([]*Response, len(resourceURLs))
+	indexes := make(chan int)
+	var interceptorLock sync.Mutex // the request interceptors are never called concurrently for the same user
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism && i < len(resourceURLs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				fetched[index] = user.fetchResource(request, referer, resourceURLs[index], &interceptorLock)
+			}
+		}()
+	}
+	for i := range resourceURLs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return fetched
+}
+
+func (user *User) fetchResource(request *Request, referer, resourceURL string, interceptorLock *sync.Mutex) *Response {
+	rsp := &Response{
+		Scenario:   user.Scenario,
+		Step:       request.Step,
+		RequestURL: resourceURL,
+		Timestamps: &Timestamps{},
+	}
+	ctx := context.Background()
+	if request.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, request.Timeout)
+		defer cancel()
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, resourceURL, nil)
+	if err != nil {
+		rsp.Error = err
+		return rsp
+	}
+	r.Header.Set("Referer", referer)
+	r.Header.Set("Accept", "*/*")
+	user.addTaggingHeaders(r, request.Step)
+	interceptorLock.Lock()
+	user.callRequestInterceptors(r)
+	interceptorLock.Unlock()
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: rsp.gotFirstResponseByte,
+		WroteRequest:         rsp.wroteRequest,
+	}
+	outcome := &cacheOutcome{}
+	rsp.Timestamps.Start = time.Now()
+	responseOfCall, err := user.HttpClient.Do(r.WithContext(context.WithValue(httptrace.WithClientTrace(ctx, trace), cacheOutcomeKey{}, outcome)))
+	rsp.Timestamps.Done = time.Now()
+	if err != nil {
+		rsp.trackError(err)
+	}
+	if responseOfCall != nil && responseOfCall.Body != nil {
+		defer responseOfCall.Body.Close()
+		rsp.Body, err = io.ReadAll(responseOfCall.Body)
+		if err != nil {
+			rsp.trackError(err)
+		}
+		rsp.Header = responseOfCall.Header
+		rsp.StatusCode = responseOfCall.StatusCode
+		rsp.Status = responseOfCall.Status
+		rsp.Protocol = responseOfCall.Proto
+		rsp.FinalURL = responseOfCall.Request.URL.String()
+		rsp.ResponseSize = HeaderSize(responseOfCall.Header) + len(rsp.Body)
+	}
+	rsp.RequestSize = HeaderSize(r.Header)
+	rsp.trackCacheOutcome(outcome)
+	return rsp
+}
+
+// ResourceCounts are the collected counts of fetched embedded resources.
+type ResourceCounts struct {
+	Requests, Failures            uint64 // failures are errors, timeouts and status codes >= 400
+	RequestBytes, ResponseBytes   uint64
+	CacheHits, CacheRevalidations uint64
+}
+
+func (counts *ResourceCounts) add(other ResourceCounts) {
+	counts.Requests += other.Requests
+	counts.Failures += other.Failures
+	counts.RequestBytes += other.RequestBytes
+	counts.ResponseBytes += other.ResponseBytes
+	counts.CacheHits += other.CacheHits
+	counts.CacheRevalidations += other.CacheRevalidations
+}
+
+func (response *Response) resourceEntries() (entries []ResourceEntry) {
+	for _, resource := range response.Resources {
+		entries = append(entries, ResourceEntry{
+			URL:              resource.RequestURL,
+			Timestamps:       *resource.Timestamps,
+			StatusCode:       resource.StatusCode,
+			Timeout:          resource.Timeout != nil,
+			Error:            resource.Error != nil,
+			RootCause:        UnwrapDeepestError(resource.Error) + UnwrapDeepestError(resource.Timeout),
+			RequestSize:      resource.RequestSize,
+			ResponseSize:     resource.ResponseSize,
+			CacheHit:         resource.CacheHit,
+			CacheRevalidated: resource.CacheRevalidated,
+		})
+	}
+	return entries
+}
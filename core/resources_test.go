@@ -0,0 +1,99 @@
+package goverrun
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchEmbeddedResources(t *testing.T) {
+	var lock sync.Mutex
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		fetched = append(fetched, r.URL.Path)
+		lock.Unlock()
+		switch r.URL.Path {
+		case "/app/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, `<html><head><link rel="stylesheet" href="style.css"><script src="/app.js"></script>
+<link rel="icon" href="https://other.example.com/favicon.ico"><style>body { background: url('bg.png') }</style></head>
+<body><img src="logo.png"><img src="logo.png#dup"><img src="data:image/png;base64,AAAA"><img src="missing.png"></body></html>`)
+		case "/app/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			_, _ = fmt.Fprint(w, `@font-face { src: url("fonts/font.woff2") }`)
+		case "/app/missing.png":
+			http.NotFound(w, r)
+		default:
+			_, _ = fmt.Fprint(w, "resource")
+		}
+	}))
+	defer server.Close()
+	defer Reset()
+	var active, overlapping int32
+	AddRequestInterceptor(func(u *User, r *http.Request) {
+		if atomic.AddInt32(&active, 1) > 1 {
+			atomic.StoreInt32(&overlapping, 1)
+		}
+		u.Data["intercepted"] = u.Data["intercepted"].(int) + 1 // user data is not safe for concurrent use
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+	})
+
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: server.Client(), Data: map[string]interface{}{"intercepted": 0}}
+	response := user.Step("page").Request(http.MethodGet, server.URL+"/app/page.html").FetchEmbeddedResources(2).SendWithoutTimeout()
+	sort.Strings(fetched)
+	want := []string{"/app.js", "/app/bg.png", "/app/fonts/font.woff2", "/app/logo.png", "/app/missing.png", "/app/page.html", "/app/style.css"}
+	if fmt.Sprint(fetched) != fmt.Sprint(want) {
+		t.Errorf("got %v want %v", fetched, want)
+	}
+	if plt, completed := response.Timestamps.PageLoadTime(); !completed || plt < response.Timestamps.Done.Sub(response.Timestamps.Start) {
+		t.Errorf("unexpected page-load time: %v %v", plt, completed)
+	}
+	var counts ResourceCounts
+	for _, entry := range response.resourceEntries() {
+		counts.Requests++
+		if entry.StatusCode >= 400 {
+			counts.Failures++
+		}
+	}
+	if counts.Requests != 6 || counts.Failures != 1 {
+		t.Errorf("unexpected resource counts: %+v", counts)
+	}
+	if overlapping != 0 || user.Data["intercepted"] != 7 {
+		t.Errorf("expected request interceptors to be called one at a time for all 7 requests: %v %v", overlapping, user.Data["intercepted"])
+	}
+}
+
+func TestEmbeddedResourcesAllowedHosts(t *testing.T) {
+	config := &EmbeddedResources{AllowedHosts: []string{"*.cdn.example.com", "static.example.org"}}
+	document := mustParseURL("https://www.example.com/index.html")
+	for resource, allowed := range map[string]bool{
+		"https://www.example.com/app.js":       true,
+		"http://www.example.com/app.js":        false,
+		"https://eu.cdn.example.com/lib.js":    true,
+		"https://cdn.example.com/lib.js":       false,
+		"https://static.example.org/logo.png":  true,
+		"https://other.example.org/logo.png":   false,
+		"ftp://www.example.com/file.txt":       false,
+		"https://evilcdn.example.com/tracking": false,
+	} {
+		if got := config.isAllowed(document, mustParseURL(resource)); got != allowed {
+			t.Errorf("%s: got %v want %v", resource, got, allowed)
+		}
+	}
+}
+
+func mustParseURL(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,256 @@
+package goverrun
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// totalStepName names the summary of the overall results.
+const totalStepName = "(total)"
+
+// StepSummary is the summary of the reported results of a step (or of the overall results), see LoadResults.
+type StepSummary struct {
+	Step                        string
+	Requests, Successes         uint64
+	Failures, Errors, Timeouts  uint64
+	SuccessPercentage           float64
+	Mean, Median, P90, P95, P99 time.Duration // of the total request-response time
+	RequestBytes, ResponseBytes uint64
+	HasUnmetExpectation         bool
+}
+
+// StepComparison compares the summaries of a step of two runs, see CompareResults.
+type StepComparison struct {
+	Step              string
+	Baseline, Current *StepSummary // nil when the step is missing in one of the runs
+	P95Change         float64      // in percent
+	SuccessChange     float64      // in percentage points
+	Regressed         bool
+}
+
+// LoadResults loads the summaries of every step (in chronological order) followed by the overall results
+// from the JSON files written by GenerateResultsReport into the report folder.
+func LoadResults(reportPath string) ([]StepSummary, error) {
+	var summaries []StepSummary
+	for i := 1; ; i++ {
+		var stats Stats
+		err := readStatsFile(filepath.Join(reportPath, "step-"+strconv.Itoa(i)+".json"), &stats)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summarize(stats.Step, stats))
+	}
+	var overall Stats
+	if err := readStatsFile(filepath.Join(reportPath, "scenarios.json"), &overall); err != nil {
+		return nil, fmt.Errorf("no reported results in %s (generate them via the report subcommand): %w", reportPath, err)
+	}
+	return append(summaries, summarize(totalStepName, overall)), nil
+}
+
+func readStatsFile(filename string, stats *Stats) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", filename, err)
+	}
+	return nil
+}
+
+func summarize(step string, stats Stats) StepSummary {
+	trrt := stats.TotalRequestResponseTime
+	return StepSummary{
+		Step:                step,
+		Requests:            stats.Counts.Requests,
+		Successes:           stats.Counts.Successes(),
+		Failures:            stats.Counts.Failures,
+		Errors:              stats.Counts.Errors,
+		Timeouts:            stats.Counts.Timeouts,
+		SuccessPercentage:   stats.Counts.SuccessPercentage(),
+		Mean:                time.Duration(trrt.Stats.Mean),
+		Median:              time.Duration(trrt.Stats.Median),
+		P90:                 time.Duration(trrt.Percentiles.P90p00),
+		P95:                 time.Duration(trrt.Percentiles.P95p00),
+		P99:                 time.Duration(trrt.Percentiles.P99p00),
+		RequestBytes:        stats.RequestBytes,
+		ResponseBytes:       stats.ResponseBytes,
+		HasUnmetExpectation: stats.HasUnmetExpectation,
+	}
+}
+
+var resultColumns = []string{"step", "requests", "successes", "failures", "errors", "timeouts", "success_percentage",
+	"mean_ms", "median_ms", "p90_ms", "p95_ms", "p99_ms", "request_bytes", "response_bytes", "unmet_expectation"}
+
+func (summary StepSummary) values() []interface{} {
+	return []interface{}{summary.Step, summary.Requests, summary.Successes, summary.Failures, summary.Errors, summary.Timeouts,
+		roundTo(summary.SuccessPercentage, 2), millis(summary.Mean), millis(summary.Median), millis(summary.P90), millis(summary.P95),
+		millis(summary.P99), summary.RequestBytes, summary.ResponseBytes, summary.HasUnmetExpectation}
+}
+
+func millis(d time.Duration) float64 {
+	return roundTo(float64(d)/float64(time.Millisecond), 3)
+}
+
+func roundTo(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
+
+// ExportResults writes the summaries in the format csv (with a header row) or json (an array of objects),
+// durations are exported in milliseconds.
+func ExportResults(w io.Writer, summaries []StepSummary, format string) error {
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(resultColumns); err != nil {
+			return err
+		}
+		for _, summary := range summaries {
+			var record []string
+			for _, value := range summary.values() {
+				record = append(record, fmt.Sprint(value))
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json":
+		rows := make([]map[string]interface{}, 0, len(summaries))
+		for _, summary := range summaries {
+			row := make(map[string]interface{})
+			for i, value := range summary.values() {
+				row[resultColumns[i]] = value
+			}
+			rows = append(rows, row)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+	return fmt.Errorf("unsupported export format %q (expected csv or json)", format)
+}
+
+// CompareResults compares the steps (matched by name) of the current with the baseline run. A step regressed when its
+// 95th percentile of the total request-response time increased by more than maxLatencyIncrease percent or its success
+// percentage dropped by more than maxSuccessDrop percentage points. Steps of only one run are listed without regression.
+func CompareResults(baseline, current []StepSummary, maxLatencyIncrease, maxSuccessDrop float64) []StepComparison {
+	byStep := make(map[string]*StepSummary)
+	for i := range baseline {
+		byStep[baseline[i].Step] = &baseline[i]
+	}
+	var comparisons []StepComparison
+	seen := make(map[string]bool)
+	for i := range current {
+		comparison := StepComparison{Step: current[i].Step, Baseline: byStep[current[i].Step], Current: &current[i]}
+		seen[comparison.Step] = true
+		if comparison.Baseline != nil {
+			if comparison.Baseline.P95 > 0 {
+				comparison.P95Change = roundTo(100*float64(comparison.Current.P95-comparison.Baseline.P95)/float64(comparison.Baseline.P95), 2)
+			}
+			comparison.SuccessChange = roundTo(comparison.Current.SuccessPercentage-comparison.Baseline.SuccessPercentage, 2)
+			comparison.Regressed = comparison.P95Change > maxLatencyIncrease || -comparison.SuccessChange > maxSuccessDrop
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	for i := range baseline {
+		if !seen[baseline[i].Step] {
+			comparisons = append(comparisons, StepComparison{Step: baseline[i].Step, Baseline: &baseline[i]})
+		}
+	}
+	return comparisons
+}
+
+// WriteComparison writes the comparisons as a table.
+func WriteComparison(w io.Writer, comparisons []StepComparison) {
+	fmt.Fprintf(w, "%-40s %14s %14s %9s %10s %10s %9s  %s\n", "Step", "P95 baseline", "P95 current", "Change", "Success", "Success", "Change", "Result")
+	for _, comparison := range comparisons {
+		step := comparison.Step
+		if len(step) > 40 {
+			step = step[:37] + "..."
+		}
+		switch {
+		case comparison.Baseline == nil:
+			fmt.Fprintf(w, "%-40s %14s %14s %9s %10s %9.2f%% %9s  %s\n", step, "-", comparison.Current.P95.Round(time.Microsecond), "-", "-",
+				comparison.Current.SuccessPercentage, "-", "new")
+		case comparison.Current == nil:
+			fmt.Fprintf(w, "%-40s %14s %14s %9s %9.2f%% %10s %9s  %s\n", step, comparison.Baseline.P95.Round(time.Microsecond), "-", "-",
+				comparison.Baseline.SuccessPercentage, "-", "-", "missing")
+		default:
+			result := "ok"
+			if comparison.Regressed {
+				result = "REGRESSION"
+			}
+			fmt.Fprintf(w, "%-40s %14s %14s %+8.2f%% %9.2f%% %9.2f%% %+9.2f  %s\n", step, comparison.Baseline.P95.Round(time.Microsecond),
+				comparison.Current.P95.Round(time.Microsecond), comparison.P95Change, comparison.Baseline.SuccessPercentage,
+				comparison.Current.SuccessPercentage, comparison.SuccessChange, result)
+		}
+	}
+}
+
+// loadOrReportResults loads the results of the report folder (analyzing the raw results first when not yet reported).
+func loadOrReportResults(reportPath string) ([]StepSummary, error) {
+	summaries, err := LoadResults(reportPath)
+	if errors.Is(err, os.ErrNotExist) {
+		GenerateResultsReport(reportPath)
+		summaries, err = LoadResults(reportPath)
+	}
+	return summaries, err
+}
+
+// exportResultsFromArgs runs the export subcommand.
+func exportResultsFromArgs() {
+	reportPath, format := *CommandlineArgs.Export.Folder, strings.ToLower(*CommandlineArgs.Export.Format)
+	summaries, err := loadOrReportResults(reportPath)
+	CheckErrAndLogFatal(err, "unable to load results")
+	output := *CommandlineArgs.Export.Output
+	if len(output) == 0 {
+		output = filepath.Join(reportPath, "results."+format)
+	}
+	f, err := os.Create(output)
+	CheckErrAndLogFatal(err, "unable to create export file")
+	err = ExportResults(f, summaries, format)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	CheckErrAndLogFatal(err, "unable to export results")
+	LogSuccess("Results exported to:", output)
+}
+
+// compareResultsFromArgs runs the compare subcommand.
+func compareResultsFromArgs() {
+	if len(CommandlineArgs.SubcommandArgs) != 2 {
+		LogError("Expected the baseline and the current report folder (use as arguments at the end)")
+		os.Exit(ExitUsage)
+	}
+	baseline, err := loadOrReportResults(CommandlineArgs.SubcommandArgs[0])
+	CheckErrAndLogFatal(err, "unable to load baseline results")
+	current, err := loadOrReportResults(CommandlineArgs.SubcommandArgs[1])
+	CheckErrAndLogFatal(err, "unable to load current results")
+	comparisons := CompareResults(baseline, current, *CommandlineArgs.Compare.MaxLatencyIncrease, *CommandlineArgs.Compare.MaxSuccessDrop)
+	fmt.Println()
+	WriteComparison(os.Stdout, comparisons)
+	fmt.Println()
+	for _, comparison := range comparisons {
+		if comparison.Regressed {
+			LogWarning("Regression compared to baseline")
+			os.Exit(ExitUnmetExpectation)
+		}
+	}
+	LogSuccess("No regression compared to baseline")
+}
@@ -0,0 +1,159 @@
+package goverrun
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestResults(t *testing.T, folder string, p95 time.Duration, failures uint64) {
+	for i, stats := range []Stats{
+		{Step: "login", Counts: Counts{Requests: 100, Failures: failures}, RequestBytes: 1000, ResponseBytes: 5000},
+		{Step: "search", Counts: Counts{Requests: 50}},
+		{Counts: Counts{Requests: 150, Failures: failures}},
+	} {
+		stats.TotalRequestResponseTime.Stats.Mean = float64(p95 / 2)
+		stats.TotalRequestResponseTime.Percentiles.P95p00 = float64(p95)
+		data, err := json.Marshal(stats)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filename := "step-" + string(rune('1'+i)) + ".json"
+		if stats.Step == "" {
+			filename = "scenarios.json"
+		}
+		if err := os.WriteFile(filepath.Join(folder, filename), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExportResults(t *testing.T) {
+	folder := t.TempDir()
+	if _, err := LoadResults(folder); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+	writeTestResults(t, folder, 120*time.Millisecond, 2)
+	summaries, err := LoadResults(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 3 || summaries[0].Step != "login" || summaries[2].Step != "(total)" || summaries[0].SuccessPercentage != 98 {
+		t.Fatalf("unexpected summaries: %+v", summaries)
+	}
+
+	var csv bytes.Buffer
+	if err := ExportResults(&csv, summaries, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "step,requests,successes,") || lines[1] != "login,100,98,2,0,0,98,60,0,0,120,0,1000,5000,false" {
+		t.Errorf("unexpected CSV:\n%s", csv.String())
+	}
+	var export bytes.Buffer
+	if err := ExportResults(&export, summaries, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(export.Bytes(), &rows); err != nil || len(rows) != 3 || rows[1]["step"] != "search" || rows[1]["p95_ms"] != 120.0 {
+		t.Errorf("unexpected JSON (%v):\n%s", err, export.String())
+	}
+	if err := ExportResults(&export, summaries, "xml"); err == nil {
+		t.Error("expected unsupported format error")
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	baseline := []StepSummary{{Step: "login", P95: 100 * time.Millisecond, SuccessPercentage: 100}, {Step: "logout", P95: time.Millisecond}}
+	current := []StepSummary{{Step: "login", P95: 105 * time.Millisecond, SuccessPercentage: 98.5}, {Step: "search", P95: time.Millisecond}}
+	comparisons := CompareResults(baseline, current, 10, 1)
+	if len(comparisons) != 3 || comparisons[0].P95Change != 5 || comparisons[0].SuccessChange != -1.5 || !comparisons[0].Regressed ||
+		comparisons[1].Baseline != nil || comparisons[2].Step != "logout" || comparisons[2].Current != nil {
+		t.Errorf("unexpected comparisons: %+v", comparisons)
+	}
+	if CompareResults(baseline, current, 10, 2)[0].Regressed || !CompareResults(baseline, current, 4, 2)[0].Regressed {
+		t.Error("unexpected regression thresholds")
+	}
+	var table bytes.Buffer
+	WriteComparison(&table, comparisons)
+	if output := table.String(); !strings.Contains(output, "REGRESSION") || !strings.Contains(output, "new") || !strings.Contains(output, "missing") {
+		t.Errorf("unexpected table:\n%s", output)
+	}
+}
+
+func TestOpenConnectionsOfScenarios(t *testing.T) {
+	folder := t.TempDir()
+	minute := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	for i, step := range []struct {
+		name    string
+		entries []StepEntry
+	}{
+		{"login", []StepEntry{{Scenario: "shop", OpenConnections: 3}, {Scenario: "admin", OpenConnections: 2}}},
+		{"search", []StepEntry{{Scenario: "shop", OpenConnections: 5}, {Scenario: "shop", OpenConnections: 4}}},
+	} {
+		file, err := os.Create(filepath.Join(folder, fmt.Sprintf(stepDefaultFilenamePattern, i+1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gzw := gzip.NewWriter(file)
+		writer := &stepGobWriter{gobWriter: gobWriter{file: file, gzw: gzw, gobEncoder: gob.NewEncoder(gzw)}}
+		if err := writer.writeStepNameInit(step.name, Expectation{}); err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range step.entries {
+			entry.Timestamps.Start = minute.Add(time.Second)
+			if err := writer.writeStepEntry(&entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = gzw.Close()
+		_ = file.Close()
+	}
+	GenerateResultsReport(folder)
+
+	for filename, expected := range map[string]int{"step-1.json": 5, "step-2.json": 5, "scenarios.json": 7} {
+		data, err := os.ReadFile(filepath.Join(folder, filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stats Stats
+		if err := json.Unmarshal(data, &stats); err != nil {
+			t.Fatal(err)
+		}
+		if connections := stats.OpenConnectionsPerMinute[minute]; connections != expected {
+			t.Errorf("%s: got %d open connections want %d", filename, connections, expected)
+		}
+	}
+}
+
+func TestParseCommandline(t *testing.T) {
+	defineTestCommandlineFlags()
+	if err := ParseCommandline([]string{"compare", "-max-success-drop", "0.5", "baseline", "current"}); err != nil {
+		t.Fatal(err)
+	}
+	if !SubcommandCompare.Parsed() || *CommandlineArgs.Compare.MaxSuccessDrop != 0.5 || *CommandlineArgs.Compare.MaxLatencyIncrease != 10 ||
+		len(CommandlineArgs.SubcommandArgs) != 2 || CommandlineArgs.SubcommandArgs[1] != "current" {
+		t.Errorf("unexpected parsed arguments: %+v", CommandlineArgs.Compare)
+	}
+	if err := ParseCommandline(nil); !errors.Is(err, ErrUnknownSubcommand) {
+		t.Errorf("expected unknown subcommand error, got %v", err)
+	}
+	if err := ParseCommandline([]string{"bench"}); !errors.Is(err, ErrUnknownSubcommand) {
+		t.Errorf("expected unknown subcommand error, got %v", err)
+	}
+	if err := ParseCommandline([]string{"export", "-format"}); err == nil || errors.Is(err, ErrUnknownSubcommand) {
+		t.Errorf("expected flag error, got %v", err)
+	}
+	if err := ParseCommandline([]string{"run", "-h"}); err != flag.ErrHelp {
+		t.Errorf("expected help, got %v", err)
+	}
+}
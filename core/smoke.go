@@ -0,0 +1,229 @@
+package goverrun
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httputil"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+var (
+	// SmokeRedactedHeaders are the headers whose values are redacted in the dumps of the smoke mode (see RunSmoke).
+	SmokeRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token"}
+	// SmokeRedactedParameters are the (case-insensitive) names of query, form and JSON parameters whose values are
+	// redacted in the dumps of the smoke mode.
+	SmokeRedactedParameters = []string{"password", "passwd", "secret", "client_secret", "token", "access_token", "refresh_token", "api_key", "apikey"}
+	// SmokeMaxBodyDump limits the dumped bytes of every request and response body in the smoke mode.
+	SmokeMaxBodyDump = 4096
+
+	// internal
+	smoke         bool
+	smokeFailures uint64
+	smokeLock     sync.Mutex // guards User.smokeResponses
+	// redactedParameters caches the pattern of SmokeRedactedParameters (compiled again only when they change)
+	redactedParameters struct {
+		sync.Mutex
+		names   string
+		pattern *regexp.Regexp
+	}
+)
+
+// RunSmoke runs every non-ignored scenario exactly once with a single user to check that the scenarios work
+// (like against a new environment) before a real run. The load config is ignored (except the HTTP cache), think
+// times of the scenarios are kept. Every request and response is dumped (with secrets redacted, see
+// SmokeRedactedHeaders and SmokeRedactedParameters) and every assertion and extraction result is logged.
+// Nothing is written to a report folder. Returns whether any step failed (due to an error, timeout or assertion).
+func RunSmoke() (failed bool) {
+	smoke, smokeFailures = true, 0
+	defer func() {
+		smoke = false
+	}()
+	titles := make([]string, 0, len(scenarios))
+	for title, scenario := range scenarios {
+		if !scenario.Ignored {
+			titles = append(titles, title)
+		}
+	}
+	sort.Strings(titles)
+	for _, title := range titles {
+		scenario := scenarios[title]
+		LogInfo("Smoke testing scenario:", title)
+		failuresBefore := atomic.LoadUint64(&smokeFailures)
+		if err := runSmokeScenario(scenario); err != nil {
+			LogError("Unable to run scenario:", title, err)
+			atomic.AddUint64(&smokeFailures, 1)
+		}
+		if atomic.LoadUint64(&smokeFailures) > failuresBefore {
+			LogErrorf("Smoke test of scenario '%s' failed", title)
+		} else {
+			LogSuccessf("Smoke test of scenario '%s' passed", title)
+		}
+	}
+	return atomic.LoadUint64(&smokeFailures) > 0
+}
+
+func runSmokeScenario(scenario *Scenario) error {
+	if err := scenario.Transport.validateProtocolSupport(); err != nil {
+		return err
+	}
+	loadedTLS, err := scenario.Transport.TLS.load()
+	if err != nil {
+		return err
+	}
+	roundTripper := newRoundTripper(scenario.Transport, scenario.LoadConfig.Connections, loadedTLS.forUser(1), scenario.Title, 1)
+	transport := wrapRoundTripper(scenario.Transport, roundTripper, 1)
+	defer transport.CloseIdleConnections()
+	if scenario.LoadConfig.HTTPCache {
+		transport.Cache = NewHTTPCache()
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	user := User{
+		Scenario:    scenario.Title,
+		CurrentUser: 1,
+		CurrentLoop: 1,
+		HttpClient: &http.Client{
+			Transport: transport,
+			Jar:       jar,
+		},
+		Data:    make(map[string]interface{}),
+		tagging: scenario.Transport.Tagging,
+	}
+	defer user.closeWebSockets()
+	defer user.closeSockets()
+	scenario.Runner(&user)
+	atomic.AddUint64(&scenario.ExecutionCount, 1)
+	for _, response := range user.smokeResponses {
+		response.ArchiveStats() // logs (and counts failures of) the steps whose response was not archived by the scenario
+	}
+	return nil
+}
+
+// trackSmoke keeps the response of a step in the smoke mode, so its result counts even when it is not archived.
+func (user *User) trackSmoke(response *Response) *Response {
+	if smoke {
+		smokeLock.Lock()
+		user.smokeResponses = append(user.smokeResponses, response)
+		smokeLock.Unlock()
+	}
+	return response
+}
+
+// logSmokeStep logs the result of the step of the response in the smoke mode and counts failed ones.
+func (response *Response) logSmokeStep() {
+	name := ""
+	if response.Step != nil {
+		name = response.Step.Name
+	}
+	switch {
+	case response.Error != nil:
+		LogErrorf("Step '%s' failed with error: %v", name, response.Error)
+	case response.Timeout != nil:
+		LogErrorf("Step '%s' failed with timeout: %v", name, response.Timeout)
+	case response.IsFailed():
+		LogErrorf("Step '%s' failed: %s", name, response.AssertionFailed)
+	default:
+		LogSuccessf("Step '%s' passed (%s in %s)", name, response.Status, response.Timestamps.Done.Sub(response.Timestamps.Start))
+		return
+	}
+	atomic.AddUint64(&smokeFailures, 1)
+}
+
+// markAsPassed logs the passed assertion in the smoke mode.
+func (response *Response) markAsPassed(format string, v ...interface{}) {
+	if smoke {
+		LogSuccessf(format, v...)
+	}
+}
+
+// redactExtracted redacts the extracted value when its key is one of SmokeRedactedParameters (and shortens it).
+func redactExtracted(key, value string) string {
+	for _, name := range SmokeRedactedParameters {
+		if strings.EqualFold(key, name) {
+			return redacted
+		}
+	}
+	if len(value) > 100 {
+		return fmt.Sprintf("%q... (%d bytes)", value[:100], len(value))
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+func dumpSmokeRequest(r *http.Request) {
+	dump, err := httputil.DumpRequest(r, true)
+	if err != nil {
+		LogWarning("Unable to dump request:", err)
+		return
+	}
+	printLock.Lock()
+	defer printLock.Unlock()
+	fmt.Println(">>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>")
+	fmt.Println(redactDump(dump))
+}
+
+func dumpSmokeResponse(r *http.Response, body []byte) {
+	dump, err := httputil.DumpResponse(r, false)
+	if err != nil {
+		LogWarning("Unable to dump response:", err)
+		return
+	}
+	printLock.Lock()
+	defer printLock.Unlock()
+	fmt.Println("<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<")
+	fmt.Println(redactDump(append(dump, body...)))
+}
+
+// redactDump redacts the values of SmokeRedactedHeaders and SmokeRedactedParameters in the dump of a request or
+// response and shortens its body to SmokeMaxBodyDump bytes (binary bodies are omitted).
+func redactDump(dump []byte) string {
+	head, body := dump, []byte(nil)
+	if i := bytes.Index(dump, []byte("\r\n\r\n")); i >= 0 {
+		head, body = dump[:i], dump[i+4:]
+	}
+	lines := strings.Split(string(head), "\r\n")
+	for i, line := range lines[1:] {
+		if colon := strings.Index(line, ":"); colon > 0 {
+			for _, header := range SmokeRedactedHeaders {
+				if strings.EqualFold(line[:colon], header) {
+					lines[i+1] = line[:colon] + ": " + redacted
+				}
+			}
+		}
+	}
+	s := strings.Join(lines, "\n")
+	switch {
+	case len(body) == 0:
+	case !utf8.Valid(body):
+		s += fmt.Sprintf("\n\n[%d bytes binary body]", len(body))
+	case len(body) > SmokeMaxBodyDump:
+		s += "\n\n" + string(body[:SmokeMaxBodyDump]) + fmt.Sprintf("\n[... %d more bytes]", len(body)-SmokeMaxBodyDump)
+	default:
+		s += "\n\n" + string(body)
+	}
+	return redactedParametersPattern().ReplaceAllString(s, "${1}${2}"+redacted)
+}
+
+func redactedParametersPattern() *regexp.Regexp {
+	names := make([]string, 0, len(SmokeRedactedParameters))
+	for _, name := range SmokeRedactedParameters {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	joined := strings.Join(names, "|")
+	redactedParameters.Lock()
+	defer redactedParameters.Unlock()
+	if redactedParameters.pattern == nil || redactedParameters.names != joined {
+		// like "password": "secret words" (JSON, up to the closing unescaped quote) or password=secret (query or form)
+		redactedParameters.pattern = regexp.MustCompile(`(?i)(\b(?:` + joined + `)"?\s*[:=]\s*")(?:[^"\\]|\\.)*|(\b(?:` + joined + `)"?\s*[:=]\s*)[^"&\s,;}]*`)
+		redactedParameters.names = joined
+	}
+	return redactedParameters.pattern
+}
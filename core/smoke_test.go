@@ -0,0 +1,87 @@
+package goverrun
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSmoke(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, `{"token": "t-1"}`)
+	}))
+	defer server.Close()
+	defer Reset()
+
+	var runs int
+	passing := func(user *User) {
+		runs++
+		user.Step("login").Request(http.MethodPost, server.URL+"/login?password=secret").SendWithTimeout(5 * time.Second).
+			AssertStatusCode(200).AssertBodyContains("token").Extract(ExtractJSONPath("token", "$.token")).ArchiveStats()
+		user.ThinkTime(time.Millisecond)
+	}
+	failing := func(user *User) {
+		runs++
+		user.Step("missing").Request(http.MethodGet, server.URL+"/missing").SendWithTimeout(5 * time.Second).
+			AssertStatusCode(200).ArchiveStats()
+	}
+	config := LoadConfig{LoopingUsers: 50, RampUp: time.Hour, Plateau: time.Hour}
+	for title, runner := range map[string]func(user *User){"passing": passing, "failing": failing, "ignored": failing} {
+		if err := AddScenario(&Scenario{Title: title, Runner: runner, LoadConfig: config, Ignored: title == "ignored"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !RunSmoke() || runs != 2 {
+		t.Errorf("expected failed smoke run of 2 scenarios, got %d runs", runs)
+	}
+	scenarios["failing"].Ignored = true
+	if RunSmoke() || runs != 3 || scenarios["passing"].ExecutionCount != 2 {
+		t.Errorf("expected passed smoke run of 1 scenario, got %d runs", runs)
+	}
+	if smoke || len(stepHistogramWriters) > 0 {
+		t.Error("expected smoke mode to end without tracked stats")
+	}
+
+	unarchived := func(user *User) {
+		runs++
+		user.Step("missing").Request(http.MethodGet, server.URL+"/missing").SendWithTimeout(5 * time.Second).AssertStatusCode(200)
+	}
+	if err := AddScenario(&Scenario{Title: "unarchived", Runner: unarchived, LoadConfig: config}); err != nil {
+		t.Fatal(err)
+	}
+	scenarios["passing"].Ignored = true
+	if !RunSmoke() || runs != 4 {
+		t.Errorf("expected failed smoke run of a scenario not archiving its failed step, got %d runs", runs)
+	}
+}
+
+func TestRedactDump(t *testing.T) {
+	dump := "POST /login?user=alice&password=secret HTTP/1.1\r\nHost: example.com\r\nAuthorization: Bearer abc\r\ncookie: session=1\r\n" +
+		"X-Token: xyz\r\n\r\n{\"user\": \"alice\", \"Password\": \"s3cret\", \"api_key\":42}"
+	expected := "POST /login?user=alice&password=xxxxx HTTP/1.1\nHost: example.com\nAuthorization: xxxxx\ncookie: xxxxx\n" +
+		"X-Token: xxxxx\n\n{\"user\": \"alice\", \"Password\": \"xxxxx\", \"api_key\":xxxxx}"
+	if redactedDump := redactDump([]byte(dump)); redactedDump != expected {
+		t.Errorf("unexpected redacted dump:\n%s\nwant:\n%s", redactedDump, expected)
+	}
+	dump = "HTTP/1.1 200 OK\r\n\r\n{\"password\": \"correct horse battery\", \"secret\":\"say \\\"hello\\\" twice\", \"pin\": \"1 2 3\"}"
+	expected = "HTTP/1.1 200 OK\n\n{\"password\": \"xxxxx\", \"secret\":\"xxxxx\", \"pin\": \"xxxxx\"}"
+	SmokeRedactedParameters = append(SmokeRedactedParameters, "pin")
+	defer func() { SmokeRedactedParameters = SmokeRedactedParameters[:len(SmokeRedactedParameters)-1] }()
+	if redactedDump := redactDump([]byte(dump)); redactedDump != expected {
+		t.Errorf("unexpected redacted multi-word secrets:\n%s\nwant:\n%s", redactedDump, expected)
+	}
+	if redactedDump := redactDump([]byte("HTTP/1.1 200 OK\r\n\r\n" + strings.Repeat("a", SmokeMaxBodyDump+10))); !strings.HasSuffix(redactedDump, "a\n[... 10 more bytes]") {
+		t.Errorf("unexpected shortened dump: %s", redactedDump[len(redactedDump)-30:])
+	}
+	if redactedDump := redactDump([]byte("HTTP/1.1 200 OK\r\n\r\n\xff\xfe")); redactedDump != "HTTP/1.1 200 OK\n\n[2 bytes binary body]" {
+		t.Errorf("unexpected binary dump: %s", redactedDump)
+	}
+}
@@ -0,0 +1,274 @@
+package goverrun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Socket is an open TCP or UDP connection of a user for load-testing non-HTTP services. Like with WebSockets,
+// connecting and every exchange of bytes are steps recorded like requests: the connect time is the total duration
+// of the connecting step and the round-trip time of an exchange is its Time-to-First-Byte.
+//
+// A Socket stays open across the loops of the user until it is closed (see User.OpenSocket) and is closed latest
+// when the user stops looping.
+type Socket struct {
+	Network      string    // tcp or udp
+	Address      string    // host:port
+	Response     *Response // of connecting
+	MaxFrameSize uint64    // largest accepted length of length-prefixed messages (0: DefaultMaxFrameSize)
+	conn         net.Conn  // nil when the connection failed or is closed
+	reader       *bufio.Reader
+}
+
+// SocketStep is a step sending and receiving bytes over a Socket.
+type SocketStep struct {
+	step   *Step
+	socket *Socket
+}
+
+// DefaultMaxFrameSize is the largest accepted length of length-prefixed messages (see Socket.MaxFrameSize), so a corrupt
+// or hostile length prefix fails the exchange instead of allocating gigabytes.
+const DefaultMaxFrameSize = 16 << 20
+
+// maxDatagramSize is the size of the read buffer, which is large enough for every UDP datagram.
+const maxDatagramSize = 65535
+
+// ConnectSocket opens a TCP or UDP connection ("tcp" or "udp" network) to the address (host:port). It uses the dialer
+// of the user's transport (local addresses, resolver and open connection tracking) when available. Use socket.Response
+// to assert on the connection and to archive it. For UDP nothing is sent when connecting, so only the address is resolved.
+func (step *Step) ConnectSocket(network, address string, timeout time.Duration) *Socket {
+	user := step.User
+	socket := &Socket{Network: network, Address: address}
+	if user.Disabled {
+		socket.Response = &Response{Timestamps: &Timestamps{}, archived: true}
+		return socket
+	}
+	rsp := &Response{
+		Scenario:   user.Scenario,
+		Step:       step,
+		RequestURL: network + "://" + address,
+		FinalURL:   network + "://" + address,
+		Protocol:   network,
+		Timestamps: &Timestamps{},
+	}
+	user.trackSmoke(rsp)
+	socket.Response = rsp
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if verbose {
+		user.printStep(step)
+	}
+	rsp.Timestamps.Start = time.Now()
+	conn, err := user.socketDialContext()(ctx, network, address)
+	rsp.Timestamps.Done = time.Now()
+	if err != nil {
+		rsp.trackError(err)
+		return socket
+	}
+	rsp.Timestamps.WroteRequest = rsp.Timestamps.Done
+	rsp.Timestamps.GotFirstResponseByte = rsp.Timestamps.Done
+	if host, _, err := net.SplitHostPort(conn.LocalAddr().String()); err == nil {
+		rsp.LocalAddress = host
+	}
+	socket.conn = conn
+	socket.reader = bufio.NewReaderSize(conn, maxDatagramSize)
+	rsp.OpenConnections = openConnections.Value(user.Scenario)
+	if user.sockets == nil {
+		user.sockets = make(map[string]*Socket)
+	}
+	if previous := user.sockets[rsp.RequestURL]; previous != nil {
+		previous.Close()
+	}
+	user.sockets[rsp.RequestURL] = socket
+	return socket
+}
+
+// OpenSocket returns the still open socket of the user to the address (or nil when there is none).
+func (user *User) OpenSocket(network, address string) *Socket {
+	if socket := user.sockets[network+"://"+address]; socket != nil && socket.IsOpen() {
+		return socket
+	}
+	return nil
+}
+
+// closeSockets closes all sockets of the user.
+func (user *User) closeSockets() {
+	for _, socket := range user.sockets {
+		socket.Close()
+	}
+}
+
+// socketDialContext returns the dial function of the user's transport (if possible).
+func (user *User) socketDialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var transport *http.Transport
+	switch rt := user.HttpClient.Transport.(type) {
+	case *http.Transport:
+		transport = rt
+	case *RoundTripperWrapper:
+		transport, _ = rt.realRoundTripper.(*http.Transport)
+	}
+	if transport != nil && transport.DialContext != nil {
+		return transport.DialContext
+	}
+	return (&net.Dialer{}).DialContext
+}
+
+// IsOpen returns if the connection is (still) open.
+func (socket *Socket) IsOpen() bool {
+	return socket.conn != nil
+}
+
+// Close closes the connection.
+func (socket *Socket) Close() {
+	if socket.conn == nil {
+		return
+	}
+	_ = socket.conn.Close()
+	socket.conn = nil
+}
+
+// OnSocket uses the step for sending and receiving bytes over the socket.
+func (step *Step) OnSocket(socket *Socket) *SocketStep {
+	return &SocketStep{step: step, socket: socket}
+}
+
+// Send sends the data (without waiting for any response).
+func (ss *SocketStep) Send(data []byte) *Response {
+	return ss.exchange(data, 0, nil)
+}
+
+// SendAndReadUntil sends the data (if any) and reads until the delimiter (like "\r\n" for line-based protocols),
+// which is part of the response body.
+func (ss *SocketStep) SendAndReadUntil(data, delimiter []byte, timeout time.Duration) *Response {
+	return ss.exchange(data, timeout, func(reader *bufio.Reader) ([]byte, error) {
+		if len(delimiter) == 0 {
+			return nil, fmt.Errorf("empty delimiter")
+		}
+		var received []byte
+		last := delimiter[len(delimiter)-1]
+		for {
+			chunk, err := reader.ReadBytes(last)
+			received = append(received, chunk...)
+			if err != nil || bytes.HasSuffix(received, delimiter) {
+				return received, err
+			}
+		}
+	})
+}
+
+// SendAndReadLengthPrefixed sends the data (if any) and reads a message prefixed by its length as big-endian
+// unsigned integer of the given size (1, 2, 4 or 8 bytes). The response body is the message without the prefix.
+// Lengths above the Socket.MaxFrameSize are an error (without reading the message).
+func (ss *SocketStep) SendAndReadLengthPrefixed(data []byte, prefixSize int, timeout time.Duration) *Response {
+	return ss.exchange(data, timeout, func(reader *bufio.Reader) ([]byte, error) {
+		if prefixSize != 1 && prefixSize != 2 && prefixSize != 4 && prefixSize != 8 {
+			return nil, fmt.Errorf("invalid length prefix size %d (expected 1, 2, 4 or 8 bytes)", prefixSize)
+		}
+		prefix := make([]byte, 8)
+		if _, err := io.ReadFull(reader, prefix[8-prefixSize:]); err != nil {
+			return nil, err
+		}
+		length, maxFrameSize := binary.BigEndian.Uint64(prefix), ss.socket.MaxFrameSize
+		if maxFrameSize == 0 {
+			maxFrameSize = DefaultMaxFrameSize
+		}
+		if length > maxFrameSize {
+			return nil, fmt.Errorf("length prefix %d exceeds the max frame size %d", length, maxFrameSize)
+		}
+		received := make([]byte, length)
+		n, err := io.ReadFull(reader, received)
+		return received[:n], err
+	})
+}
+
+// SendAndReadUntilTimeout sends the data (if any) and reads everything received until the timeout expires or
+// the connection is closed by the server (both are no error). For UDP a single datagram is read.
+func (ss *SocketStep) SendAndReadUntilTimeout(data []byte, timeout time.Duration) *Response {
+	udp := ss.socket.Network == "udp" || ss.socket.Network == "udp4" || ss.socket.Network == "udp6"
+	return ss.exchange(data, timeout, func(reader *bufio.Reader) ([]byte, error) {
+		var received []byte
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, err := reader.Read(buf)
+			received = append(received, buf[:n]...)
+			if netErr, ok := err.(net.Error); (ok && netErr.Timeout()) || err == io.EOF {
+				return received, nil
+			}
+			if err != nil || (udp && n > 0) {
+				return received, err
+			}
+		}
+	})
+}
+
+// exchange sends the data (if any) and reads the response (if requested). A read error (including timeouts)
+// closes the connection, as the remaining bytes of the response would be read by the next exchange otherwise.
+func (ss *SocketStep) exchange(data []byte, timeout time.Duration, read func(reader *bufio.Reader) ([]byte, error)) *Response {
+	user, socket := ss.step.User, ss.socket
+	if user.Disabled {
+		return &Response{Timestamps: &Timestamps{}, archived: true}
+	}
+	rsp := &Response{
+		Scenario:   user.Scenario,
+		Step:       ss.step,
+		RequestURL: socket.Response.RequestURL,
+		FinalURL:   socket.Response.RequestURL,
+		Protocol:   socket.Network,
+		Timestamps: &Timestamps{},
+	}
+	user.trackSmoke(rsp)
+	if verbose {
+		user.printStep(ss.step)
+	}
+	rsp.Timestamps.Start = time.Now()
+	if socket.conn == nil {
+		rsp.Error = fmt.Errorf("socket connection to %s is not open", rsp.RequestURL)
+		rsp.Timestamps.Done = time.Now()
+		return rsp
+	}
+	rsp.LocalAddress = socket.Response.LocalAddress
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = rsp.Timestamps.Start.Add(timeout)
+	}
+	_ = socket.conn.SetDeadline(deadline)
+	if len(data) > 0 {
+		_, err := socket.conn.Write(data)
+		rsp.Timestamps.WroteRequest = time.Now()
+		if err != nil {
+			rsp.trackError(err)
+			rsp.Timestamps.Done = time.Now()
+			socket.Close()
+			return rsp
+		}
+		rsp.RequestSize = len(data)
+	} else {
+		rsp.Timestamps.WroteRequest = rsp.Timestamps.Start
+	}
+	if read != nil {
+		if _, err := socket.reader.Peek(1); err == nil {
+			rsp.Timestamps.GotFirstResponseByte = time.Now()
+		}
+		received, err := read(socket.reader)
+		rsp.Body = received
+		rsp.ResponseSize = len(received)
+		if err != nil {
+			rsp.trackError(err)
+			socket.Close()
+		}
+	}
+	rsp.Timestamps.Done = time.Now()
+	rsp.OpenConnections = openConnections.Value(user.Scenario)
+	return rsp
+}
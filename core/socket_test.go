@@ -0,0 +1,131 @@
+package goverrun
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTCPSocket(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) { // line-based: PING answers PONG, LEN answers length-prefixed, QUIT closes
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch strings.TrimSpace(line) {
+					case "PING":
+						_, _ = conn.Write([]byte("PO"))
+						time.Sleep(10 * time.Millisecond)
+						_, _ = conn.Write([]byte("NG\r\n"))
+					case "LEN":
+						message := []byte("length-prefixed")
+						prefix := make([]byte, 2)
+						binary.BigEndian.PutUint16(prefix, uint16(len(message)))
+						_, _ = conn.Write(append(prefix, message...))
+					case "QUIT":
+						_, _ = conn.Write([]byte("BYE"))
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{}}
+
+	socket := user.Step("connect").ConnectSocket("tcp", listener.Addr().String(), time.Second)
+	if socket.Response.ConsideredUnsuccessful() || !socket.IsOpen() || user.OpenSocket("tcp", listener.Addr().String()) != socket {
+		t.Fatalf("unexpected connect: %v", socket.Response.Error)
+	}
+	if total, completed := socket.Response.Timestamps.TotalDuration(); !completed || total <= 0 || socket.Response.LocalAddress != "127.0.0.1" {
+		t.Errorf("expected connect time and source IP to be tracked: %v %q", total, socket.Response.LocalAddress)
+	}
+
+	response := user.Step("ping").OnSocket(socket).SendAndReadUntil([]byte("PING\n"), []byte("\r\n"), time.Second).
+		AssertBodyContains("PONG")
+	if response.ConsideredUnsuccessful() || string(response.Body) != "PONG\r\n" || response.Protocol != "tcp" || response.RequestSize != 5 {
+		t.Fatalf("unexpected response: %v %s %q", response.Error, response.AssertionFailed, response.Body)
+	}
+	if ttfb, completed := response.Timestamps.TimeToFirstByte(false); !completed || ttfb <= 0 || response.stepEntry().Protocol != "tcp" {
+		t.Errorf("expected round-trip time to be tracked: %v %+v", ttfb, response.Timestamps)
+	}
+
+	response = user.Step("length").OnSocket(socket).SendAndReadLengthPrefixed([]byte("LEN\n"), 2, time.Second)
+	if response.ConsideredUnsuccessful() || string(response.Body) != "length-prefixed" {
+		t.Errorf("unexpected length-prefixed response: %v %q", response.Error, response.Body)
+	}
+	socket.MaxFrameSize = 10
+	response = user.Step("too long").OnSocket(socket).SendAndReadLengthPrefixed([]byte("LEN\n"), 2, time.Second)
+	if response.Error == nil || response.Error.Error() != "length prefix 15 exceeds the max frame size 10" || socket.IsOpen() {
+		t.Errorf("expected max frame size error closing the socket: %v %v", response.Error, socket.IsOpen())
+	}
+	socket = user.Step("reconnect").ConnectSocket("tcp", listener.Addr().String(), time.Second)
+
+	response = user.Step("timeout").OnSocket(socket).SendAndReadUntil([]byte("NOOP\n"), []byte("\r\n"), 50*time.Millisecond)
+	if response.Timeout == nil || socket.IsOpen() {
+		t.Errorf("expected timeout closing the socket: %v %v", response.Error, socket.IsOpen())
+	}
+	response = user.Step("closed").OnSocket(socket).Send([]byte("PING\n"))
+	if response.Error == nil {
+		t.Error("expected error on closed socket")
+	}
+
+	socket = user.Step("reconnect").ConnectSocket("tcp", listener.Addr().String(), time.Second)
+	response = user.Step("quit").OnSocket(socket).SendAndReadUntilTimeout([]byte("QUIT\n"), time.Second)
+	if response.ConsideredUnsuccessful() || string(response.Body) != "BYE" {
+		t.Errorf("expected reading until closed by server: %v %q", response.Error, response.Body)
+	}
+	user.closeSockets()
+	if socket.IsOpen() {
+		t.Error("expected socket to be closed")
+	}
+}
+
+func TestUDPSocket(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = server.WriteTo([]byte("ack "+string(buf[:n])), addr)
+		}
+	}()
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{}}
+	socket := user.Step("connect").ConnectSocket("udp", server.LocalAddr().String(), time.Second)
+	defer socket.Close()
+
+	response := user.Step("telemetry").OnSocket(socket).SendAndReadUntilTimeout([]byte("cpu=42"), time.Second)
+	if response.ConsideredUnsuccessful() || string(response.Body) != "ack cpu=42" || response.Protocol != "udp" {
+		t.Errorf("unexpected datagram: %v %q", response.Error, response.Body)
+	}
+	if total, _ := response.Timestamps.TotalDuration(); total >= time.Second {
+		t.Errorf("expected a single datagram to be read without waiting for the timeout: %v", total)
+	}
+	if response := user.Step("fire-and-forget").OnSocket(socket).Send([]byte("mem=7")); response.ConsideredUnsuccessful() || response.RequestSize != 5 {
+		t.Errorf("unexpected send: %v", response.Error)
+	}
+}
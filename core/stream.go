@@ -0,0 +1,165 @@
+package goverrun
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Streaming configures a request whose response body is processed incrementally as a stream of events
+// (see Request.Stream). Server-Sent Events (text/event-stream) are parsed into their fields, any other
+// streamed content (like chunked or newline-delimited JSON responses) is split into one event per line.
+type Streaming struct {
+	Stop      func(event StreamEvent) bool // optional stop condition checked for every event (the stream is closed when true)
+	MaxEvents int                          // stop after this number of events (zero means unlimited)
+}
+
+// StreamEvent is a single event of a streamed response.
+type StreamEvent struct {
+	Received time.Time
+	Event    string // SSE event type (empty for the default "message" type and for line events)
+	ID       string // SSE event id
+	Data     string // SSE data (multiple data lines joined by newline) or the line
+}
+
+// Stream enables the streaming mode for the response, optionally stopping when the stop condition is met.
+// The time to the first event, the gaps between the events, the event count and the stream duration are tracked.
+func (req *Request) Stream(stop func(event StreamEvent) bool) *Request {
+	req.Streaming = &Streaming{Stop: stop}
+	return req
+}
+
+// StreamMaxEvents enables the streaming mode for the response stopping after the given number of events.
+func (req *Request) StreamMaxEvents(maxEvents int) *Request {
+	req.Streaming = &Streaming{MaxEvents: maxEvents}
+	return req
+}
+
+// readStream reads the body incrementally into events until the end of the stream or the stop condition.
+// The returned body contains the content read so far.
+func (response *Response) readStream(body io.Reader, contentType string, streaming *Streaming) ([]byte, error) {
+	var content bytes.Buffer
+	reader := bufio.NewReader(io.TeeReader(body, &content))
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	sse := mediaType == "text/event-stream"
+	var event StreamEvent
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 || err == nil {
+			line = strings.TrimRight(line, "\r\n")
+			complete := false
+			if !sse {
+				if len(line) > 0 {
+					event, complete = StreamEvent{Data: line}, true
+				}
+			} else if len(line) == 0 { // blank line dispatches the event
+				event.Data = strings.Join(data, "\n")
+				complete = len(data) > 0
+				data = nil
+			} else if !strings.HasPrefix(line, ":") { // lines starting with a colon are comments
+				field, value := line, ""
+				if i := strings.Index(line, ":"); i >= 0 {
+					field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+				}
+				switch field {
+				case "event":
+					event.Event = value
+				case "id":
+					event.ID = value
+				case "data":
+					data = append(data, value)
+				}
+			}
+			if complete {
+				event.Received = time.Now()
+				response.Events = append(response.Events, event)
+				if (streaming.Stop != nil && streaming.Stop(event)) || (streaming.MaxEvents > 0 && len(response.Events) >= streaming.MaxEvents) {
+					return content.Bytes(), nil
+				}
+				event = StreamEvent{ID: event.ID} // the last event id applies to the following events
+			}
+		}
+		if err == io.EOF {
+			return content.Bytes(), nil
+		}
+		if err != nil {
+			return content.Bytes(), err
+		}
+	}
+}
+
+// TimeToFirstEvent returns the duration from the start of the request to the first streamed event.
+func (response *Response) TimeToFirstEvent() (d time.Duration, completed bool) {
+	if len(response.Events) == 0 {
+		return 0, false
+	}
+	return response.Events[0].Received.Sub(response.Timestamps.Start), true
+}
+
+// InterEventGaps returns the durations between the streamed events.
+func (response *Response) InterEventGaps() (gaps []time.Duration) {
+	for i := 1; i < len(response.Events); i++ {
+		gaps = append(gaps, response.Events[i].Received.Sub(response.Events[i-1].Received))
+	}
+	return gaps
+}
+
+// AssertEventCountAtLeast asserts that the stream contained at least the given number of events.
+func (response *Response) AssertEventCountAtLeast(count int) *Response {
+	if response.ConsideredUnsuccessful() {
+		return response // earlier checked assertion already failed or error or timeout happened
+	}
+	if len(response.Events) < count {
+		response.MarkAsFailed(fmt.Sprint("assertion of event count failed (expected at least ", count, " events): ", len(response.Events)))
+	} else {
+		response.markAsPassed("assertion of event count passed (expected at least %d events): %d", count, len(response.Events))
+	}
+	return response
+}
+
+// AssertEventContains asserts that the data of at least one streamed event contains the given value.
+func (response *Response) AssertEventContains(s string) *Response {
+	if response.ConsideredUnsuccessful() {
+		return response // earlier checked assertion already failed or error or timeout happened
+	}
+	for i, event := range response.Events {
+		if strings.Contains(event.Data, s) {
+			response.markAsPassed("assertion of event content passed (event %d contained value): %s", i+1, s)
+			return response
+		}
+	}
+	response.MarkAsFailed(fmt.Sprint("assertion of event content failed (no event contained expected value): ", s))
+	return response
+}
+
+// AssertEachEvent asserts the function to hold for every streamed event.
+func (response *Response) AssertEachEvent(fn func(event StreamEvent) (message string, ok bool)) *Response {
+	if response.ConsideredUnsuccessful() {
+		return response // earlier checked assertion already failed or error or timeout happened
+	}
+	for i, event := range response.Events {
+		if message, ok := fn(event); !ok {
+			response.MarkAsFailed(fmt.Sprint("assertion of function on event ", i+1, " failed ", message))
+			return response
+		}
+	}
+	response.markAsPassed("assertion of function on %d events passed", len(response.Events))
+	return response
+}
+
+// streamValues are the stream measurements of a step file.
+type streamValues struct {
+	timeToFirstEvent, interEventGaps []float64 // in nanoseconds
+	events                           uint64
+}
+
+func (values *streamValues) add(other streamValues) {
+	values.timeToFirstEvent = append(values.timeToFirstEvent, other.timeToFirstEvent...)
+	values.interEventGaps = append(values.interEventGaps, other.interEventGaps...)
+	values.events += other.events
+}
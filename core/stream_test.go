@@ -0,0 +1,69 @@
+package goverrun
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		switch r.URL.Path {
+		case "/sse":
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			_, _ = fmt.Fprint(w, ": comment\n\n")
+			for i := 1; i <= 3; i++ {
+				time.Sleep(20 * time.Millisecond)
+				_, _ = fmt.Fprintf(w, "event: token\nid: %d\ndata: part %d\ndata: continued\n\n", i, i)
+				flusher.Flush()
+			}
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		case "/endless":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for i := 1; r.Context().Err() == nil; i++ {
+				_, _ = fmt.Fprintf(w, "{\"count\":%d}\n", i)
+				flusher.Flush()
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+	}))
+	defer server.Close()
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{}}
+
+	response := user.Step("sse").Request(http.MethodGet, server.URL+"/sse").
+		Stream(func(event StreamEvent) bool { return event.Data == "[DONE]" }).
+		SendWithTimeout(5 * time.Second).
+		AssertEventCountAtLeast(4).
+		AssertEventContains("part 2").
+		AssertEachEvent(func(event StreamEvent) (string, bool) {
+			return event.Data, event.Data == "[DONE]" || strings.HasSuffix(event.Data, "\ncontinued")
+		})
+	if response.ConsideredUnsuccessful() || len(response.Events) != 4 {
+		t.Fatalf("unexpected stream: %v %s %v", response.Error, response.AssertionFailed, response.Events)
+	}
+	if event := response.Events[1]; event.Event != "token" || event.ID != "2" || event.Data != "part 2\ncontinued" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if response.Events[3].ID != "3" || response.Events[3].Event != "" {
+		t.Errorf("expected the last event id to be kept: %+v", response.Events[3])
+	}
+	entry := response.stepEntry()
+	if ttfe, _ := response.TimeToFirstEvent(); entry.StreamEvents != 4 || len(entry.InterEventGaps) != 3 || entry.TimeToFirstEvent != ttfe || ttfe < 20*time.Millisecond {
+		t.Errorf("unexpected stream stats: %+v", entry)
+	}
+	if total, _ := response.Timestamps.TotalDuration(); total < 60*time.Millisecond {
+		t.Errorf("expected total stream duration to cover all events: %v", total)
+	}
+
+	response = user.Step("endless").Request(http.MethodGet, server.URL+"/endless").StreamMaxEvents(5).SendWithTimeout(5 * time.Second)
+	if response.Error != nil || response.Timeout != nil || len(response.Events) != 5 || response.Events[4].Data != `{"count":5}` {
+		t.Errorf("expected endless stream to stop after 5 events: %v %v", response.Error, response.Events)
+	}
+	if response := response.AssertEventContains("count\":99"); !response.IsFailed() {
+		t.Error("expected event assertion to fail")
+	}
+}
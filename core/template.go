@@ -0,0 +1,212 @@
+package goverrun
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rePlaceholder matches placeholders like ${data.token} as well as escaped ones like $${data.token}
+var rePlaceholder = regexp.MustCompile(`\$?\$\{([^{}]*)\}`)
+
+// reEscapedPlaceholder matches placeholders within the escaped URLs of raw requests (like $%7Bdata.id%7D).
+var reEscapedPlaceholder = regexp.MustCompile(`\$(?:\{|%7[Bb])([^{}]*?)(?:\}|%7[Dd])`)
+
+// Templated enables the resolving of placeholders in the request URL, headers, cookies, form params and body
+// (also for raw requests) right before the request is sent. Supported placeholders are:
+//
+//	${user.Scenario}, ${user.CurrentUser}, ${user.CurrentLoop}
+//	${data.<key>}               value of User.Data[<key>]
+//	${feeder.<column>}          column of the rows fed to the user in the current loop (see User.Feed)
+//	${random.uuid}              random UUID (version 4)
+//	${random.int(<min>,<max>)}  random number between min and max (both inclusive)
+//	${now.unix}, ${now.unixmilli}, ${now.rfc3339}
+//
+// Unresolvable placeholders are left untouched. Use $${...} to send a literal ${...}.
+func (req *Request) Templated() *Request {
+	req.Templating = true
+	return req
+}
+
+// Expand resolves all placeholders in the given string (see Request.Templated for the supported placeholders).
+func (user *User) Expand(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return rePlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		if strings.HasPrefix(placeholder, "$$") {
+			return placeholder[1:] // escaped
+		}
+		expression := strings.TrimSpace(placeholder[2 : len(placeholder)-1])
+		if value, ok := user.resolvePlaceholder(expression); ok {
+			return value
+		}
+		if verbose {
+			LogWarningf("[%d:%d] Unable to resolve placeholder: %s\n", user.CurrentUser, user.CurrentLoop, placeholder)
+		}
+		return placeholder
+	})
+}
+
+func (user *User) resolvePlaceholder(expression string) (string, bool) {
+	dot := strings.Index(expression, ".")
+	if dot < 0 {
+		return "", false
+	}
+	namespace, name := expression[:dot], expression[dot+1:]
+	switch namespace {
+	case "user":
+		switch name {
+		case "Scenario":
+			return user.Scenario, true
+		case "CurrentUser":
+			return strconv.Itoa(user.CurrentUser), true
+		case "CurrentLoop":
+			return strconv.Itoa(user.CurrentLoop), true
+		}
+	case "data":
+		if value, exists := user.Data[name]; exists {
+			return fmt.Sprint(value), true
+		}
+	case "feeder":
+		return user.fedColumn(name)
+	case "random":
+		if name == "uuid" {
+			return randomUUID(), true
+		}
+		if strings.HasPrefix(name, "int(") && strings.HasSuffix(name, ")") {
+			bounds := strings.Split(name[len("int("):len(name)-1], ",")
+			if len(bounds) == 2 {
+				min, errMin := strconv.Atoi(strings.TrimSpace(bounds[0]))
+				max, errMax := strconv.Atoi(strings.TrimSpace(bounds[1]))
+				if errMin == nil && errMax == nil && min <= max {
+					return strconv.Itoa(RandomNumber(min, max)), true
+				}
+			}
+		}
+	case "now":
+		now := time.Now()
+		switch name {
+		case "unix":
+			return strconv.FormatInt(now.Unix(), 10), true
+		case "unixmilli":
+			return strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10), true
+		case "rfc3339":
+			return now.Format(time.RFC3339), true
+		}
+	}
+	return "", false
+}
+
+// fedColumn looks up the column in the rows fed in the current loop (the latest fed row wins).
+func (user *User) fedColumn(column string) (string, bool) {
+	var current []fedRow
+	for _, fed := range user.fed {
+		if fed.loop == user.CurrentLoop && fed.ok {
+			current = append(current, fed)
+		}
+	}
+	sort.Slice(current, func(i, j int) bool { return current[i].seq > current[j].seq })
+	for _, fed := range current {
+		if value, exists := fed.row[column]; exists {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func randomUUID() string {
+	var uuid [16]byte
+	_, err := rand.Read(uuid[:])
+	CheckErrAndLogError(err, "unable to generate random UUID")
+	uuid[6] = uuid[6]&0x0f | 0x40 // version 4
+	uuid[8] = uuid[8]&0x3f | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
+
+func expandMap(user *User, m map[string]string) {
+	for k, v := range m {
+		m[k] = user.Expand(v)
+	}
+}
+
+func expandValues(user *User, values url.Values) {
+	for _, vs := range values {
+		for i, v := range vs {
+			vs[i] = user.Expand(v)
+		}
+	}
+}
+
+func expandBody(user *User, body io.Reader) (expanded []byte, err error) {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(raw, []byte("${")) {
+		return raw, nil
+	}
+	return []byte(user.Expand(string(raw))), nil
+}
+
+// expandRequest resolves the placeholders of a request before it is built (or in case of a raw request: before it is sent).
+func expandRequest(req *Request) {
+	user := req.User
+	expandMap(user, req.Headers)
+	expandMap(user, req.Cookies)
+	if !req.Raw {
+		req.URL = user.Expand(req.URL)
+		expandMap(user, req.FormParams)
+		expandValues(user, req.repeatedFormParams)
+		if req.Body != nil {
+			expanded, err := expandBody(user, *req.Body)
+			CheckErrAndLogError(err, "unable to read request body for templating")
+			var body io.Reader = bytes.NewReader(expanded)
+			req.Body = &body
+		}
+		return
+	}
+	r := req.Request
+	if r == nil {
+		return
+	}
+	if u := unescapePlaceholders(r.URL.String()); strings.Contains(u, "${") {
+		parsed, err := url.Parse(user.Expand(u))
+		CheckErrAndLogError(err, "unable to parse templated raw request url")
+		if err == nil {
+			r.URL = parsed
+		}
+	}
+	for _, values := range r.Header {
+		for i, v := range values {
+			values[i] = user.Expand(v)
+		}
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		expanded, err := expandBody(user, r.Body)
+		CheckErrAndLogError(err, "unable to read raw request body for templating")
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(expanded))
+		r.ContentLength = int64(len(expanded))
+		r.Header.Del("Content-Length") // transport writes the updated content length instead
+	}
+}
+
+// unescapePlaceholders unescapes the placeholders within the URL, keeping other escaped braces (like %7B) escaped.
+func unescapePlaceholders(u string) string {
+	return reEscapedPlaceholder.ReplaceAllStringFunc(u, func(placeholder string) string {
+		expression := reEscapedPlaceholder.FindStringSubmatch(placeholder)[1]
+		if unescaped, err := url.PathUnescape(expression); err == nil {
+			expression = unescaped
+		}
+		return "${" + expression + "}"
+	})
+}
@@ -0,0 +1,65 @@
+package goverrun
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	feeder := NewFeeder([]map[string]string{{"username": "alice"}}, FeedCircular)
+	user := &User{Scenario: "Template Test", CurrentUser: 3, CurrentLoop: 7, Data: map[string]interface{}{"token": "abc", "count": 42}}
+	user.Feed(feeder)
+	tests := map[string]string{
+		"${user.Scenario}/${user.CurrentUser}/${user.CurrentLoop}": "Template Test/3/7",
+		"token=${data.token}&count=${ data.count }":                "token=abc&count=42",
+		"${feeder.username}":             "alice",
+		"${data.missing} and ${unknown}": "${data.missing} and ${unknown}",
+		"$${data.token} stays literal":   "${data.token} stays literal",
+		"${random.int(5,5)}":             "5",
+	}
+	for input, want := range tests {
+		if got := user.Expand(input); got != want {
+			t.Errorf("Expand(%q): got %q want %q", input, got, want)
+		}
+	}
+	reUUID := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if uuid := user.Expand("${random.uuid}"); !reUUID.MatchString(uuid) {
+		t.Errorf("invalid random UUID: %s", uuid)
+	}
+	if now := user.Expand("${now.unix}"); !regexp.MustCompile(`^\d{10}$`).MatchString(now) {
+		t.Errorf("invalid unix timestamp: %s", now)
+	}
+}
+
+func TestTemplatedRequests(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		cookie, _ := r.Cookie("session")
+		got = append(got, r.URL.Path+"|"+r.URL.RawQuery+"|"+r.Header.Get("X-Token")+"|"+cookie.Value+"|"+string(body))
+	}))
+	defer server.Close()
+
+	user := &User{CurrentUser: 1, CurrentLoop: 2, HttpClient: server.Client(), Data: map[string]interface{}{"token": "abc", "name": "alice"}}
+	user.Step("templated").Request(http.MethodPost, server.URL+"/users/${user.CurrentUser}?loop=${user.CurrentLoop}").Templated().
+		SetHeader("X-Token", "${data.token}").
+		SetCookie("session", "s-${data.token}").
+		SetFormParam("name", "${data.name}").
+		SendWithoutTimeout()
+	raw := "POST /ignored HTTP/1.1\r\nHost: example.com\r\nX-Token: ${data.token}\r\nCookie: session=raw-${data.token}\r\nContent-Length: 20\r\n\r\n{\"n\":\"${data.name}\"}"
+	user.Step("templated raw").RequestRaw(server.URL+"/raw/${user.CurrentUser}/${random.int(3, 3)}?q=${data.name}&literal=%7Bq%7D", bufio.NewReader(strings.NewReader(raw))).Templated().
+		SendWithoutTimeout()
+
+	want := []string{
+		"/users/1|loop=2|abc|s-abc|name=alice",
+		`/raw/1/3|q=alice&literal=%7Bq%7D|abc|raw-abc|{"n":"alice"}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,192 @@
+package goverrun
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"golang.org/x/crypto/pkcs12"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TLSConfig configures the TLS connections of the users of a scenario. All certificates are referenced by file path.
+type TLSConfig struct {
+	InsecureSkipVerify bool     // disables the certificate verification (only for test systems with invalid certificates)
+	RootCAFiles        []string // PEM files with the CA certificates to verify servers against (instead of the system roots)
+	ServerName         string   // overrides the server name used for verification and SNI
+
+	// client certificates (mutual TLS)
+	ClientCertFile string // PEM file with the client certificate (and optionally the key)
+	ClientKeyFile  string // PEM file with the key of the client certificate (when not contained in ClientCertFile)
+	ClientCertDir  string // directory with client certificates distributed round-robin to the users: <name>.p12/.pfx or <name>.crt/.pem with <name>.key
+	PKCS12Password string // password of PKCS#12 (.p12/.pfx) files
+
+	MinVersion, MaxVersion string   // like "1.2" or "1.3"
+	CipherSuites           []string // names like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (only for TLS 1.2 and below)
+}
+
+// loadedTLS is the TLS config of a scenario with all referenced files loaded.
+type loadedTLS struct {
+	base         *tls.Config
+	certificates []tls.Certificate
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// load reads all referenced files and checks the config for invalid values.
+func (config TLSConfig) load() (*loadedTLS, error) {
+	base := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		ServerName:         config.ServerName,
+	}
+	if len(config.RootCAFiles) > 0 {
+		base.RootCAs = x509.NewCertPool()
+		for _, filename := range config.RootCAFiles {
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			if !base.RootCAs.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no PEM certificates found in CA file '%s'", filename)
+			}
+		}
+	}
+	for _, version := range []struct {
+		name   string
+		target *uint16
+	}{{config.MinVersion, &base.MinVersion}, {config.MaxVersion, &base.MaxVersion}} {
+		if len(version.name) == 0 {
+			continue
+		}
+		value, exists := tlsVersions[strings.TrimPrefix(strings.ToUpper(version.name), "TLS")]
+		if !exists {
+			return nil, fmt.Errorf("unknown TLS version '%s'", version.name)
+		}
+		*version.target = value
+	}
+	if base.MinVersion > 0 && base.MaxVersion > 0 && base.MinVersion > base.MaxVersion {
+		return nil, fmt.Errorf("TLS min version %s is above max version %s", config.MinVersion, config.MaxVersion)
+	}
+	for _, name := range config.CipherSuites {
+		id, err := cipherSuiteID(name)
+		if err != nil {
+			return nil, err
+		}
+		base.CipherSuites = append(base.CipherSuites, id)
+	}
+
+	loaded := &loadedTLS{base: base}
+	if len(config.ClientCertFile) > 0 {
+		certificate, err := config.loadCertificate(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		loaded.certificates = append(loaded.certificates, certificate)
+	}
+	if len(config.ClientCertDir) > 0 {
+		certificates, err := config.loadCertificateDir()
+		if err != nil {
+			return nil, err
+		}
+		loaded.certificates = append(loaded.certificates, certificates...)
+	}
+	return loaded, nil
+}
+
+func cipherSuiteID(name string) (uint16, error) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if strings.EqualFold(suite.Name, name) {
+			return suite.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite '%s'", name)
+}
+
+func (config TLSConfig) loadCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	switch strings.ToLower(filepath.Ext(certFile)) {
+	case ".p12", ".pfx":
+		data, err := os.ReadFile(certFile)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		blocks, err := pkcs12.ToPEM(data, config.PKCS12Password)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("unable to decode PKCS#12 file '%s': %w", certFile, err)
+		}
+		var pemData []byte
+		for _, block := range blocks {
+			pemData = append(pemData, pem.EncodeToMemory(block)...)
+		}
+		return tls.X509KeyPair(pemData, pemData)
+	default:
+		if len(keyFile) == 0 {
+			keyFile = certFile // combined PEM file
+		}
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("unable to load client certificate '%s': %w", certFile, err)
+		}
+		return certificate, nil
+	}
+}
+
+// loadCertificateDir loads the client certificates of the directory in file name order.
+func (config TLSConfig) loadCertificateDir() (certificates []tls.Certificate, err error) {
+	entries, err := os.ReadDir(config.ClientCertDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(config.ClientCertDir, name)
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		var certificate tls.Certificate
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".p12", ".pfx":
+			certificate, err = config.loadCertificate(path, "")
+		case ".crt", ".pem", ".cer":
+			keyFile := filepath.Join(config.ClientCertDir, base+".key")
+			if _, statErr := os.Stat(keyFile); statErr != nil {
+				keyFile = "" // key contained in the certificate file
+			}
+			certificate, err = config.loadCertificate(path, keyFile)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no client certificates found in directory '%s'", config.ClientCertDir)
+	}
+	return certificates, nil
+}
+
+// forUser returns the TLS config for the given user (one-based) with its client certificate (if any).
+func (loaded *loadedTLS) forUser(currentUser int) *tls.Config {
+	config := loaded.base.Clone()
+	if len(loaded.certificates) > 0 {
+		index := 0
+		if currentUser > 0 {
+			index = (currentUser - 1) % len(loaded.certificates)
+		}
+		config.Certificates = []tls.Certificate{loaded.certificates[index]}
+	}
+	return config
+}
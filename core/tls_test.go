@@ -0,0 +1,168 @@
+package goverrun
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// userP12 is a PKCS#12 file (password "secret") with a self-signed client certificate for CN=user-p12
+const userP12 = `
+MIIDggIBAzCCA0gGCSqGSIb3DQEHAaCCAzkEggM1MIIDMTCCAicGCSqGSIb3DQEHBqCCAhgwggIUAgEAMIICDQYJKoZIhvcNAQcB
+MBwGCiqGSIb3DQEMAQMwDgQIaq0LaO1v/EICAggAgIIB4B1Dq6kTKP2gq3KOd2LlAr7EOL7bUYXaf7RrW8JA1WnwN6aOmlsNtqaY
+XlhgM7tOVczNlDoiMAjTzmyD0y8RSmI31vIaEPKHGdwmSXjpW3pgBQdR7FMuk76HF3JYwPNb1KMSHw+Vg4pl2PMZ5nNf4j/Y9cU8
+1k1XSS+KQEx2YFzN+AzpFwK4IGkTkGf1wJAp5KBb4zS2GTwpfthlqwsncSUhbpZvLwACVcDGx4Uhsrkr/wPzxhTIfOUXpsLvsfOM
+o1kBW5IAmbtTEM1yMi164QhYLGdRy7nK5xiGqUf0WXOXs0iUsSjRdHnPwEaE3PuTCSKWHb65w5b54r08Du7QAnkVZO5ega71ARRE
+RvwwPqPRvSMvTOfQ1v5xhJzM7+G1eizBbYXLeA/eMm4s2w/+X7gJe9HDYw5aLtFJAQlyC1i76+51jgnJpDhRDogf3gSCVCIJBFPg
+F9SYrXe98QPz/+JxSjGVOz3Q4FqnxlbT5oFAmi5b/DkOjagCFTR2UuwDEk6V+HfUEGECXU3yJg6+raOG4wqGfLfZqMH0L/fWgAe9
+9yMgtj8y9nmxwZXRwZ7lhoTevlgmjNtKALuHpkg2OZfvZIDTnSx0W16w98qq0/eDTexayWcvyMg79WAhe9qLozCCAQIGCSqGSIb3
+DQEHAaCB9ASB8TCB7jCB6wYLKoZIhvcNAQwKAQKggbQwgbEwHAYKKoZIhvcNAQwBAzAOBAiOcLBQMRJLtwICCAAEgZALGP70kkVw
+uszV6k5XAyBz3G9Ii2Sa3r1zH0rmCVwXjnYQwTZzFqFfdJ31BwOs0uFlTfnC9SAJt8rpDsTeAtXk/KDvvIVwFP+34F9DfQsVs5bj
+s8+sGJly79Pt+OLD7UzmqVNGWDZgPvHhqapM6VTyg9uULKpHiAnd+9u4kFcjta7w7z8+GptGFWOsJI+okb0xJTAjBgkqhkiG9w0B
+CRUxFgQUQzx9qEY/fboiMtJTxoTGh+XaODswMTAhMAkGBSsOAwIaBQAEFMSL2fdthcqTC4LJLonsNFrHhIMoBAjDuAaN1D+s9gIC
+CAA=`
+
+// writeCertificate creates a certificate (self-signed when no parent is given) and writes it and its key as PEM files.
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{"localhost"},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if certFile == keyFile {
+		certPEM = append(certPEM, keyPEM...)
+	} else if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	ca, caKey := writeCertificate(t, caFile, filepath.Join(dir, "ca.key"), "test CA", true, nil, nil)
+	serverFile := filepath.Join(dir, "server.pem")
+	writeCertificate(t, serverFile, serverFile, "localhost", false, ca, caKey)
+	clientFile := filepath.Join(dir, "client.pem")
+	writeCertificate(t, clientFile, clientFile, "single-user", false, nil, nil)
+	userDir := filepath.Join(dir, "users")
+	if err := os.Mkdir(userDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"user-a", "user-b"} {
+		writeCertificate(t, filepath.Join(userDir, name+".crt"), filepath.Join(userDir, name+".key"), name, false, nil, nil)
+	}
+	p12, err := base64.StdEncoding.DecodeString(strings.TrimSpace(userP12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(userDir, "user-c.p12"), p12, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	serverCertificate, err := tls.LoadX509KeyPair(serverFile, serverFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := map[uint16]string{tls.VersionTLS12: "TLS 1.2", tls.VersionTLS13: "TLS 1.3"}[r.TLS.Version]
+		_, _ = fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName, " ", version)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCertificate}, ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	serverURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	for _, test := range []struct {
+		config      TLSConfig
+		currentUser int
+		want        string
+	}{
+		{TLSConfig{RootCAFiles: []string{caFile}, ClientCertFile: clientFile}, 1, "single-user TLS 1.3"},
+		{TLSConfig{RootCAFiles: []string{caFile}, ClientCertDir: userDir, PKCS12Password: "secret", MaxVersion: "1.2"}, 1, "user-a TLS 1.2"},
+		{TLSConfig{RootCAFiles: []string{caFile}, ClientCertDir: userDir, PKCS12Password: "secret"}, 2, "user-b TLS 1.3"},
+		{TLSConfig{RootCAFiles: []string{caFile}, ClientCertDir: userDir, PKCS12Password: "secret"}, 3, "user-p12 TLS 1.3"},
+		{TLSConfig{RootCAFiles: []string{caFile}, ClientCertDir: userDir, PKCS12Password: "secret"}, 4, "user-a TLS 1.3"},
+	} {
+		transport, err := NewRoundTripperWrapperWithConfig(TransportConfig{TLS: test.config}, ConnectionPolicy{}, test.currentUser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user := &User{CurrentUser: test.currentUser, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport}}
+		response := user.Step("mTLS").Request(http.MethodGet, serverURL).SendWithoutTimeout()
+		if response.Error != nil || string(response.Body) != test.want {
+			t.Errorf("user %d: got %q (%v) want %q", test.currentUser, response.Body, response.Error, test.want)
+		}
+	}
+
+	// verification stays on: the system roots don't know the test CA
+	transport, err := NewRoundTripperWrapperWithConfig(TransportConfig{TLS: TLSConfig{ClientCertFile: clientFile}}, ConnectionPolicy{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &User{CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport}}
+	if response := user.Step("unknown CA").Request(http.MethodGet, serverURL).SendWithoutTimeout(); response.Error == nil {
+		t.Error("expected certificate verification error")
+	}
+}
+
+func TestTLSConfigValidation(t *testing.T) {
+	for _, config := range []TLSConfig{
+		{MinVersion: "1.4"},
+		{MinVersion: "1.3", MaxVersion: "1.2"},
+		{CipherSuites: []string{"TLS_UNKNOWN"}},
+		{RootCAFiles: []string{"missing-ca.pem"}},
+		{ClientCertDir: os.TempDir() + "/missing-dir"},
+	} {
+		if err := (TransportConfig{TLS: config}).Validate(); err == nil {
+			t.Errorf("%+v: expected validation error", config)
+		}
+	}
+	if err := (TransportConfig{TLS: TLSConfig{MinVersion: "TLS1.2", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,10 +1,19 @@
 package goverrun
 
 import (
+	"context"
 	"crypto/tls"
+	"fmt"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"sync"
+	"time"
 )
 
 // TODO add more user agent strings to choose from
@@ -101,34 +110,481 @@ func RandomUserAgent() string {
 type RoundTripperWrapper struct {
 	realRoundTripper http.RoundTripper
 	//currentRequest      *http.Request
-	UserAgent string
+	UserAgent                 string
+	RandomUserAgentPerRequest bool       // ignores UserAgent and chooses a random one for every request
+	Cache                     *HTTPCache // nil when the HTTP cache emulation is disabled
+	network                   *emulatedNetwork
 }
 
 func NewRoundTripperWrapper(skipCertificateValidationForInvalidTestCerts bool, proxy string) *RoundTripperWrapper {
-	var roundTripper http.RoundTripper
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	if skipCertificateValidationForInvalidTestCerts {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	config := TransportConfig{Proxy: ProxyConfig{URL: proxy}}
+	tlsConfig := &tls.Config{InsecureSkipVerify: skipCertificateValidationForInvalidTestCerts}
+	return wrapRoundTripper(config, newRoundTripper(config, ConnectionPolicy{}, tlsConfig, "", 0), 0)
+}
+
+// NewRoundTripperWrapperWithConfig creates the round-tripper for the given transport config and connection policy
+// (see TransportConfig.Validate and ConnectionPolicy.Validate) for the given user (one-based, selecting the client certificate).
+func NewRoundTripperWrapperWithConfig(config TransportConfig, policy ConnectionPolicy, currentUser int) (*RoundTripperWrapper, error) {
+	loaded, err := config.TLS.load()
+	if err != nil {
+		return nil, err
 	}
-	if len(proxy) > 0 {
-		transport.Proxy = func(request *http.Request) (*url.URL, error) {
-			return url.Parse(proxy) // proxy through ZAP or similar to debug: http://127.0.0.1:8080
-		}
+	return wrapRoundTripper(config, newRoundTripper(config, policy, loaded.forUser(currentUser), "", currentUser), currentUser), nil
+}
+
+// wrapRoundTripper wraps the round-tripper for the given user (one-based or zero for none in particular).
+func wrapRoundTripper(config TransportConfig, roundTripper http.RoundTripper, currentUser int) *RoundTripperWrapper {
+	ua := config.UserAgent
+	if len(ua) == 0 {
+		ua = UserAgent
 	}
-	roundTripper = transport
-	ua := UserAgent
 	if len(ua) == 0 {
 		ua = RandomUserAgent()
 	}
-	return &RoundTripperWrapper{
-		realRoundTripper: roundTripper,
-		UserAgent:        ua,
+	trans := &RoundTripperWrapper{
+		realRoundTripper:          roundTripper,
+		UserAgent:                 ua,
+		RandomUserAgentPerRequest: len(config.UserAgent) == 0 && config.UserAgentStrategy == UserAgentRandomPerRequest,
 	}
+	trans.EmulateNetwork(config.networkProfile(currentUser))
+	return trans
+}
+
+// newRoundTripper creates the transport speaking the configured protocol, counting its open connections for the given scenario.
+func newRoundTripper(config TransportConfig, policy ConnectionPolicy, tlsConfig *tls.Config, scenario string, currentUser int) http.RoundTripper {
+	var roundTripper http.RoundTripper
+	if SkipCertificateValidation {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	dialTimeout := 30 * time.Second // like http.DefaultTransport
+	if config.Timeouts.Dial > 0 {
+		dialTimeout = config.Timeouts.Dial
+	}
+	tlsHandshakeTimeout := 10 * time.Second // like http.DefaultTransport
+	if config.Timeouts.TLSHandshake > 0 {
+		tlsHandshakeTimeout = config.Timeouts.TLSHandshake
+	}
+	dialer := &countingDialer{
+		Dialer:               net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second},
+		tlsHandshakeTimeout:  tlsHandshakeTimeout,
+		scenario:             scenario,
+		currentUser:          currentUser,
+		localAddresses:       config.LocalAddresses,
+		localAddressStrategy: config.LocalAddressStrategy,
+	}
+	if config.Resolver.enabled() {
+		dialer.resolver = newResolver(config.Resolver, &dialer.Dialer, scenario, currentUser)
+	}
+	proxy := config.Proxy.url()
+	switch config.Protocol {
+	case ProtocolHTTP2:
+		roundTripper = &http2.Transport{TLSClientConfig: tlsConfig, DialTLSContext: dialer.dialTLSContext}
+	case ProtocolH2C:
+		roundTripper = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr) // prior knowledge: speak HTTP/2 without TLS and upgrade
+			},
+		}
+	case ProtocolHTTP3:
+		roundTripper = &http3.Transport{
+			TLSClientConfig: tlsConfig,
+			QUICConfig:      &quic.Config{HandshakeIdleTimeout: tlsHandshakeTimeout},
+			Dial:            dialer.dialQUIC,
+		}
+	default:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dialer.DialContext
+		transport.TLSClientConfig = tlsConfig
+		if proxy != nil {
+			transport.Proxy = http.ProxyURL(proxy) // proxy through ZAP or similar to debug: http://127.0.0.1:8080
+		}
+		transport.TLSHandshakeTimeout = tlsHandshakeTimeout
+		transport.ResponseHeaderTimeout = config.Timeouts.ResponseHeader
+		if config.Protocol == ProtocolHTTP1 {
+			transport.ForceAttemptHTTP2 = false
+			transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper) // non-nil empty map disables HTTP/2
+		}
+		transport.DisableKeepAlives = policy.DisableKeepAlive
+		transport.MaxConnsPerHost = policy.MaxConnectionsPerHost
+		if policy.IdleTimeout > 0 {
+			transport.IdleConnTimeout = policy.IdleTimeout
+		}
+		roundTripper = transport
+	}
+	return roundTripper
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport (i.e. the next request opens a new connection).
+func (trans *RoundTripperWrapper) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if transport, ok := trans.realRoundTripper.(closeIdler); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// userAgent returns the user agent of the next request of the user (chosen by the transport of the scenario,
+// i.e. the package-level UserAgent for other transports) for requests not sent via the transport (like gRPC).
+func (user *User) userAgent() string {
+	if user.HttpClient == nil {
+		return UserAgent
+	}
+	wrapper, ok := user.HttpClient.Transport.(*RoundTripperWrapper)
+	if !ok {
+		return UserAgent
+	}
+	if wrapper.RandomUserAgentPerRequest {
+		return RandomUserAgent()
+	}
+	return wrapper.UserAgent
 }
 
 // RoundTrip wraps http.DefaultTransport.RoundTrip to keep track of the current request.
 func (trans *RoundTripperWrapper) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Add("User-Agent", trans.UserAgent)
+	if trans.RandomUserAgentPerRequest {
+		req.Header.Add("User-Agent", RandomUserAgent())
+	} else {
+		req.Header.Add("User-Agent", trans.UserAgent)
+	}
 	//trans.currentRequest = req
-	return trans.realRoundTripper.RoundTrip(req)
+	next := trans.realRoundTripper
+	if trans.network != nil {
+		next = trans.network
+	}
+	if trans.Cache != nil {
+		return trans.Cache.roundTrip(req, next)
+	}
+	return next.RoundTrip(req)
+}
+
+// Protocol selects the HTTP protocol version spoken by the users of a scenario.
+type Protocol string
+
+const (
+	// ProtocolAuto negotiates HTTP/2 via ALPN for TLS connections and falls back to HTTP/1.1 (like browsers do).
+	ProtocolAuto Protocol = ""
+	// ProtocolHTTP1 forces HTTP/1.1 (also for servers supporting HTTP/2).
+	ProtocolHTTP1 Protocol = "http/1.1"
+	// ProtocolHTTP2 forces HTTP/2 over TLS (requests to servers without HTTP/2 support fail).
+	// Neither a proxy nor a response header timeout is supported.
+	ProtocolHTTP2 Protocol = "h2"
+	// ProtocolH2C speaks HTTP/2 over cleartext TCP with prior knowledge (i.e. without upgrade).
+	// Neither a proxy nor a response header timeout is supported.
+	ProtocolH2C Protocol = "h2c"
+	// ProtocolHTTP3 speaks HTTP/3 over QUIC (requests to servers without HTTP/3 support fail).
+	// Neither a proxy nor a response header timeout is supported.
+	ProtocolHTTP3 Protocol = "h3"
+)
+
+// TransportConfig configures the transport of all users of a scenario. Unset values fall back to the
+// corresponding package-level defaults (like Proxy or UserAgent), so scenarios of the same run can
+// e.g. use different proxies.
+type TransportConfig struct {
+	Protocol          Protocol
+	TLS               TLSConfig
+	Proxy             ProxyConfig
+	UserAgent         string            // fixed user agent for all requests (when empty: chosen according to UserAgentStrategy)
+	UserAgentStrategy UserAgentStrategy // how to choose random user agents of UserAgents
+	Timeouts          TimeoutConfig
+	Tagging           TaggingConfig
+	Resolver          ResolverConfig
+	Network           NetworkProfile   // emulated network conditions of all users (see NetworkMix)
+	NetworkMix        []NetworkProfile // network profiles assigned round-robin to the users (instead of Network)
+
+	LocalAddresses       []string             // local IPs (of a load generator with multiple IPs) to bind the outgoing connections to
+	LocalAddressStrategy LocalAddressStrategy // how the LocalAddresses are assigned
+}
+
+// ProxyConfig configures the proxy all requests are sent through.
+type ProxyConfig struct {
+	URL      string // like http://proxy:8080, https://proxy:8443 or socks5://proxy:1080 (credentials also in URL)
+	Username string // proxy authentication (overrides the credentials of the URL)
+	Password string
+}
+
+// UserAgentStrategy defines how the random user agents are chosen.
+type UserAgentStrategy int
+
+const (
+	// UserAgentRandomPerUser chooses one random user agent per user (i.e. per connection pool like browsers).
+	UserAgentRandomPerUser UserAgentStrategy = iota
+	// UserAgentRandomPerRequest chooses a random user agent for every request.
+	UserAgentRandomPerRequest
+)
+
+// TimeoutConfig configures the transport timeouts (the request timeouts are set per request, see Request.SendWithTimeout).
+type TimeoutConfig struct {
+	Dial           time.Duration // zero means the default of 30 seconds
+	TLSHandshake   time.Duration // zero means the default of 10 seconds
+	ResponseHeader time.Duration // time to wait for the response headers after the request is sent, zero means no limit
+}
+
+// TaggingConfig configures the headers added to every request to identify them in the logs of the tested system.
+type TaggingConfig struct {
+	UserLoopHeader     bool // adds Goverrun-User-Loop with the current user and loop
+	ScenarioStepHeader bool // adds Goverrun-Scenario-Step with the scenario and step name
+}
+
+// url returns the proxy URL (falling back to the package-level Proxy) including the credentials or nil without proxy.
+func (config ProxyConfig) url() *url.URL {
+	proxy := config.URL
+	if len(proxy) == 0 {
+		proxy = Proxy
+	}
+	if len(proxy) == 0 {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		LogError("invalid proxy URL:", err)
+		return nil
+	}
+	if len(config.Username) > 0 {
+		u.User = url.UserPassword(config.Username, config.Password)
+	}
+	return u
+}
+
+const redacted = "xxxxx"
+
+// redacted returns the config without secrets (e.g. to be written to the results).
+func (config TransportConfig) redacted() TransportConfig {
+	if u, err := url.Parse(config.Proxy.URL); err == nil && u.User != nil {
+		config.Proxy.URL = u.Redacted()
+	}
+	if len(config.Proxy.Password) > 0 {
+		config.Proxy.Password = redacted
+	}
+	if len(config.TLS.PKCS12Password) > 0 {
+		config.TLS.PKCS12Password = redacted
+	}
+	return config
+}
+
+// Validate checks the transport config for unsupported settings and unreadable certificate files.
+func (config TransportConfig) Validate() error {
+	switch config.Protocol {
+	case ProtocolAuto, ProtocolHTTP1, ProtocolHTTP2, ProtocolH2C, ProtocolHTTP3:
+	default:
+		return fmt.Errorf("unknown protocol '%s'", config.Protocol)
+	}
+	if len(config.Proxy.URL) > 0 {
+		u, err := url.Parse(config.Proxy.URL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported proxy scheme '%s'", u.Scheme)
+		}
+	}
+	for _, profile := range append([]NetworkProfile{config.Network}, config.NetworkMix...) {
+		if err := profile.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := config.validateLocalAddresses(); err != nil {
+		return err
+	}
+	if err := config.Resolver.Validate(); err != nil {
+		return err
+	}
+	if config.UserAgentStrategy != UserAgentRandomPerUser && config.UserAgentStrategy != UserAgentRandomPerRequest {
+		return fmt.Errorf("unknown user agent strategy %d", config.UserAgentStrategy)
+	}
+	if config.Timeouts.Dial < 0 || config.Timeouts.TLSHandshake < 0 || config.Timeouts.ResponseHeader < 0 {
+		return fmt.Errorf("negative timeout")
+	}
+	if err := config.validateProtocolSupport(); err != nil {
+		return err
+	}
+	_, err := config.TLS.load() // to fail early on unreadable certificate files
+	return err
+}
+
+// validateProtocolSupport checks the proxy (also the package-level Proxy, which may be set after adding the scenario)
+// and the timeouts to be supported by the protocol.
+func (config TransportConfig) validateProtocolSupport() error {
+	if config.Protocol != ProtocolHTTP2 && config.Protocol != ProtocolH2C && config.Protocol != ProtocolHTTP3 {
+		return nil
+	}
+	if len(config.Proxy.URL) > 0 || len(Proxy) > 0 {
+		return fmt.Errorf("proxy is not supported for protocol %s", config.Protocol)
+	}
+	if config.Timeouts.ResponseHeader > 0 {
+		return fmt.Errorf("response header timeout is not supported for protocol %s", config.Protocol)
+	}
+	return nil
+}
+
+// ConnectionPolicy configures the connection management of the users of a scenario.
+type ConnectionPolicy struct {
+	DisableKeepAlive       bool          // opens a new connection for every request
+	MaxConnectionsPerHost  int           // limits the (active and idle) connections per host, zero means no limit
+	IdleTimeout            time.Duration // closes connections idle for longer, zero means the default of 90 seconds
+	NewConnectionEveryLoop bool          // closes the idle connections before every loop of a user (not with SharedTransport)
+	SharedTransport        bool          // all users of the scenario share one connection pool (e.g. to emulate a gateway)
+}
+
+// Validate checks the connection policy for invalid values and settings unsupported by the given protocol.
+func (policy ConnectionPolicy) Validate(protocol Protocol) error {
+	if policy.MaxConnectionsPerHost < 0 {
+		return fmt.Errorf("negative MaxConnectionsPerHost")
+	}
+	if policy.IdleTimeout < 0 {
+		return fmt.Errorf("negative IdleTimeout")
+	}
+	if protocol != ProtocolAuto && protocol != ProtocolHTTP1 && (policy.DisableKeepAlive || policy.MaxConnectionsPerHost > 0 || policy.IdleTimeout > 0) {
+		return fmt.Errorf("DisableKeepAlive, MaxConnectionsPerHost and IdleTimeout are not supported for protocol %s", protocol)
+	}
+	if policy.NewConnectionEveryLoop && policy.SharedTransport {
+		return fmt.Errorf("NewConnectionEveryLoop is not supported with a shared transport (it would close the connections of all users)")
+	}
+	return nil
+}
+
+// openConnections counts the currently open connections per scenario.
+var openConnections = safeTracker{counters: make(map[string]int)}
+
+type countingDialer struct {
+	net.Dialer
+	tlsHandshakeTimeout  time.Duration // of HTTP/2 connections (the transport of HTTP/1.1 handshakes itself, QUIC via its config)
+	scenario             string
+	currentUser          int
+	resolver             *resolver // nil when using the system resolver
+	localAddresses       []string
+	localAddressStrategy LocalAddressStrategy
+}
+
+func (dialer *countingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	d := dialer.Dialer
+	d.LocalAddr = dialer.localAddress(network)
+	if dialer.resolver != nil {
+		conn, err = dialer.resolver.dial(ctx, &d, network, addr)
+	} else {
+		conn, err = d.DialContext(ctx, network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	openConnections.Inc(dialer.scenario)
+	return &countedConn{Conn: conn, scenario: dialer.scenario}, nil
+}
+
+func (dialer *countingDialer) dialTLSContext(ctx context.Context, network, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	handshakeCtx, cancel := context.WithTimeout(ctx, dialer.tlsHandshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if protocol := tlsConn.ConnectionState().NegotiatedProtocol; protocol != http2.NextProtoTLS {
+		_ = conn.Close()
+		return nil, fmt.Errorf("server does not support HTTP/2 (negotiated protocol '%s')", protocol)
+	}
+	return tlsConn, nil
+}
+
+// dialQUIC connects to the first reachable of the resolved addresses via QUIC (from the local address of the user, if any).
+func (dialer *countingDialer) dialQUIC(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (quic.EarlyConnection, error) {
+	r := dialer.resolver
+	if r == nil {
+		r = newResolver(ResolverConfig{}, &dialer.Dialer, dialer.scenario, dialer.currentUser)
+	}
+	addresses, err := r.addresses(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range addresses {
+		var udpAddr *net.UDPAddr
+		if udpAddr, err = net.ResolveUDPAddr("udp", address); err != nil {
+			continue
+		}
+		localAddr, _ := dialer.localAddress("udp").(*net.UDPAddr)
+		var packetConn *net.UDPConn
+		if packetConn, err = net.ListenUDP("udp", localAddr); err != nil {
+			continue
+		}
+		var conn quic.EarlyConnection
+		if conn, err = quic.DialEarly(ctx, packetConn, udpAddr, tlsConfig, quicConfig); err != nil {
+			_ = packetConn.Close()
+			continue
+		}
+		openConnections.Inc(dialer.scenario)
+		go func() {
+			<-conn.Context().Done() // the UDP socket is not closed together with the QUIC connection using it
+			_ = packetConn.Close()
+			openConnections.Dec(dialer.scenario)
+		}()
+		return tracedQUICConnection{EarlyConnection: conn}, nil
+	}
+	return nil, err
+}
+
+// tracedQUICConnection reports the request written and the first response byte to the client trace of the request,
+// which the HTTP/3 transport doesn't do itself.
+type tracedQUICConnection struct {
+	quic.EarlyConnection
+}
+
+func (conn tracedQUICConnection) OpenStreamSync(ctx context.Context) (quic.Stream, error) {
+	stream, err := conn.EarlyConnection.OpenStreamSync(ctx)
+	trace := httptrace.ContextClientTrace(ctx)
+	if err != nil || trace == nil {
+		return stream, err
+	}
+	return &tracedQUICStream{Stream: stream, trace: trace}, nil
+}
+
+// tracedQUICStream is the stream of a single request, which is written when the stream is closed for sending.
+type tracedQUICStream struct {
+	quic.Stream
+	trace     *httptrace.ClientTrace
+	wroteOnce sync.Once
+	readOnce  sync.Once
+}
+
+func (stream *tracedQUICStream) Close() error {
+	err := stream.Stream.Close()
+	stream.wroteOnce.Do(func() {
+		if stream.trace.WroteRequest != nil {
+			stream.trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+		}
+	})
+	return err
+}
+
+func (stream *tracedQUICStream) Read(p []byte) (int, error) {
+	n, err := stream.Stream.Read(p)
+	if n > 0 {
+		stream.readOnce.Do(func() {
+			if stream.trace.GotFirstResponseByte != nil {
+				stream.trace.GotFirstResponseByte()
+			}
+		})
+	}
+	return n, err
+}
+
+type countedConn struct {
+	net.Conn
+	scenario string
+	once     sync.Once
+}
+
+func (conn *countedConn) Close() error {
+	conn.once.Do(func() {
+		openConnections.Dec(conn.scenario)
+	})
+	return conn.Conn.Close()
 }
@@ -64,8 +64,8 @@ func PrintMissingSubcommandAndExit(validCommands ...*flag.FlagSet) {
 		valids.WriteString(valid.Name())
 		valids.WriteString("'")
 	}
-	LogFatal("Missing required subcommand, choose from: ", valids.String())
-	os.Exit(1)
+	LogError("Missing required subcommand, choose from: ", valids.String())
+	os.Exit(ExitUsage)
 }
 
 func panicOnErr(err error) {
@@ -0,0 +1,283 @@
+package goverrun
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// WebSocket is an open WebSocket connection of a user. Its messages are sent and received within steps
+// (see Step.OnWebSocket), which are recorded like requests: the round-trip time of a message is its
+// Time-to-First-Byte and the message counts are tracked in the step files.
+//
+// A WebSocket stays open across the loops of the user until it is closed (see User.OpenWebSocket to
+// hold long-lived connections) and is closed latest when the user stops looping.
+type WebSocket struct {
+	URL      string
+	Response *Response       // of the opening handshake
+	conn     *websocket.Conn // nil when the connection failed or is closed
+}
+
+// WebSocketStep is a step sending and receiving messages of a WebSocket.
+type WebSocketStep struct {
+	step      *Step
+	webSocket *WebSocket
+}
+
+// ConnectWebSocket opens a WebSocket connection (the opening handshake is the request of this step). The handshake
+// uses the cookies, headers and transport settings (proxy, TLS, dialer) of the user. Use ws.Response to assert on
+// the handshake and to archive it.
+func (step *Step) ConnectWebSocket(url string, timeout time.Duration) *WebSocket {
+	user := step.User
+	ws := &WebSocket{URL: url}
+	if user.Disabled {
+		ws.Response = &Response{Timestamps: &Timestamps{}, archived: true}
+		return ws
+	}
+	rsp := &Response{
+		Scenario:   user.Scenario,
+		Step:       step,
+		RequestURL: url,
+		Timestamps: &Timestamps{},
+	}
+	user.trackSmoke(rsp)
+	ws.Response = rsp
+	header, err := user.webSocketHeader(step, url)
+	if err != nil {
+		rsp.trackError(err)
+		return ws
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: rsp.gotFirstResponseByte,
+		DNSStart:             rsp.dnsStart,
+		DNSDone:              rsp.dnsDone,
+		GotConn:              rsp.gotConn,
+	})
+	if verbose {
+		user.printStep(step)
+	}
+	rsp.Timestamps.Start = time.Now()
+	rsp.Timestamps.WroteRequest = rsp.Timestamps.Start // the handshake request is written right after connecting
+	conn, handshake, err := user.webSocketDialer().DialContext(ctx, url, header)
+	rsp.Timestamps.Done = time.Now()
+	rsp.RequestSize = HeaderSize(header)
+	if handshake != nil {
+		rsp.StatusCode = handshake.StatusCode
+		rsp.Status = handshake.Status
+		rsp.Protocol = handshake.Proto
+		rsp.Header = handshake.Header
+		rsp.FinalURL = url
+		rsp.ResponseSize = HeaderSize(handshake.Header)
+	}
+	if err != nil {
+		rsp.trackError(err)
+		return ws
+	}
+	ws.conn = conn
+	rsp.OpenConnections = openConnections.Value(user.Scenario)
+	if user.webSockets == nil {
+		user.webSockets = make(map[string]*WebSocket)
+	}
+	if previous := user.webSockets[url]; previous != nil {
+		previous.Close()
+	}
+	user.webSockets[url] = ws
+	return ws
+}
+
+// OpenWebSocket returns the still open WebSocket connection of the user to the URL (or nil when there is none).
+func (user *User) OpenWebSocket(url string) *WebSocket {
+	if ws := user.webSockets[url]; ws != nil && ws.IsOpen() {
+		return ws
+	}
+	return nil
+}
+
+// closeWebSockets closes all WebSocket connections of the user.
+func (user *User) closeWebSockets() {
+	for _, ws := range user.webSockets {
+		ws.Close()
+	}
+}
+
+// webSocketHeader builds the handshake headers like for a regular request (tagging headers and request interceptors).
+func (user *User) webSocketHeader(step *Step, url string) (http.Header, error) {
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if userAgent := user.userAgent(); len(userAgent) > 0 {
+		r.Header.Set("User-Agent", userAgent)
+	}
+	user.addTaggingHeaders(r, step)
+	user.callRequestInterceptors(r)
+	return r.Header, nil
+}
+
+// webSocketDialer creates a dialer using the cookie jar and (if possible) the transport settings of the user.
+func (user *User) webSocketDialer() *websocket.Dialer {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second, // like websocket.DefaultDialer
+		Jar:              user.HttpClient.Jar,
+	}
+	var transport *http.Transport
+	switch rt := user.HttpClient.Transport.(type) {
+	case *http.Transport:
+		transport = rt
+	case *RoundTripperWrapper:
+		transport, _ = rt.realRoundTripper.(*http.Transport)
+	}
+	if transport != nil {
+		dialer.Proxy = transport.Proxy
+		dialer.NetDialContext = transport.DialContext
+		if transport.TLSClientConfig != nil {
+			dialer.TLSClientConfig = transport.TLSClientConfig.Clone()
+			dialer.TLSClientConfig.NextProtos = nil // the handshake requires HTTP/1.1
+		}
+	} else if SkipCertificateValidation {
+		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return dialer
+}
+
+// IsOpen returns if the connection is (still) open.
+func (ws *WebSocket) IsOpen() bool {
+	return ws.conn != nil
+}
+
+// Close sends a close message and closes the connection.
+func (ws *WebSocket) Close() {
+	if ws.conn == nil {
+		return
+	}
+	_ = ws.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	_ = ws.conn.Close()
+	ws.conn = nil
+}
+
+// OnWebSocket uses the step for sending and receiving messages of the WebSocket.
+func (step *Step) OnWebSocket(ws *WebSocket) *WebSocketStep {
+	return &WebSocketStep{step: step, webSocket: ws}
+}
+
+// SendText sends a text message (without waiting for any response message).
+func (wss *WebSocketStep) SendText(message string) *Response {
+	return wss.exchange(websocket.TextMessage, []byte(message), false, 0, nil)
+}
+
+// SendBinary sends a binary message (without waiting for any response message).
+func (wss *WebSocketStep) SendBinary(data []byte) *Response {
+	return wss.exchange(websocket.BinaryMessage, data, false, 0, nil)
+}
+
+// Receive waits for the next message, which becomes the body of the response.
+func (wss *WebSocketStep) Receive(timeout time.Duration) *Response {
+	return wss.exchange(0, nil, true, timeout, nil)
+}
+
+// ReceiveUntilContains waits for the next message containing the given text (skipping other messages).
+func (wss *WebSocketStep) ReceiveUntilContains(text string, timeout time.Duration) *Response {
+	return wss.exchange(0, nil, true, timeout, func(message []byte) bool {
+		return strings.Contains(string(message), text)
+	})
+}
+
+// SendTextAndReceive sends a text message and waits for the next message (the round-trip time is tracked as Time-to-First-Byte).
+func (wss *WebSocketStep) SendTextAndReceive(message string, timeout time.Duration) *Response {
+	return wss.exchange(websocket.TextMessage, []byte(message), true, timeout, nil)
+}
+
+// SendTextAndReceiveUntilContains sends a text message and waits for the next message containing the given text (skipping other messages).
+func (wss *WebSocketStep) SendTextAndReceiveUntilContains(message, text string, timeout time.Duration) *Response {
+	return wss.exchange(websocket.TextMessage, []byte(message), true, timeout, func(received []byte) bool {
+		return strings.Contains(string(received), text)
+	})
+}
+
+// exchange sends the message (if any) and receives a message (if requested) matching the filter (if any).
+// A receive timeout breaks the connection, which is closed then.
+func (wss *WebSocketStep) exchange(messageType int, message []byte, receive bool, timeout time.Duration, filter func([]byte) bool) *Response {
+	user, ws := wss.step.User, wss.webSocket
+	if user.Disabled {
+		return &Response{Timestamps: &Timestamps{}, archived: true}
+	}
+	rsp := &Response{
+		Scenario:   user.Scenario,
+		Step:       wss.step,
+		RequestURL: ws.URL,
+		FinalURL:   ws.URL,
+		Protocol:   "websocket",
+		Timestamps: &Timestamps{},
+	}
+	user.trackSmoke(rsp)
+	if verbose {
+		user.printStep(wss.step)
+	}
+	rsp.Timestamps.Start = time.Now()
+	if ws.conn == nil {
+		rsp.Error = fmt.Errorf("websocket connection to %s is not open", ws.URL)
+		rsp.Timestamps.Done = time.Now()
+		return rsp
+	}
+	if message != nil {
+		err := ws.conn.WriteMessage(messageType, message)
+		rsp.Timestamps.WroteRequest = time.Now()
+		if err != nil {
+			rsp.trackError(err)
+			rsp.Timestamps.Done = time.Now()
+			ws.Close()
+			return rsp
+		}
+		rsp.RequestSize = len(message)
+		rsp.MessagesSent = 1
+	} else {
+		rsp.Timestamps.WroteRequest = rsp.Timestamps.Start
+	}
+	if receive {
+		deadline := time.Time{}
+		if timeout > 0 {
+			deadline = rsp.Timestamps.Start.Add(timeout)
+		}
+		_ = ws.conn.SetReadDeadline(deadline)
+		for {
+			_, received, err := ws.conn.ReadMessage()
+			if err != nil {
+				rsp.trackError(err)
+				ws.Close()
+				break
+			}
+			rsp.MessagesReceived++
+			rsp.ResponseSize += len(received)
+			if filter == nil || filter(received) {
+				rsp.Timestamps.GotFirstResponseByte = time.Now()
+				rsp.Body = received
+				break
+			}
+		}
+	}
+	rsp.Timestamps.Done = time.Now()
+	rsp.OpenConnections = openConnections.Value(user.Scenario)
+	return rsp
+}
+
+// MessageCounts are the numbers of WebSocket messages sent and received.
+type MessageCounts struct {
+	Sent, Received uint64
+}
+
+func (counts *MessageCounts) add(other MessageCounts) {
+	counts.Sent += other.Sent
+	counts.Received += other.Received
+}
@@ -0,0 +1,88 @@
+package goverrun
+
+import (
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || r.Header.Get("Goverrun-Scenario-Step") != "chat: connect" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(message) == "subscribe" {
+				_ = conn.WriteMessage(websocket.TextMessage, []byte("ticker 1"))
+				_ = conn.WriteMessage(websocket.TextMessage, []byte("ticker 2"))
+			}
+			_ = conn.WriteMessage(messageType, []byte(cookie.Value+": "+string(message)))
+		}
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	transport, err := NewRoundTripperWrapperWithConfig(TransportConfig{}, ConnectionPolicy{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar, _ := cookiejar.New(nil)
+	serverURL, _ := url.Parse(server.URL)
+	jar.SetCookies(serverURL, []*http.Cookie{{Name: "session", Value: "alice"}})
+	user := &User{Scenario: "chat", CurrentUser: 1, CurrentLoop: 1, HttpClient: &http.Client{Transport: transport, Jar: jar},
+		tagging: TaggingConfig{ScenarioStepHeader: true}}
+
+	ws := user.Step("connect").ConnectWebSocket(wsURL, time.Second)
+	if _, completed := ws.Response.Timestamps.TimeToFirstByte(false); ws.Response.Error != nil || ws.Response.StatusCode != http.StatusSwitchingProtocols || !completed {
+		t.Fatalf("unexpected handshake: %d (%v)", ws.Response.StatusCode, ws.Response.Error)
+	}
+	if user.OpenWebSocket(wsURL) != ws {
+		t.Error("expected the connection to be held open by the user")
+	}
+
+	response := user.Step("echo").OnWebSocket(ws).SendTextAndReceive("hello", time.Second).AssertBodyContains("alice: hello")
+	if _, completed := response.Timestamps.TimeToFirstByte(true); response.ConsideredUnsuccessful() || !completed || response.MessagesSent != 1 || response.MessagesReceived != 1 {
+		t.Errorf("unexpected echo: %q (%v) %+v", response.Body, response.Error, response.stepEntry())
+	}
+	response = user.Step("subscribe").OnWebSocket(ws).SendTextAndReceiveUntilContains("subscribe", "alice:", time.Second)
+	if string(response.Body) != "alice: subscribe" || response.MessagesReceived != 3 {
+		t.Errorf("expected the ticker messages to be skipped: %q after %d messages", response.Body, response.MessagesReceived)
+	}
+	if response := user.Step("send").OnWebSocket(ws).SendBinary([]byte("data")); response.Error != nil || response.RequestSize != 4 {
+		t.Errorf("unexpected send: %+v", response.stepEntry())
+	}
+	if response := user.Step("receive").OnWebSocket(ws).Receive(time.Second); string(response.Body) != "alice: data" {
+		t.Errorf("unexpected receive: %q (%v)", response.Body, response.Error)
+	}
+
+	response = user.Step("silence").OnWebSocket(ws).Receive(50 * time.Millisecond)
+	if response.Timeout == nil || ws.IsOpen() || user.OpenWebSocket(wsURL) != nil {
+		t.Errorf("expected the receive timeout to close the connection: %v", response.Error)
+	}
+	if response := user.Step("closed").OnWebSocket(ws).SendText("late"); response.Error == nil {
+		t.Error("expected error sending on a closed connection")
+	}
+
+	user.tagging = TaggingConfig{}
+	if ws := user.Step("connect").ConnectWebSocket(wsURL, time.Second); ws.IsOpen() || ws.Response.StatusCode != http.StatusForbidden {
+		t.Errorf("expected rejected handshake: %d", ws.Response.StatusCode)
+	}
+	user.closeWebSockets()
+}
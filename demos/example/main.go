@@ -1,11 +1,13 @@
 package main
 
 import (
+	_ "embed"
 	"fmt"
 	. "github.com/goverrun/goverrun/core"
 	"net/http"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -14,11 +16,19 @@ const debug = false
 var build string // set during build
 var targetURL string
 
+//go:embed search-terms.csv
+var searchTermsCSV string
+var searchTerms *Feeder
+
 func main() {
 	CommandlineDefaults(3, 3, 10, 3, "/tmp/marathon")
 	fmt.Println("Build:", build)
 
-	err := AddScenario(&Scenario{
+	var err error
+	searchTerms, err = NewCSVFeederFromReader(strings.NewReader(searchTermsCSV), FeedCircular)
+	CheckErrAndLogFatal(err, "unable to load search terms")
+
+	err = AddScenario(&Scenario{
 		Title:       "view standings",
 		Description: "viewing standings of two different disciplines",
 		Runner:      viewStandings,
@@ -75,7 +85,7 @@ func viewStandings(user *User) {
 
 func searchRunners(user *User) {
 	doOpenStartPage(user)
-	doSubmitRunnerSearch(user, true)
+	doSubmitFedRunnerSearch(user)
 	doGoBackHome(user)
 	doSubmitRunnerSearch(user, false)
 }
@@ -223,6 +233,20 @@ func doSubmitRunnerSearch(user *User, john bool) {
 	return
 }
 
+func doSubmitFedRunnerSearch(user *User) {
+	searchTerm, ok := user.Feed(searchTerms)
+	if !ok {
+		return
+	}
+	user.Step("submit runner search").
+		ExpectSuccessPercentageAtLeast(95).
+		Request(http.MethodPost, targetURL+"searchRunner.page").
+		SetFormParam("searchTerm", searchTerm["term"]).
+		SendWithTimeout(3 * time.Second).
+		AssertBodyContains(searchTerm["expected"]).AssertStatusCode(http.StatusOK).ArchiveStats()
+	user.ThinkTime(RandomDuration(2000*time.Millisecond, 4500*time.Millisecond))
+}
+
 func doViewRunnerProfile(user *User, john bool) {
 	if john {
 		user.Step("view profile").
@@ -1,11 +1,36 @@
 module github.com/goverrun/goverrun
 
-go 1.17
+go 1.22
 
 require (
 	github.com/PaesslerAG/gval v1.1.1
 	github.com/PaesslerAG/jsonpath v0.1.1
+	github.com/andybalholm/cascadia v1.3.1
+	github.com/antchfx/htmlquery v1.2.5
+	github.com/antchfx/xpath v1.2.1
 	github.com/aybabtme/uniplot v0.0.0-20151203143629-039c559e5e7e
+	github.com/gorilla/websocket v1.5.0
 	github.com/montanaflynn/stats v0.6.6
-	golang.org/x/text v0.3.7
+	github.com/quic-go/quic-go v0.48.2
+	golang.org/x/crypto v0.26.0
+	golang.org/x/net v0.28.0
+	golang.org/x/text v0.17.0
+	google.golang.org/grpc v1.51.0
+	google.golang.org/protobuf v1.33.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/go-task/slim-sprig v0.0.0-20230315185526-52ccab3ef572 // indirect
+	github.com/golang/groupcache v0.0.0-20200121045136-8c9f03a8e57e // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/google/pprof v0.0.0-20210407192527-94a9f03dee38 // indirect
+	github.com/onsi/ginkgo/v2 v2.9.5 // indirect
+	github.com/quic-go/qpack v0.5.1 // indirect
+	go.uber.org/mock v0.4.0 // indirect
+	golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842 // indirect
+	golang.org/x/mod v0.17.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/tools v0.21.1-0.20240508182429-e35e4ccd0d2d // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 )